@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-robot/internal/auth"
+)
+
+// runCreateAdmin создаёт первого администратора или повышает существующего пользователя до admin.
+// Пароль берётся из ADMIN_PASSWORD или читается со стандартного ввода.
+func runCreateAdmin(database *sql.DB, passwords *auth.Passwords, args []string) error {
+	fs := flag.NewFlagSet("create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "имя пользователя (обязательно)")
+	email := fs.String("email", "", "email")
+	phone := fs.String("phone", "", "телефон")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("использование: create-admin -username NAME [-email EMAIL] [-phone PHONE]")
+	}
+
+	var existingID int
+	err := database.QueryRow(`SELECT id FROM guests WHERE username = $1`, *username).Scan(&existingID)
+	if err == nil {
+		if _, err := database.Exec(`UPDATE guests SET role = $1 WHERE id = $2`, auth.RoleAdmin, existingID); err != nil {
+			return err
+		}
+		fmt.Printf("Пользователь %s (id=%d) назначен администратором\n", *username, existingID)
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Пароль администратора: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("не удалось прочитать пароль: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	if password == "" {
+		return fmt.Errorf("пароль не может быть пустым")
+	}
+	hash, err := passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+	var id int
+	query := `INSERT INTO guests (username, email, password, phone, role) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err := database.QueryRow(query, *username, *email, hash, *phone, auth.RoleAdmin).Scan(&id); err != nil {
+		return err
+	}
+	fmt.Printf("Администратор %s создан (id=%d)\n", *username, id)
+	return nil
+}
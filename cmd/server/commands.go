@@ -0,0 +1,22 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-robot/internal/auth"
+)
+
+// runCommand выполняет подкоманду CLI вместо запуска HTTP-сервера.
+func runCommand(database *sql.DB, passwords *auth.Passwords, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(database, args[1:])
+	case "hash-passwords":
+		return runHashPasswords(database, passwords)
+	case "create-admin":
+		return runCreateAdmin(database, passwords, args[1:])
+	default:
+		return fmt.Errorf("неизвестная команда %q", args[0])
+	}
+}
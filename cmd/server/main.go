@@ -4,11 +4,21 @@ import (
 	"log"
 	"net/http"
 	"os" // Для работы с переменными окружения
+	"strconv"
+	"strings"
+	"time"
 
+	"go-robot/internal/auth"
+	"go-robot/internal/bot"
+	"go-robot/internal/catalog"
 	"go-robot/internal/chat"
 	"go-robot/internal/db"
 	"go-robot/internal/handlers"
+	"go-robot/internal/kitchen"
+	"go-robot/internal/orders"
 	"go-robot/internal/seed"
+	"go-robot/internal/storage"
+	"go-robot/internal/support"
 	"github.com/joho/godotenv" // Для локальной разработки с .env
 )
 
@@ -28,6 +38,30 @@ func main() {
 	}
 	defer database.Close()
 
+	// Настраиваем хеширование паролей
+	passwords, err := auth.NewPasswordsFromEnv()
+	if err != nil {
+		log.Fatalf("Ошибка настройки хеширования паролей: %v", err)
+	}
+
+	// Настраиваем выпуск токенов и хранилище сессий
+	tokens, err := auth.NewTokensFromEnv()
+	if err != nil {
+		log.Fatalf("Ошибка настройки токенов: %v", err)
+	}
+	sessions, err := auth.NewSessionsFromEnv(database, tokens)
+	if err != nil {
+		log.Fatalf("Ошибка настройки сессий: %v", err)
+	}
+
+	// Подкоманды CLI (например, migrate up) выполняются вместо запуска сервера
+	if len(os.Args) > 1 {
+		if err := runCommand(database, passwords, os.Args[1:]); err != nil {
+			log.Fatalf("Ошибка выполнения команды %s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	// Подсчитываем количество записей в таблице guests при запуске
 	var count int
 	countQuery := `SELECT COUNT(*) FROM guests`
@@ -37,25 +71,116 @@ func main() {
 		log.Printf("Количество сохранённых данных при запуске: %d", count)
 	}
 
-	// Заполняем таблицу продуктов начальными данными (если требуется)
+	// Заполняем категории и продукты начальными данными (если требуется)
+	seed.InsertSampleCategories(database)
 	seed.InsertSampleProducts(database)
 
-	// Инициализируем чат-хаб для WebSocket
-	hub := chat.NewChatHub()
-	go hub.Run() // Запускаем обработку сообщений чата в отдельной горутине
+	// Разрешённые источники WebSocket-подключений (через запятую, "*" – любые)
+	if origins := os.Getenv("WS_ALLOWED_ORIGINS"); origins != "" {
+		chat.AllowOrigins(strings.Split(origins, ","))
+	} else {
+		chat.AllowOrigins(nil)
+	}
+
+	// Инициализируем чат-хаб для WebSocket; история чатов хранится в Postgres
+	// CHAT_BROKER=postgres связывает несколько экземпляров сервера через LISTEN/NOTIFY
+	chatStore := chat.NewPostgresStore(database)
+	var chatBroker chat.Broker = chat.NewMemoryBroker()
+	if os.Getenv("CHAT_BROKER") == "postgres" {
+		pgBroker, err := chat.NewPostgresBroker(database, os.Getenv("DATABASE_URL"))
+		if err != nil {
+			log.Fatalf("Ошибка подключения брокера чата: %v", err)
+		}
+		defer pgBroker.Close()
+		chatBroker = pgBroker
+	}
+	hub := chat.NewChatHub(chatStore, chatBroker)
+	// Очередь поддержки: гость, написавший в чат поддержки, получает обращение;
+	// SUPPORT_ASSIGNMENT=manual отключает автоназначение наименее загруженному оператору
+	var assigner support.Assigner = support.LeastBusy{}
+	if os.Getenv("SUPPORT_ASSIGNMENT") == "manual" {
+		assigner = support.Manual{}
+	}
+	supportService := support.NewService(database, assigner)
+	hub.UseSupport(supportService)
+	// Бот-помощник по меню отвечает гостям до передачи диалога оператору (CHAT_BOT=off отключает)
+	if os.Getenv("CHAT_BOT") != "off" {
+		hub.UseBot(bot.New(database))
+	}
+	// Вложения чата (изображения) хранятся в ATTACHMENTS_DIR; ATTACHMENT_MAX_BYTES – лимит размера файла
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+	if attachmentsDir == "" {
+		attachmentsDir = "./data/attachments"
+	}
+	attachmentStorage, err := storage.NewLocal(attachmentsDir)
+	if err != nil {
+		log.Fatalf("Ошибка настройки хранилища вложений: %v", err)
+	}
+	var attachmentMaxBytes int64
+	if v := os.Getenv("ATTACHMENT_MAX_BYTES"); v != "" {
+		if attachmentMaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			log.Fatalf("Некорректный ATTACHMENT_MAX_BYTES: %v", err)
+		}
+	}
+	attachments := chat.NewAttachments(chatStore, attachmentStorage, attachmentMaxBytes)
+	hub.UseAttachments(attachments)
+	// Фильтр сообщений: CHAT_BANNED_WORDS – слова через запятую ("слово*" – по началу слова),
+	// CHAT_LINKS=block запрещает гостям ссылки, кроме доменов из CHAT_LINK_DOMAINS
+	hub.UseFilter(chat.NewFilter(chat.FilterConfig{
+		Words:          splitList(os.Getenv("CHAT_BANNED_WORDS")),
+		BlockLinks:     os.Getenv("CHAT_LINKS") == "block",
+		AllowedDomains: splitList(os.Getenv("CHAT_LINK_DOMAINS")),
+	}))
+	// Уведомления о сообщениях, непрочитанных дольше CHAT_NOTIFY_AFTER (по умолчанию 15m);
+	// CHAT_NOTIFY_AFTER=off отключает. Пока уведомления только пишутся в лог
+	if v := os.Getenv("CHAT_NOTIFY_AFTER"); v != "off" {
+		notifyAfter := 15 * time.Minute
+		if v != "" {
+			if notifyAfter, err = time.ParseDuration(v); err != nil || notifyAfter <= 0 {
+				log.Fatalf("Некорректный CHAT_NOTIFY_AFTER: %q", v)
+			}
+		}
+		hub.UseNotifier(chat.LogNotifier{}, notifyAfter)
+	}
+	hub.Run() // Запускаем фоновые задачи чата: присутствие и уведомления
+
+	// Категории меню
+	categories := catalog.NewService(database)
+
+	// Сервис статусов заказов и хаб отслеживания заказов в реальном времени
+	orderService := orders.NewService(database)
+	orderHub := chat.NewOrderHub(orderService)
+
+	// Кухонный экран: очередь активных заказов и её поток в реальном времени
+	kitchenService := kitchen.NewService(database, orderService)
+	kitchenHub := chat.NewKitchenHub(kitchenService, orderService)
 
 	// Регистрируем обработчики API
-	http.HandleFunc("/register", handlers.RegisterHandler(database))
-	http.HandleFunc("/login", handlers.LoginHandler(database))
-	http.HandleFunc("/guest/", handlers.GuestHandler(database))
-	http.HandleFunc("/products", handlers.ProductsHandler(database))
-	http.HandleFunc("/products/", handlers.ProductUpdateHandler(database))
-	http.HandleFunc("/orders", handlers.OrdersHandler(database))
+	http.HandleFunc("/register", handlers.RegisterHandler(database, passwords))
+	http.HandleFunc("/login", handlers.LoginHandler(database, passwords, sessions))
+	http.HandleFunc("/token/refresh", handlers.RefreshHandler(sessions))
+	http.HandleFunc("/logout", handlers.LogoutHandler(sessions))
+	http.HandleFunc("/guest/", handlers.RequireAuth(tokens, handlers.GuestHandler(database, passwords)))
+	http.HandleFunc("/products", handlers.RequirePermissionForWrites(tokens, auth.PermManageProducts, handlers.ProductsHandler(database)))
+	http.HandleFunc("/products/", handlers.RequirePermission(tokens, auth.PermManageProducts, handlers.ProductUpdateHandler(database)))
+	http.HandleFunc("/categories", handlers.RequirePermissionForWrites(tokens, auth.PermManageProducts, handlers.CategoriesHandler(categories)))
+	http.HandleFunc("/categories/", handlers.RequirePermission(tokens, auth.PermManageProducts, handlers.CategoryAdminHandler(categories)))
+	http.HandleFunc("/orders", handlers.RequireAuth(tokens, handlers.OrdersHandler(database, orderService)))
+	http.HandleFunc("/orders/", handlers.RequireAuth(tokens, handlers.OrderStatusHandler(database, orderService)))
 	http.HandleFunc("/health", handlers.HealthHandler)
 	// Регистрируем новый API-эндпоинт для общего количества клиентов
 	http.HandleFunc("/api/total-customers", handlers.TotalCustomersHandler(database))
 	// Подключаем WebSocket-обработчик
-	http.HandleFunc("/ws", hub.ChatHandler)
+	http.HandleFunc("/ws", handlers.RequireAuth(tokens, hub.ChatHandler))
+	http.HandleFunc("/chats/", handlers.RequireAuth(tokens, handlers.ChatsHandler(hub, chatStore, attachments)))
+	http.HandleFunc("/chats/unread", handlers.RequireAuth(tokens, handlers.ChatUnreadHandler(chatStore)))
+	http.HandleFunc("/chats/search", handlers.RequirePermission(tokens, auth.PermChatAdmin, handlers.ChatSearchHandler(chatStore)))
+	http.HandleFunc("/transcripts/", handlers.RequirePermission(tokens, auth.PermChatAdmin, handlers.TranscriptHandler(chatStore)))
+	http.HandleFunc("/attachments/", handlers.RequireAuth(tokens, handlers.AttachmentsHandler(hub, attachments)))
+	http.HandleFunc("/support/", handlers.RequirePermission(tokens, auth.PermChatAdmin, handlers.SupportHandler(supportService)))
+	http.HandleFunc("/ws/orders", handlers.RequireAuth(tokens, orderHub.OrderHandler))
+	http.HandleFunc("/kitchen/", handlers.RequirePermission(tokens, auth.PermKitchen, handlers.KitchenHandler(kitchenService)))
+	http.HandleFunc("/ws/kitchen", handlers.RequirePermission(tokens, auth.PermKitchen, kitchenHub.KitchenHandler))
 
 	// Включаем CORS для всех маршрутов (если требуется)
 	handler := handlers.EnableCORS(http.DefaultServeMux)
@@ -97,3 +222,14 @@ func main() {
 
 
 
+
+// splitList разбирает список через запятую из переменной окружения; пустые элементы пропускаются.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go-robot/internal/migrations"
+)
+
+// runMigrate выполняет подкоманду migrate: up | down [N] | status.
+func runMigrate(database *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("использование: migrate up | down [N] | status")
+	}
+	migrator, err := migrations.New(database)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Применено миграций: %d\n", n)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("некорректное количество шагов: %s", args[1])
+			}
+		}
+		n, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Откачено миграций: %d\n", n)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state = "applied"
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("неизвестная команда migrate %q: ожидается up, down или status", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"go-robot/internal/auth"
+)
+
+// runHashPasswords одноразово заменяет пароли, хранящиеся открытым текстом, их хешами.
+// Уже захешированные записи пропускаются, поэтому команду можно запускать повторно.
+func runHashPasswords(database *sql.DB, passwords *auth.Passwords) error {
+	rows, err := database.Query(`SELECT id, password FROM guests`)
+	if err != nil {
+		return err
+	}
+	plain := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var password string
+		if err := rows.Scan(&id, &password); err != nil {
+			rows.Close()
+			return err
+		}
+		if !passwords.IsHashed(password) {
+			plain[id] = password
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	for id, password := range plain {
+		hash, err := passwords.Hash(password)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("гость %d: %w", id, err)
+		}
+		// Условие на старое значение защищает от перезаписи пароля, изменённого во время работы команды.
+		if _, err := tx.Exec(`UPDATE guests SET password = $1 WHERE id = $2 AND password = $3`, hash, id, password); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("гость %d: %w", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Захешировано паролей: %d\n", len(plain))
+	return nil
+}
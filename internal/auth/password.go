@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrUnknownFormat возвращается, если строка не похожа ни на один поддерживаемый хеш.
+var ErrUnknownFormat = errors.New("неизвестный формат хеша пароля")
+
+// Hasher – алгоритм хеширования паролей.
+// Хеш хранится в самоописывающем формате ($2a$..., $argon2id$v=19$...),
+// поэтому по строке всегда можно определить алгоритм и его параметры.
+type Hasher interface {
+	// Hash возвращает закодированный хеш пароля.
+	Hash(password string) (string, error)
+	// Verify сравнивает пароль с хешем за постоянное время.
+	Verify(encoded, password string) (bool, error)
+	// Recognizes сообщает, создан ли хеш этим алгоритмом.
+	Recognizes(encoded string) bool
+	// NeedsRehash сообщает, что хеш создан с устаревшими параметрами.
+	NeedsRehash(encoded string) bool
+}
+
+// BcryptHasher хеширует пароли алгоритмом bcrypt.
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+// Hash возвращает bcrypt-хеш пароля.
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify проверяет пароль по bcrypt-хешу.
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Recognizes сообщает, является ли строка bcrypt-хешем.
+func (h BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// NeedsRehash сообщает, отличается ли стоимость хеша от текущей.
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost()
+}
+
+// Argon2idHasher хеширует пароли алгоритмом argon2id.
+// Формат: $argon2id$v=19$m=<KiB>,t=<итерации>,p=<потоки>$<соль>$<хеш>.
+type Argon2idHasher struct {
+	Memory  uint32 // КиБ
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2id – параметры argon2id по рекомендациям OWASP.
+var DefaultArgon2id = Argon2idHasher{Memory: 64 * 1024, Time: 1, Threads: 4, SaltLen: 16, KeyLen: 32}
+
+type argon2Params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// Hash возвращает argon2id-хеш пароля со случайной солью.
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, h.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify проверяет пароль по argon2id-хешу с параметрами, записанными в самом хеше.
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	p, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+}
+
+// Recognizes сообщает, является ли строка argon2id-хешем.
+func (h Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// NeedsRehash сообщает, отличаются ли параметры хеша от текущих.
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, err := parseArgon2(encoded)
+	if err != nil {
+		return true
+	}
+	return p.memory != h.Memory || p.time != h.Time || p.threads != h.Threads ||
+		uint32(len(p.salt)) != h.SaltLen || uint32(len(p.key)) != h.KeyLen
+}
+
+func parseArgon2(encoded string) (argon2Params, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, fmt.Errorf("неподдерживаемая версия argon2: %s", parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, fmt.Errorf("некорректные параметры argon2: %w", err)
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, err
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+// Passwords хеширует новые пароли текущим алгоритмом и проверяет хеши,
+// созданные любым из известных алгоритмов.
+type Passwords struct {
+	current Hasher
+	hashers []Hasher
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewPasswords создаёт Passwords; current используется для новых хешей,
+// legacy – только для проверки ранее сохранённых.
+func NewPasswords(current Hasher, legacy ...Hasher) *Passwords {
+	return &Passwords{current: current, hashers: append([]Hasher{current}, legacy...)}
+}
+
+// NewPasswordsFromEnv настраивает хеширование из переменных окружения:
+// PASSWORD_HASHER (argon2id | bcrypt), BCRYPT_COST, ARGON2_MEMORY, ARGON2_TIME, ARGON2_THREADS.
+func NewPasswordsFromEnv() (*Passwords, error) {
+	bc := BcryptHasher{Cost: bcrypt.DefaultCost}
+	if v := os.Getenv("BCRYPT_COST"); v != "" {
+		cost, err := strconv.Atoi(v)
+		if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("некорректное значение BCRYPT_COST: %s", v)
+		}
+		bc.Cost = cost
+	}
+
+	ar := DefaultArgon2id
+	for name, dst := range map[string]*uint32{"ARGON2_MEMORY": &ar.Memory, "ARGON2_TIME": &ar.Time} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil || n == 0 {
+				return nil, fmt.Errorf("некорректное значение %s: %s", name, v)
+			}
+			*dst = uint32(n)
+		}
+	}
+	if v := os.Getenv("ARGON2_THREADS"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 8)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("некорректное значение ARGON2_THREADS: %s", v)
+		}
+		ar.Threads = uint8(n)
+	}
+
+	switch os.Getenv("PASSWORD_HASHER") {
+	case "", "argon2id":
+		return NewPasswords(ar, bc), nil
+	case "bcrypt":
+		return NewPasswords(bc, ar), nil
+	default:
+		return nil, fmt.Errorf("неизвестный PASSWORD_HASHER: %s", os.Getenv("PASSWORD_HASHER"))
+	}
+}
+
+// Hash хеширует пароль текущим алгоритмом.
+func (p *Passwords) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// IsHashed сообщает, распознан ли encoded как хеш одним из известных алгоритмов.
+func (p *Passwords) IsHashed(encoded string) bool {
+	return p.hasherFor(encoded) != nil
+}
+
+// Verify проверяет пароль. rehash = true, если пароль верен, но хеш следует
+// пересчитать текущим алгоритмом (другой алгоритм, устаревшие параметры
+// или пароль, ещё хранящийся открытым текстом до запуска hash-passwords).
+func (p *Passwords) Verify(encoded, password string) (ok, rehash bool, err error) {
+	h := p.hasherFor(encoded)
+	if h == nil {
+		// Открытый текст из старых записей сравниваем за постоянное время.
+		ok = subtle.ConstantTimeCompare([]byte(encoded), []byte(password)) == 1
+		return ok, ok, nil
+	}
+	ok, err = h.Verify(encoded, password)
+	if err != nil || !ok {
+		return false, false, err
+	}
+	return true, h != p.current || p.current.NeedsRehash(encoded), nil
+}
+
+// DummyVerify тратит столько же времени, сколько настоящая проверка,
+// чтобы по времени ответа нельзя было определить существование пользователя.
+func (p *Passwords) DummyVerify(password string) {
+	p.dummyOnce.Do(func() {
+		p.dummyHash, _ = p.current.Hash("dummy-password")
+	})
+	if p.dummyHash != "" {
+		p.current.Verify(p.dummyHash, password)
+	}
+}
+
+func (p *Passwords) hasherFor(encoded string) Hasher {
+	for _, h := range p.hashers {
+		if h.Recognizes(encoded) {
+			return h
+		}
+	}
+	return nil
+}
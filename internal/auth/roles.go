@@ -0,0 +1,56 @@
+package auth
+
+// Role – роль пользователя (колонка guests.role).
+type Role string
+
+const (
+	RoleGuest    Role = "guest"    // посетитель: свой профиль, свои заказы, свой чат поддержки
+	RoleOperator Role = "operator" // оператор поддержки: чаты и заказы
+	RoleKitchen  Role = "kitchen"  // кухня: очередь и статусы заказов
+	RoleAdmin    Role = "admin"    // администратор: всё
+)
+
+// Roles – все известные роли.
+var Roles = []Role{RoleGuest, RoleOperator, RoleKitchen, RoleAdmin}
+
+// Valid сообщает, является ли роль известной.
+func (r Role) Valid() bool {
+	for _, known := range Roles {
+		if r == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Permission – право на действие, проверяемое middleware.
+type Permission string
+
+const (
+	PermManageProducts Permission = "products:manage" // создание и изменение блюд
+	PermManageOrders   Permission = "orders:manage"   // изменение статуса заказов
+	PermManageGuests   Permission = "guests:manage"   // чужие профили и роли
+	PermChatAdmin      Permission = "chat:admin"      // административные действия в чате
+	PermKitchen        Permission = "kitchen:operate" // кухонный экран и отметки готовности
+)
+
+// permissions – матрица прав по ролям. Администратору разрешено всё.
+var permissions = map[Role]map[Permission]bool{
+	RoleGuest: {},
+	RoleOperator: {
+		PermManageOrders: true,
+		PermChatAdmin:    true,
+	},
+	RoleKitchen: {
+		PermManageOrders: true,
+		PermKitchen:      true,
+	},
+}
+
+// Can сообщает, есть ли у роли право perm.
+func (r Role) Can(perm Permission) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	return permissions[r][perm]
+}
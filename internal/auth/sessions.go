@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+)
+
+// ErrRefreshReused возвращается, когда предъявлен уже заменённый refresh-токен.
+// Это признак кражи токена, поэтому вся сессия отзывается.
+var ErrRefreshReused = errors.New("refresh-токен уже использован, сессия отозвана")
+
+// TokenPair – ответ на вход и обновление токенов.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // секунды жизни access-токена
+}
+
+// Sessions выпускает пары токенов и хранит ротируемые refresh-токены в таблице refresh_tokens.
+type Sessions struct {
+	db         *sql.DB
+	tokens     *Tokens
+	refreshTTL time.Duration
+}
+
+// NewSessions создаёт Sessions.
+func NewSessions(db *sql.DB, tokens *Tokens, refreshTTL time.Duration) *Sessions {
+	return &Sessions{db: db, tokens: tokens, refreshTTL: refreshTTL}
+}
+
+// NewSessionsFromEnv читает REFRESH_TOKEN_TTL (по умолчанию 720h).
+func NewSessionsFromEnv(db *sql.DB, tokens *Tokens) (*Sessions, error) {
+	ttl, err := durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return NewSessions(db, tokens, ttl), nil
+}
+
+// Tokens возвращает выпускающий access-токены объект.
+func (s *Sessions) Tokens() *Tokens {
+	return s.tokens
+}
+
+// Start открывает новую сессию для гостя.
+func (s *Sessions) Start(id Identity) (TokenPair, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	pair, err := s.issue(tx, id, family)
+	if err != nil {
+		tx.Rollback()
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// Refresh обменивает refresh-токен на новую пару; старый токен больше не действует.
+func (s *Sessions) Refresh(refreshToken string) (TokenPair, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return TokenPair{}, err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID    int64
+		id         Identity
+		family     string
+		expiresAt  time.Time
+		replacedAt sql.NullTime
+		revokedAt  sql.NullTime
+	)
+	err = tx.QueryRow(`
+		SELECT t.id, t.guest_id, g.username, g.role, t.family_id, t.expires_at, t.replaced_at, t.revoked_at
+		FROM refresh_tokens t
+		JOIN guests g ON g.id = t.guest_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t`, hashToken(refreshToken)).
+		Scan(&tokenID, &id.GuestID, &id.Username, &id.Role, &family, &expiresAt, &replacedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return TokenPair{}, ErrInvalidToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return TokenPair{}, ErrInvalidToken
+	}
+	if replacedAt.Valid {
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL`, family); err != nil {
+			return TokenPair{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return TokenPair{}, err
+		}
+		log.Printf("Повторное использование refresh-токена гостя %d, сессия %s отозвана", id.GuestID, family)
+		return TokenPair{}, ErrRefreshReused
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET replaced_at = NOW() WHERE id = $1`, tokenID); err != nil {
+		return TokenPair{}, err
+	}
+	pair, err := s.issue(tx, id, family)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, tx.Commit()
+}
+
+// Revoke завершает сессию, к которой принадлежит refresh-токен.
+func (s *Sessions) Revoke(refreshToken string) error {
+	res, err := s.db.Exec(`
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL
+		  AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`,
+		hashToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// issue выпускает access-токен и новый refresh-токен в семье family.
+func (s *Sessions) issue(tx *sql.Tx, id Identity, family string) (TokenPair, error) {
+	access, err := s.tokens.Issue(id)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (guest_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		id.GuestID, family, hashToken(refresh), time.Now().Add(s.refreshTTL)); err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken возвращается для подделанных, повреждённых или просроченных токенов.
+var ErrInvalidToken = errors.New("недействительный токен")
+
+// Identity – аутентифицированный гость, извлечённый из access-токена.
+type Identity struct {
+	GuestID  int    `json:"sub"`
+	Username string `json:"name"`
+	Role     Role   `json:"role"`
+	// ExpiresAt – срок действия токена, из которого получен гость (заполняет Parse);
+	// долгие соединения (WebSocket) закрываются по его истечении.
+	ExpiresAt time.Time `json:"-"`
+}
+
+// Can сообщает, есть ли у гостя право perm.
+func (id Identity) Can(perm Permission) bool {
+	return id.Role.Can(perm)
+}
+
+// claims – полезная нагрузка access-токена (подмножество JWT).
+type claims struct {
+	Identity
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// jwtHeader – единственный поддерживаемый заголовок: HS256.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Tokens выпускает и проверяет подписанные access-токены в формате JWT (HS256).
+type Tokens struct {
+	secret    []byte
+	accessTTL time.Duration
+}
+
+// NewTokens создаёт Tokens с секретом подписи и временем жизни access-токена.
+func NewTokens(secret []byte, accessTTL time.Duration) *Tokens {
+	return &Tokens{secret: secret, accessTTL: accessTTL}
+}
+
+// NewTokensFromEnv читает AUTH_SECRET и ACCESS_TOKEN_TTL (по умолчанию 15m).
+// Без AUTH_SECRET генерируется случайный секрет, и токены перестают действовать после перезапуска.
+func NewTokensFromEnv() (*Tokens, error) {
+	secret := []byte(os.Getenv("AUTH_SECRET"))
+	if len(secret) == 0 {
+		log.Println("AUTH_SECRET не задан, используется случайный секрет (токены не переживут перезапуск)")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+	ttl, err := durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	return NewTokens(secret, ttl), nil
+}
+
+// AccessTTL возвращает время жизни access-токена.
+func (t *Tokens) AccessTTL() time.Duration {
+	return t.accessTTL
+}
+
+// Issue выпускает access-токен для гостя.
+func (t *Tokens) Issue(id Identity) (string, error) {
+	now := time.Now()
+	payload, err := json.Marshal(claims{
+		Identity:  id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.accessTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + t.sign(unsigned), nil
+}
+
+// Parse проверяет подпись и срок действия access-токена.
+func (t *Tokens) Parse(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return Identity{}, ErrInvalidToken
+	}
+	expected := t.sign(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return Identity{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return Identity{}, ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return Identity{}, ErrInvalidToken
+	}
+	c.Identity.ExpiresAt = time.Unix(c.ExpiresAt, 0)
+	return c.Identity, nil
+}
+
+func (t *Tokens) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// BearerToken извлекает токен из заголовка "Authorization: Bearer <token>".
+func BearerToken(authorization string) (string, bool) {
+	const prefix = "Bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(authorization[len(prefix):]), true
+}
+
+type identityKey struct{}
+
+// WithIdentity кладёт аутентифицированного гостя в контекст запроса.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext возвращает гостя, положенного в контекст middleware.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("некорректное значение %s: %s", name, v)
+	}
+	return d, nil
+}
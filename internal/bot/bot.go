@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/models"
+	"go-robot/internal/money"
+	"go-robot/internal/orders"
+)
+
+// Name – имя бота в чате.
+const Name = "robot"
+
+// maxListed – сколько блюд бот перечисляет в одном ответе.
+const maxListed = 10
+
+// Bot – помощник по меню. Отвечает локально по таблицам products, categories и orders,
+// по запросу или при непонятном вопросе передаёт диалог оператору.
+type Bot struct {
+	db *sql.DB
+}
+
+// New создаёт Bot.
+func New(db *sql.DB) *Bot {
+	return &Bot{db: db}
+}
+
+// Name возвращает имя отправителя сообщений бота.
+func (b *Bot) Name() string {
+	return Name
+}
+
+// Reply отвечает на сообщение гостя. handoff = true – гость просит оператора.
+// Без операторов (operators = false) бот не обещает передать диалог и продолжает отвечать сам.
+func (b *Bot) Reply(guest auth.Identity, text string, operators bool) (reply string, handoff bool) {
+	m := Detect(text)
+	var err error
+	switch m.Intent {
+	case IntentHandoff:
+		if !operators {
+			return tr(m.Lang, "Сейчас операторов нет, но я постараюсь помочь. ",
+				"No operators are available right now, but I will try to help. ") + help(m.Lang, false), false
+		}
+		return tr(m.Lang, "Передаю диалог оператору, он скоро подключится.",
+			"Connecting you to an operator, please wait."), true
+	case IntentOrderStatus:
+		reply, err = b.orderStatus(m, guest.GuestID)
+	case IntentPrice:
+		reply, err = b.price(m)
+	case IntentUnderCalories:
+		reply, err = b.underCalories(m)
+	case IntentCategory:
+		reply, err = b.category(m)
+	case IntentHelp:
+		reply = help(m.Lang, operators)
+	default:
+		// Слово может оказаться названием категории или блюда без ключевых слов.
+		if reply, err = b.category(m); err == nil && reply == "" {
+			reply = tr(m.Lang, "Я не понял вопрос. ", "Sorry, I did not get that. ") + help(m.Lang, operators)
+		}
+	}
+	if err != nil {
+		log.Printf("Ошибка ответа бота на %q: %v", text, err)
+		if !operators {
+			return tr(m.Lang, "Не получилось найти ответ, попробуйте спросить иначе.",
+				"I could not find an answer, please try asking differently."), false
+		}
+		return tr(m.Lang, "Не получилось найти ответ, передаю диалог оператору.",
+			"I could not find an answer, connecting you to an operator."), true
+	}
+	return reply, false
+}
+
+func tr(lang Lang, ru, en string) string {
+	if lang == LangRU {
+		return ru
+	}
+	return en
+}
+
+// help перечисляет, что умеет бот; про оператора – только если он есть.
+func help(lang Lang, operators bool) string {
+	s := tr(lang,
+		"Я могу показать блюда категории («покажи роллы»), найти блюда до N ккал («до 300 калорий»), "+
+			"назвать цену («сколько стоит Филадельфия») и статус вашего последнего заказа («где мой заказ»).",
+		"I can show a category (\"show rolls\"), find dishes under N calories (\"under 300 calories\"), "+
+			"tell a price (\"how much is Philadelphia\") and the status of your last order (\"where is my order\").")
+	if operators {
+		s += tr(lang, " Чтобы поговорить с человеком, напишите «оператор».", " Type \"operator\" to talk to a human.")
+	}
+	return s
+}
+
+// dish – блюдо для ответа бота.
+type dish struct {
+	title    string
+	price    money.Money
+	calories int
+	category models.Category
+}
+
+func (d dish) line(lang Lang) string {
+	return fmt.Sprintf("• %s – %s, %d %s", d.title, d.price, d.calories, tr(lang, "ккал", "kcal"))
+}
+
+// dishes возвращает доступные блюда видимых категорий; where – условия и порядок по полям products.
+func (b *Bot) dishes(where string, args ...interface{}) ([]dish, error) {
+	rows, err := b.db.Query(`
+		SELECT p.title, p.price_minor, p.currency, p.calories, c.id, c.slug, c.names
+		FROM products p JOIN categories c ON c.id = p.category_id AND c.visible AND p.available `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []dish
+	for rows.Next() {
+		var (
+			d     dish
+			names []byte
+		)
+		if err := rows.Scan(&d.title, &d.price.Minor, &d.price.Currency, &d.calories, &d.category.ID, &d.category.Slug, &names); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(names, &d.category.Names); err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func lines(lang Lang, list []dish) string {
+	out := make([]string, 0, len(list))
+	for i, d := range list {
+		if i == maxListed {
+			out = append(out, "…")
+			break
+		}
+		out = append(out, d.line(lang))
+	}
+	return strings.Join(out, "\n")
+}
+
+// category перечисляет блюда упомянутой категории, а без неё – список категорий.
+// Категория узнаётся по названию на любом языке, ответ – на языке гостя.
+// Для нераспознанного сообщения без упоминания категории возвращает "".
+func (b *Bot) category(m Match) (string, error) {
+	all, err := b.dishes(`ORDER BY c.position, c.id, title`)
+	if err != nil {
+		return "", err
+	}
+	var categories []string
+	best, bestID, bestScore := "", 0, 0
+	for i, d := range all {
+		if i > 0 && all[i-1].category.ID == d.category.ID {
+			continue
+		}
+		name := d.category.LocalName(string(m.Lang))
+		categories = append(categories, name)
+		for _, n := range d.category.Names {
+			if s := score(n, m.Tokens); s > bestScore {
+				best, bestID, bestScore = name, d.category.ID, s
+			}
+		}
+	}
+	if best == "" {
+		if m.Intent != IntentCategory {
+			return "", nil
+		}
+		return tr(m.Lang, "Категории меню: ", "Menu categories: ") + strings.Join(categories, ", "), nil
+	}
+	var list []dish
+	for _, d := range all {
+		if d.category.ID == bestID {
+			list = append(list, d)
+		}
+	}
+	return tr(m.Lang, "Категория «"+best+"»:\n", "Category \""+best+"\":\n") + lines(m.Lang, list), nil
+}
+
+func (b *Bot) underCalories(m Match) (string, error) {
+	list, err := b.dishes(`WHERE calories <= $1 ORDER BY calories, title`, m.Calories)
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return tr(m.Lang, fmt.Sprintf("Блюд до %d ккал нет.", m.Calories),
+			fmt.Sprintf("No dishes under %d kcal.", m.Calories)), nil
+	}
+	return tr(m.Lang, fmt.Sprintf("Блюда до %d ккал:\n", m.Calories),
+		fmt.Sprintf("Dishes under %d kcal:\n", m.Calories)) + lines(m.Lang, list), nil
+}
+
+func (b *Bot) price(m Match) (string, error) {
+	all, err := b.dishes(`ORDER BY title`)
+	if err != nil {
+		return "", err
+	}
+	var found *dish
+	bestScore := 0
+	for i := range all {
+		if s := score(all[i].title, m.Tokens); s > bestScore {
+			found, bestScore = &all[i], s
+		}
+	}
+	if found == nil {
+		return tr(m.Lang, "Уточните название блюда, например: «сколько стоит Филадельфия».",
+			"Which dish? For example: \"how much is Philadelphia\"."), nil
+	}
+	return tr(m.Lang, fmt.Sprintf("%s стоит %s.", found.title, found.price),
+		fmt.Sprintf("%s costs %s.", found.title, found.price)), nil
+}
+
+// statusNames – названия статусов заказа для гостя.
+var statusNames = map[orders.Status][2]string{
+	orders.StatusPlaced:         {"оформлен", "placed"},
+	orders.StatusAccepted:       {"принят", "accepted"},
+	orders.StatusCooking:        {"готовится", "being cooked"},
+	orders.StatusReady:          {"готов", "ready"},
+	orders.StatusOutForDelivery: {"передан курьеру", "out for delivery"},
+	orders.StatusDelivered:      {"доставлен", "delivered"},
+	orders.StatusCancelled:      {"отменён", "cancelled"},
+	orders.StatusRejected:       {"отклонён", "rejected"},
+}
+
+func (b *Bot) orderStatus(m Match, guestID int) (string, error) {
+	var id int
+	var status orders.Status
+	err := b.db.QueryRow(`
+		SELECT id, status FROM orders
+		WHERE guest_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`, guestID).Scan(&id, &status)
+	if err == sql.ErrNoRows {
+		return tr(m.Lang, "У вас пока нет заказов.", "You have no orders yet."), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name := statusNames[status]
+	return tr(m.Lang, fmt.Sprintf("Заказ №%d: %s.", id, name[0]),
+		fmt.Sprintf("Order #%d is %s.", id, name[1])), nil
+}
@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"go-robot/internal/auth"
+)
+
+// Передача оператору и справка не обращаются к базе, поэтому Bot здесь без db.
+func TestReplyHandoff(t *testing.T) {
+	b := New(nil)
+	guest := auth.Identity{GuestID: 1, Username: "guest"}
+	tests := []struct {
+		text        string
+		operators   bool
+		handoff     bool
+		mentions    string
+		notMentions string
+	}{
+		{text: "позовите оператора", operators: true, handoff: true, mentions: "Передаю диалог оператору"},
+		{text: "operator please", operators: true, handoff: true, mentions: "Connecting you to an operator"},
+		{text: "позовите оператора", operators: false, handoff: false, mentions: "операторов нет", notMentions: "Передаю"},
+		{text: "operator please", operators: false, handoff: false, mentions: "No operators", notMentions: "Type \"operator\""},
+		{text: "помощь", operators: true, handoff: false, mentions: "напишите «оператор»"},
+		{text: "help", operators: false, handoff: false, mentions: "I can show", notMentions: "operator"},
+	}
+	for _, tt := range tests {
+		reply, handoff := b.Reply(guest, tt.text, tt.operators)
+		if handoff != tt.handoff {
+			t.Errorf("Reply(%q, operators=%v) handoff = %v, want %v", tt.text, tt.operators, handoff, tt.handoff)
+		}
+		if !strings.Contains(reply, tt.mentions) {
+			t.Errorf("Reply(%q, operators=%v) = %q, want it to mention %q", tt.text, tt.operators, reply, tt.mentions)
+		}
+		if tt.notMentions != "" && strings.Contains(reply, tt.notMentions) {
+			t.Errorf("Reply(%q, operators=%v) = %q, must not mention %q", tt.text, tt.operators, reply, tt.notMentions)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Intent – распознанное намерение гостя.
+type Intent string
+
+const (
+	IntentUnknown       Intent = ""
+	IntentHelp          Intent = "help"
+	IntentHandoff       Intent = "handoff"        // позвать живого оператора
+	IntentOrderStatus   Intent = "order_status"   // статус последнего заказа
+	IntentPrice         Intent = "price"          // цена блюда
+	IntentUnderCalories Intent = "under_calories" // блюда не больше N ккал
+	IntentCategory      Intent = "category"       // блюда категории или список категорий
+)
+
+// Lang – язык ответа.
+type Lang string
+
+const (
+	LangRU Lang = "ru"
+	LangEN Lang = "en"
+)
+
+// Match – результат разбора сообщения.
+type Match struct {
+	Intent   Intent
+	Lang     Lang
+	Calories int      // для IntentUnderCalories
+	Tokens   []string // нормализованные слова сообщения
+}
+
+// rule – ключевые слова намерения. Слово совпадает с началом слова сообщения
+// (основа: "калор" – "калорий", "калорийность"), фраза из нескольких слов – целиком.
+type rule struct {
+	intent   Intent
+	keywords []string
+}
+
+// rules проверяются по порядку: первое совпадение побеждает.
+var rules = []rule{
+	{IntentHandoff, []string{"оператор", "человек", "менеджер", "сотрудник", "живой", "позовите",
+		"operator", "human", "agent", "person", "manager", "real person"}},
+	{IntentOrderStatus, []string{"статус", "мой заказ", "моего заказа", "моем заказе", "последний заказ",
+		"где заказ", "когда привез", "status", "my order", "last order", "where is my"}},
+	{IntentPrice, []string{"цен", "стоит", "стоимост", "почем", "сколько стоит",
+		"price", "cost", "how much"}},
+	{IntentUnderCalories, []string{"калор", "ккал", "kcal", "calorie", "calories"}},
+	{IntentCategory, []string{"покаж", "категор", "меню", "раздел", "есть ли", "что есть",
+		"show", "category", "categories", "menu", "list"}},
+	{IntentHelp, []string{"привет", "здравств", "помо", "умеешь", "hello", "hi", "hey", "help"}},
+}
+
+// Detect разбирает сообщение гостя. Язык определяется по наличию кириллицы.
+func Detect(text string) Match {
+	m := Match{Lang: LangEN, Tokens: tokenize(text)}
+	for _, r := range text {
+		if unicode.Is(unicode.Cyrillic, r) {
+			m.Lang = LangRU
+			break
+		}
+	}
+	for _, r := range rules {
+		if hasKeyword(m.Tokens, r.keywords) {
+			m.Intent = r.intent
+			break
+		}
+	}
+	if m.Intent == IntentUnderCalories {
+		m.Calories = firstNumber(m.Tokens)
+		if m.Calories <= 0 {
+			m.Intent = IntentUnknown
+		}
+	}
+	return m
+}
+
+// tokenize приводит текст к нижнему регистру, заменяет ё на е и делит на слова.
+// Число, слитое с единицами ("500ккал"), отделяется от них.
+func tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+	var tokens []string
+	var b strings.Builder
+	digits := false
+	flush := func() {
+		if b.Len() > 0 {
+			tokens = append(tokens, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+			digits = true
+			b.WriteRune(r)
+		case unicode.IsLetter(r):
+			if digits {
+				flush()
+			}
+			digits = false
+			b.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+func hasKeyword(tokens, keywords []string) bool {
+	joined := " " + strings.Join(tokens, " ") + " "
+	for _, kw := range keywords {
+		if strings.Contains(kw, " ") {
+			if strings.Contains(joined, " "+kw) {
+				return true
+			}
+			continue
+		}
+		for _, t := range tokens {
+			// Короткие английские слова ("hi") совпадают только целиком.
+			prefix := len([]rune(kw)) > 3 || kw[0] >= utf8.RuneSelf
+			if t == kw || prefix && strings.HasPrefix(t, kw) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func firstNumber(tokens []string) int {
+	for _, t := range tokens {
+		if n, err := strconv.Atoi(t); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// stem – основа слова для нестрогого сравнения с учётом окончаний:
+// "филадельфия" и "филадельфию" дают одну основу.
+func stem(word string) string {
+	r := []rune(word)
+	n := len(r) - 2
+	if n < 3 {
+		n = min(len(r), 3)
+	}
+	return string(r[:n])
+}
+
+// score – сколько значимых слов name встречается в tokens (с точностью до окончаний).
+func score(name string, tokens []string) int {
+	n := 0
+	for _, w := range tokenize(name) {
+		if len([]rune(w)) < 3 {
+			continue
+		}
+		s := stem(w)
+		for _, t := range tokens {
+			if strings.HasPrefix(t, s) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		text     string
+		intent   Intent
+		lang     Lang
+		calories int
+	}{
+		{text: "Позовите оператора", intent: IntentHandoff, lang: LangRU},
+		{text: "I want a real person", intent: IntentHandoff, lang: LangEN},
+		{text: "где мой заказ?", intent: IntentOrderStatus, lang: LangRU},
+		{text: "Where is my order", intent: IntentOrderStatus, lang: LangEN},
+		{text: "Сколько стоит Филадельфия", intent: IntentPrice, lang: LangRU},
+		{text: "how much is philadelphia", intent: IntentPrice, lang: LangEN},
+		{text: "блюда до 300 калорий", intent: IntentUnderCalories, lang: LangRU, calories: 300},
+		{text: "до 500ккал", intent: IntentUnderCalories, lang: LangRU, calories: 500},
+		{text: "under 450kcal please", intent: IntentUnderCalories, lang: LangEN, calories: 450},
+		{text: "сколько калорий", intent: IntentUnknown, lang: LangRU},
+		{text: "покажи роллы", intent: IntentCategory, lang: LangRU},
+		{text: "show me the menu", intent: IntentCategory, lang: LangEN},
+		{text: "Привет!", intent: IntentHelp, lang: LangRU},
+		{text: "hi", intent: IntentHelp, lang: LangEN},
+		{text: "this history", intent: IntentUnknown, lang: LangEN},
+		{text: "Ёжик", intent: IntentUnknown, lang: LangRU},
+		{text: "", intent: IntentUnknown, lang: LangEN},
+
+		// Правила проверяются по порядку: первое совпадение побеждает.
+		{text: "оператор, сколько стоит сет?", intent: IntentHandoff, lang: LangRU},
+		{text: "Hello, статус заказа?", intent: IntentOrderStatus, lang: LangRU},
+		{text: "price of the 300 kcal roll", intent: IntentPrice, lang: LangEN},
+	}
+	for _, tt := range tests {
+		m := Detect(tt.text)
+		if m.Intent != tt.intent || m.Lang != tt.lang || m.Calories != tt.calories {
+			t.Errorf("Detect(%q) = %q/%s/%d, want %q/%s/%d",
+				tt.text, m.Intent, m.Lang, m.Calories, tt.intent, tt.lang, tt.calories)
+		}
+	}
+}
+
+func TestHasKeyword(t *testing.T) {
+	tests := []struct {
+		tokens   []string
+		keywords []string
+		want     bool
+	}{
+		{tokens: []string{"hi"}, keywords: []string{"hi"}, want: true},
+		{tokens: []string{"hit"}, keywords: []string{"hi"}, want: false},
+		{tokens: []string{"this"}, keywords: []string{"hi"}, want: false},
+		{tokens: []string{"helpful"}, keywords: []string{"help"}, want: true},
+		{tokens: []string{"калорийность"}, keywords: []string{"калор"}, want: true},
+		{tokens: []string{"цена"}, keywords: []string{"цен"}, want: true},
+		{tokens: []string{"беценный"}, keywords: []string{"цен"}, want: false},
+		{tokens: []string{"my", "orders"}, keywords: []string{"my order"}, want: true},
+		{tokens: []string{"army", "order"}, keywords: []string{"my order"}, want: false},
+		{tokens: []string{"my", "big", "order"}, keywords: []string{"my order"}, want: false},
+		{tokens: []string{"где", "заказ"}, keywords: []string{"оператор", "где заказ"}, want: true},
+		{tokens: nil, keywords: []string{"help"}, want: false},
+		{tokens: []string{"help"}, keywords: nil, want: false},
+	}
+	for _, tt := range tests {
+		if got := hasKeyword(tt.tokens, tt.keywords); got != tt.want {
+			t.Errorf("hasKeyword(%q, %q) = %v, want %v", tt.tokens, tt.keywords, got, tt.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "До 500ккал!", want: []string{"до", "500", "ккал"}},
+		{text: "Ёлки-палки", want: []string{"елки", "палки"}},
+		{text: "roll2go x3", want: []string{"roll", "2", "go", "x", "3"}},
+		{text: "  ,. ", want: nil},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
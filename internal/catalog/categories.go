@@ -0,0 +1,164 @@
+package catalog
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"go-robot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound возвращается, если категории не существует.
+	ErrNotFound = errors.New("категория не найдена")
+	// ErrInvalid возвращается при некорректном slug или пустом названии.
+	ErrInvalid = errors.New("некорректная категория: нужен slug из латиницы, цифр и дефисов и название на языке " + models.DefaultLocale)
+	// ErrSlugTaken возвращается, если slug уже занят другой категорией.
+	ErrSlugTaken = errors.New("категория с таким slug уже существует")
+	// ErrInUse возвращается при удалении категории, в которой есть продукты.
+	ErrInUse = errors.New("в категории есть продукты")
+)
+
+// slugPattern совпадает с проверкой в миграции 0015_categories.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Коды ошибок PostgreSQL.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+const categoryColumns = "id, slug, position, icon, visible, names"
+
+// Service – категории меню (таблица categories).
+type Service struct {
+	db *sql.DB
+}
+
+// NewService создаёт Service.
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// List возвращает категории в порядке вывода; скрытые – только при all = true.
+func (s *Service) List(all bool) ([]models.Category, error) {
+	rows, err := s.db.Query(`
+		SELECT `+categoryColumns+`
+		FROM categories
+		WHERE $1 OR visible
+		ORDER BY position, id`, all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.Category{}
+	for rows.Next() {
+		c, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// Get возвращает категорию по ID.
+func (s *Service) Get(id int) (models.Category, error) {
+	c, err := scanCategory(s.db.QueryRow(`SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return models.Category{}, ErrNotFound
+	}
+	return c, err
+}
+
+// Create добавляет категорию.
+func (s *Service) Create(c models.Category) (models.Category, error) {
+	names, err := prepare(&c)
+	if err != nil {
+		return models.Category{}, err
+	}
+	created, err := scanCategory(s.db.QueryRow(`
+		INSERT INTO categories (slug, position, icon, visible, names)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+categoryColumns, c.Slug, c.Position, c.Icon, c.Visible, names))
+	return created, mapError(err)
+}
+
+// Update заменяет все поля категории c.ID.
+func (s *Service) Update(c models.Category) (models.Category, error) {
+	names, err := prepare(&c)
+	if err != nil {
+		return models.Category{}, err
+	}
+	updated, err := scanCategory(s.db.QueryRow(`
+		UPDATE categories
+		SET slug = $1, position = $2, icon = $3, visible = $4, names = $5
+		WHERE id = $6
+		RETURNING `+categoryColumns, c.Slug, c.Position, c.Icon, c.Visible, names, c.ID))
+	return updated, mapError(err)
+}
+
+// Delete удаляет пустую категорию.
+func (s *Service) Delete(id int) error {
+	res, err := s.db.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return mapError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// prepare нормализует категорию перед записью и возвращает названия в JSON.
+func prepare(c *models.Category) ([]byte, error) {
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	names := make(map[string]string, len(c.Names))
+	for locale, name := range c.Names {
+		locale, name = strings.ToLower(strings.TrimSpace(locale)), strings.TrimSpace(name)
+		if locale != "" && name != "" {
+			names[locale] = name
+		}
+	}
+	c.Names = names
+	if !slugPattern.MatchString(c.Slug) || names[models.DefaultLocale] == "" {
+		return nil, ErrInvalid
+	}
+	return json.Marshal(names)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCategory(row scanner) (models.Category, error) {
+	var (
+		c     models.Category
+		names []byte
+	)
+	if err := row.Scan(&c.ID, &c.Slug, &c.Position, &c.Icon, &c.Visible, &names); err != nil {
+		return models.Category{}, err
+	}
+	if err := json.Unmarshal(names, &c.Names); err != nil {
+		return models.Category{}, err
+	}
+	return c, nil
+}
+
+// mapError переводит ошибки ограничений таблицы в ошибки пакета.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == uniqueViolation:
+		return ErrSlugTaken
+	case errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation:
+		return ErrInUse
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/auth"
+)
+
+// supportChatPrefix prefixes the personal support chat of every guest: "support:42".
+const supportChatPrefix = "support:"
+
+// SupportChatID returns the ID of the guest's personal support chat.
+func SupportChatID(guestID int) string {
+	return supportChatPrefix + strconv.Itoa(guestID)
+}
+
+// CanJoin reports whether the user may read and write chat chatID.
+// Guests may only use their own support chat; operators and admins may join any chat.
+func CanJoin(id auth.Identity, chatID string) bool {
+	if id.Can(auth.PermChatAdmin) {
+		return true
+	}
+	return chatID == SupportChatID(id.GuestID)
+}
+
+// participates reports whether joining chatID makes the user its participant,
+// who gets unread counts and notifications for it. Staff opening a guest's
+// support chat do not: its participants are the guest and the operator
+// assigned to its ticket (see ticketChanged).
+func participates(id auth.Identity, chatID string) bool {
+	return !strings.HasPrefix(chatID, supportChatPrefix) || chatID == SupportChatID(id.GuestID)
+}
+
+// AllowOrigins restricts which Origin headers may open WebSocket connections.
+// An empty list allows only same-host origins; "*" allows every origin.
+// It must be called before the server starts accepting connections.
+func AllowOrigins(origins []string) {
+	allowed := make(map[string]bool, len(origins))
+	for _, o := range origins {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			allowed[strings.ToLower(o)] = true
+		}
+	}
+	upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] {
+			return true // Non-browser clients do not send Origin.
+		}
+		if allowed[strings.ToLower(origin)] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && len(allowed) == 0 && strings.EqualFold(u.Host, r.Host)
+	}
+}
@@ -0,0 +1,125 @@
+package chat
+
+import (
+	"html"
+	"strings"
+	"time"
+)
+
+// Archive gives operators access to past conversations.
+type Archive interface {
+	// Search returns messages matching q, best matches first.
+	Search(q SearchQuery) ([]SearchResult, error)
+	// Transcript returns every message of the chat in chronological order,
+	// including the content of deleted messages.
+	Transcript(chatID string) ([]Message, error)
+}
+
+// SearchQuery filters messages. Zero fields do not filter.
+type SearchQuery struct {
+	Text        string    // Web search syntax: words, "a phrase", -excluded, or.
+	Participant string    // Only chats the guest now using this username took part in.
+	ChatID      string    // Only this chat.
+	From, To    time.Time // Sent in [From, To).
+	Limit       int
+	Offset      int
+}
+
+// SearchResult is a message found by Search.
+type SearchResult struct {
+	Message  Message `json:"message"`
+	Headline string  `json:"headline,omitempty"` // HTML: escaped text with matches in <mark>; only for text queries.
+	Rank     float64 `json:"rank,omitempty"`
+}
+
+// Headline markers requested from ts_headline. Search removes them from the
+// text passed to ts_headline, so the headline is escaped first and the
+// markers are then replaced with tags.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// headlineOptions are the ts_headline options for search results.
+const headlineOptions = `StartSel="` + headlineStart + `", StopSel="` + headlineStop + `", MaxWords=35, MinWords=15, MaxFragments=2`
+
+// headlineHTML turns a ts_headline result into safe HTML.
+func headlineHTML(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(s)
+}
+
+// Search finds messages with Postgres full-text search over the Russian and
+// English configurations (see migration 0013_chat_search).
+func (s *PostgresStore) Search(q SearchQuery) ([]SearchResult, error) {
+	var from, to int64
+	if !q.From.IsZero() {
+		from = q.From.UnixMilli()
+	}
+	if !q.To.IsZero() {
+		to = q.To.UnixMilli()
+	}
+	rows, err := s.db.Query(`
+		WITH q AS (
+			SELECT websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+		)
+		SELECT m.id, m.chat_id, m.sender, m.sender_id, m.kind, m.text, m.sent_at, m.edited_at, m.deleted_at, m.deleted_by, -- messageColumns
+		       CASE WHEN $1 = '' THEN '' ELSE ts_headline('russian', translate(m.text, $9, ''), q.query, $8) END,
+		       CASE WHEN $1 = '' THEN 0 ELSE ts_rank(m.search, q.query) END AS rank
+		FROM chat_messages m, q
+		WHERE ($1 = '' OR m.search @@ q.query)
+		  AND ($2 = '' OR m.chat_id = $2)
+		  AND ($3 = '' OR EXISTS (
+		      SELECT 1 FROM chat_participants p JOIN guests g ON g.id = p.guest_id
+		      WHERE p.chat_id = m.chat_id AND g.username = $3))
+		  AND ($4::bigint = 0 OR m.sent_at >= $4)
+		  AND ($5::bigint = 0 OR m.sent_at < $5)
+		ORDER BY rank DESC, m.id DESC
+		LIMIT $6 OFFSET $7`,
+		strings.TrimSpace(q.Text), q.ChatID, q.Participant, from, to, q.Limit, q.Offset, headlineOptions, headlineStart+headlineStop)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	results := []SearchResult{}
+	for rows.Next() {
+		var (
+			r   SearchResult
+			err error
+		)
+		if r.Message, err = scanMessage(rows, &r.Headline, &r.Rank); err != nil {
+			return nil, err
+		}
+		r.Headline = headlineHTML(r.Headline)
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// Transcript returns the whole conversation with attachments.
+func (s *PostgresStore) Transcript(chatID string) ([]Message, error) {
+	messages, err := s.queryMessages(`
+		SELECT `+messageColumns+` FROM chat_messages WHERE chat_id = $1 ORDER BY id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(messages))
+	for i, m := range messages {
+		index[m.ID] = i
+	}
+
+	attachments, err := queryAttachments(s.db, `
+		SELECT `+attachmentColumns+`
+		FROM chat_attachments
+		WHERE chat_id = $1 AND message_id IS NOT NULL
+		ORDER BY message_id, id`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		if i, ok := index[a.MessageID]; ok {
+			messages[i].Attachments = append(messages[i].Attachments, a)
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/media"
+	"go-robot/internal/storage"
+)
+
+const (
+	// DefaultMaxAttachmentSize is the upload limit unless configured otherwise.
+	DefaultMaxAttachmentSize = 10 << 20
+	// maxAttachmentsPerMessage limits attachment_ids of one message.
+	maxAttachmentsPerMessage = 10
+	// thumbnailSize is the longest side of generated thumbnails, in pixels.
+	thumbnailSize = 320
+	// maxPendingAttachments limits the uploads of one user not yet sent in a message.
+	maxPendingAttachments = 2 * maxAttachmentsPerMessage
+	// unsentAttachmentTTL is how long an upload may wait to be sent before it is deleted.
+	unsentAttachmentTTL = 24 * time.Hour
+	// cleanupInterval is how often unsent uploads are checked for expiry.
+	cleanupInterval = time.Hour
+)
+
+var (
+	// ErrAttachmentNotFound is returned for unknown attachment IDs.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentUnavailable is returned when a message refers to an attachment
+	// of another chat or user, or one already sent in another message.
+	ErrAttachmentUnavailable = errors.New("attachment is not available for this message")
+	// ErrAttachmentTooLarge is returned for uploads over the size limit.
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	// ErrTooManyPending is returned for uploads of a user who already has
+	// maxPendingAttachments uploads not sent in a message.
+	ErrTooManyPending = errors.New("too many unsent attachments")
+)
+
+// Attachment is an uploaded file referenced by a message.
+type Attachment struct {
+	ID           string `json:"id"`
+	ChatID       string `json:"chat_id"`
+	Uploader     string `json:"uploader"`             // Username at the time of upload.
+	UploaderID   int    `json:"uploader_id"`          // Guest ID; only the uploader may send the attachment.
+	MessageID    string `json:"message_id,omitempty"` // Empty until sent in a message.
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	CreatedAt    int64  `json:"created_at"` // Upload time, unix ms.
+	StorageKey   string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// withURLs fills the download URLs served by the attachments handler.
+func (a Attachment) withURLs() Attachment {
+	a.URL = "/attachments/" + a.ID
+	a.ThumbnailURL = "/attachments/" + a.ID + "/thumbnail"
+	return a
+}
+
+// Attachments validates uploads, stores the files in a storage.Storage and
+// their metadata in the chat Store. Uploads not sent in a message within
+// unsentAttachmentTTL are deleted.
+type Attachments struct {
+	store   Store
+	files   storage.Storage
+	maxSize int64
+}
+
+// NewAttachments creates Attachments; maxSize <= 0 means DefaultMaxAttachmentSize.
+func NewAttachments(store Store, files storage.Storage, maxSize int64) *Attachments {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxAttachmentSize
+	}
+	s := &Attachments{store: store, files: files, maxSize: maxSize}
+	go s.cleanupLoop()
+	return s
+}
+
+// MaxSize returns the upload limit in bytes.
+func (s *Attachments) MaxSize() int64 {
+	return s.maxSize
+}
+
+// Upload validates an image read from r, stores it with a thumbnail and
+// records it as not yet sent. The content type is detected from the data.
+func (s *Attachments) Upload(chatID string, uploader auth.Identity, fileName string, r io.Reader) (Attachment, error) {
+	// Checked before the upload is read and decoded; concurrent uploads of
+	// one user may still exceed the limit slightly.
+	pending, err := s.store.PendingAttachments(uploader.GuestID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if pending >= maxPendingAttachments {
+		return Attachment{}, fmt.Errorf("%w: send or wait for the %d pending ones", ErrTooManyPending, pending)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, s.maxSize+1))
+	if err != nil {
+		return Attachment{}, err
+	}
+	if int64(len(data)) > s.maxSize {
+		return Attachment{}, fmt.Errorf("%w: limit is %d bytes", ErrAttachmentTooLarge, s.maxSize)
+	}
+	img, err := media.Inspect(data)
+	if err != nil {
+		return Attachment{}, err
+	}
+	thumb, err := media.Thumbnail(data, thumbnailSize)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("%w: %v", media.ErrUnsupportedType, err)
+	}
+
+	id := NewMessageID()
+	a := Attachment{
+		ID:           id,
+		ChatID:       chatID,
+		Uploader:     uploader.Username,
+		UploaderID:   uploader.GuestID,
+		FileName:     path.Base(fileName),
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		CreatedAt:    time.Now().UnixMilli(),
+		StorageKey:   "attachments/" + id + img.Extension,
+		ThumbnailKey: "attachments/" + id + "_thumb.jpg",
+	}
+	if err := s.files.Put(a.StorageKey, bytes.NewReader(data), a.ContentType); err != nil {
+		return Attachment{}, err
+	}
+	if err := s.files.Put(a.ThumbnailKey, bytes.NewReader(thumb), "image/jpeg"); err != nil {
+		s.files.Delete(a.StorageKey)
+		return Attachment{}, err
+	}
+	if err := s.store.SaveAttachment(&a); err != nil {
+		s.files.Delete(a.StorageKey)
+		s.files.Delete(a.ThumbnailKey)
+		return Attachment{}, err
+	}
+	return a.withURLs(), nil
+}
+
+// cleanupLoop deletes expired unsent uploads until the process exits.
+func (s *Attachments) cleanupLoop() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.deleteUnsent(time.Now().Add(-unsentAttachmentTTL))
+	}
+}
+
+// deleteUnsent deletes uploads created before the time and never sent, with their files.
+func (s *Attachments) deleteUnsent(before time.Time) {
+	deleted, err := s.store.DeleteUnsentAttachments(before.UnixMilli())
+	if err != nil {
+		log.Printf("Error deleting unsent attachments: %v", err)
+		return
+	}
+	for _, a := range deleted {
+		for _, key := range []string{a.StorageKey, a.ThumbnailKey} {
+			if err := s.files.Delete(key); err != nil {
+				log.Printf("Error deleting file %s of unsent attachment %s: %v", key, a.ID, err)
+			}
+		}
+	}
+	if len(deleted) > 0 {
+		log.Printf("Deleted %d unsent attachments", len(deleted))
+	}
+}
+
+// Get returns attachment metadata.
+func (s *Attachments) Get(id string) (Attachment, error) {
+	a, err := s.store.Attachment(id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return a.withURLs(), nil
+}
+
+// Open returns the file of the attachment, or its thumbnail.
+func (s *Attachments) Open(a Attachment, thumbnail bool) (io.ReadCloser, error) {
+	key := a.StorageKey
+	if thumbnail {
+		key = a.ThumbnailKey
+	}
+	return s.files.Open(key)
+}
+
+// resolve loads the attachments of a message being sent by the guest senderID
+// to chatID and checks that they are unsent uploads of the same guest and chat.
+func (s *Attachments) resolve(chatID string, senderID int, ids []string) ([]Attachment, error) {
+	if len(ids) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("%w: at most %d per message", ErrAttachmentUnavailable, maxAttachmentsPerMessage)
+	}
+	list := make([]Attachment, 0, len(ids))
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		a, err := s.Get(id)
+		if errors.Is(err, ErrAttachmentNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrAttachmentUnavailable, id)
+		}
+		if err != nil {
+			log.Printf("Error loading attachment %s: %v", id, err)
+			return nil, err
+		}
+		if a.ChatID != chatID || a.UploaderID != senderID || a.MessageID != "" {
+			return nil, fmt.Errorf("%w: %s", ErrAttachmentUnavailable, id)
+		}
+		list = append(list, a)
+	}
+	return list, nil
+}
+
+// UseAttachments enables attachments in messages sent through the hub.
+func (hub *ChatHub) UseAttachments(files *Attachments) {
+	hub.files = files
+}
+
+// attachments resolves the attachment IDs of a message being sent.
+func (hub *ChatHub) attachments(chatID string, senderID int, ids []string) ([]Attachment, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if hub.files == nil {
+		return nil, fmt.Errorf("%w: attachments are disabled", ErrAttachmentUnavailable)
+	}
+	return hub.files.resolve(chatID, senderID, ids)
+}
@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/support"
+)
+
+// Bot is an automated participant of support chats. It answers a guest until
+// the guest asks for a human (or the bot gives up); then a support ticket is
+// opened and the bot stays silent while the ticket is open.
+type Bot interface {
+	// Name is the sender name of the bot's messages.
+	Name() string
+	// Reply answers a guest message. handoff asks for a human operator.
+	// Without operators there is nobody to hand off to: the reply must not
+	// promise one and handoff is ignored. An empty reply sends nothing.
+	Reply(guest auth.Identity, text string, operators bool) (reply string, handoff bool)
+}
+
+// botQueue holds saved guest messages for the bot by chat ID. A chat with
+// an entry has a worker (see runBot) answering its messages in order.
+type botQueue struct {
+	mu      sync.Mutex
+	pending map[string][]botTask
+}
+
+type botTask struct {
+	identity auth.Identity
+	msg      Message
+}
+
+// UseBot lets b answer guests in their support chats. With a bot, tickets
+// are opened on handoff instead of on every guest message.
+func (hub *ChatHub) UseBot(b Bot) {
+	hub.bot = b
+}
+
+// queueBotReply lets the bot answer a saved message if it was written by a
+// guest in their own support chat. Replies in a chat follow the order of
+// the guest's messages. It does not block.
+func (hub *ChatHub) queueBotReply(identity auth.Identity, msg Message) {
+	if hub.bot == nil || msg.ChatID != SupportChatID(identity.GuestID) {
+		return
+	}
+	q := &hub.botQueue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string][]botTask)
+	}
+	_, busy := q.pending[msg.ChatID]
+	q.pending[msg.ChatID] = append(q.pending[msg.ChatID], botTask{identity, msg})
+	if !busy {
+		go hub.runBot(msg.ChatID)
+	}
+}
+
+// runBot answers the queued messages of a chat one by one until none are left.
+func (hub *ChatHub) runBot(chatID string) {
+	q := &hub.botQueue
+	for {
+		q.mu.Lock()
+		tasks := q.pending[chatID]
+		if len(tasks) == 0 {
+			delete(q.pending, chatID)
+			q.mu.Unlock()
+			return
+		}
+		q.pending[chatID] = tasks[1:]
+		q.mu.Unlock()
+		hub.botReply(tasks[0].identity, tasks[0].msg)
+	}
+}
+
+// botReply lets the bot answer msg unless an operator is involved.
+func (hub *ChatHub) botReply(identity auth.Identity, msg Message) {
+	if hub.support != nil {
+		_, err := hub.support.Current(msg.ChatID)
+		if err == nil {
+			return // A ticket is open: operators handle the chat.
+		}
+		if !errors.Is(err, support.ErrNotFound) {
+			log.Printf("Error checking support ticket of chat %s: %v", msg.ChatID, err)
+			return
+		}
+	}
+
+	reply, handoff := hub.bot.Reply(identity, msg.Text, hub.support != nil)
+	if reply != "" {
+		hub.Post(Message{ChatID: msg.ChatID, Sender: hub.bot.Name(), Kind: KindBot, Text: reply})
+	}
+	if handoff && hub.support != nil {
+		if _, err := hub.support.Open(identity.GuestID, msg.ChatID); err != nil {
+			log.Printf("Error opening support ticket for chat %s: %v", msg.ChatID, err)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package chat
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Event kinds relayed between instances.
+const (
+	// EventFrame carries a frame for every client of ChatID (a message or a read update),
+	// or only for the clients of the guest GuestID if it is set.
+	EventFrame = "frame"
+	// EventPresence carries the presence of users connected to ChatID on the Origin instance.
+	EventPresence = "presence"
+	// EventKick disconnects the clients of the guest GuestID from ChatID, e.g. after a ban.
+	EventKick = "kick"
+	// EventResync is emitted locally by a broker after it lost events
+	// (e.g. on reconnect); hubs respond by announcing their presence again.
+	EventResync = "resync"
+)
+
+// Event is a unit of chat fan-out between instances.
+type Event struct {
+	Kind     string          `json:"kind"`
+	Origin   string          `json:"origin"` // Instance that published the event.
+	ChatID   string          `json:"chat_id,omitempty"`
+	GuestID  int             `json:"guest_id,omitempty"`
+	Frame    json.RawMessage `json:"frame,omitempty"`
+	Presence []UserPresence  `json:"presence,omitempty"`
+}
+
+// Broker relays events between ChatHub instances. Subscribers receive every
+// published event, including their own, and filter by Origin.
+type Broker interface {
+	Publish(ev Event) error
+	Subscribe(fn func(Event))
+	Close() error
+}
+
+// MemoryBroker delivers events within one process. It is the default for
+// single-instance deployments.
+type MemoryBroker struct {
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewMemoryBroker creates a MemoryBroker.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+// Publish calls every subscriber synchronously.
+func (b *MemoryBroker) Publish(ev Event) error {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(ev)
+	}
+	return nil
+}
+
+// Subscribe registers fn for all subsequent events.
+func (b *MemoryBroker) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, fn)
+	b.mu.Unlock()
+}
+
+// Close is a no-op.
+func (b *MemoryBroker) Close() error {
+	return nil
+}
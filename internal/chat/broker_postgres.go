@@ -0,0 +1,150 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// brokerChannel is the Postgres NOTIFY channel used for chat events.
+const brokerChannel = "chat_events"
+
+// maxNotifyPayload is just under the 8000-byte limit Postgres puts on NOTIFY payloads.
+const maxNotifyPayload = 7900
+
+// storedEventPrefix marks a notification that carries the id of a
+// chat_broker_events row instead of the event itself.
+const storedEventPrefix = "ref:"
+
+// storedEventTTL is how long stored events are kept for the listeners to read.
+const storedEventTTL = 5 * time.Minute
+
+// PostgresBroker relays events between instances through LISTEN/NOTIFY.
+// Notifications are sent over the shared pool; a dedicated pq.Listener
+// connection receives them and reconnects on failure. Events larger than a
+// NOTIFY payload are stored in chat_broker_events and only their id is sent.
+type PostgresBroker struct {
+	db       *sql.DB
+	listener *pq.Listener
+
+	mu          sync.RWMutex
+	subscribers []func(Event)
+}
+
+// NewPostgresBroker starts listening on the chat channel. dsn is used for the
+// listener connection, which cannot come from the database/sql pool.
+func NewPostgresBroker(db *sql.DB, dsn string) (*PostgresBroker, error) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Chat broker listener event %d: %v", ev, err)
+		}
+	})
+	if err := listener.Listen(brokerChannel); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("listen %s: %w", brokerChannel, err)
+	}
+	b := &PostgresBroker{db: db, listener: listener}
+	go b.listen()
+	return b, nil
+}
+
+// Publish sends ev to every instance, including this one.
+func (b *PostgresBroker) Publish(ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if len(payload) <= maxNotifyPayload {
+		_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, brokerChannel, string(payload))
+		return err
+	}
+	// The row and the notification are committed together, so the row is
+	// visible to listeners by the time they receive its id.
+	_, err = b.db.Exec(`
+		WITH ev AS (INSERT INTO chat_broker_events (payload) VALUES ($2) RETURNING id)
+		SELECT pg_notify($1, $3::text || id) FROM ev`, brokerChannel, string(payload), storedEventPrefix)
+	return err
+}
+
+// load returns the event a notification carries, reading it from
+// chat_broker_events if it was stored there.
+func (b *PostgresBroker) load(extra string) (Event, error) {
+	var ev Event
+	payload := extra
+	if ref, ok := strings.CutPrefix(extra, storedEventPrefix); ok {
+		id, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return ev, fmt.Errorf("bad stored event id %q", ref)
+		}
+		if err := b.db.QueryRow(`SELECT payload FROM chat_broker_events WHERE id = $1`, id).Scan(&payload); err != nil {
+			return ev, fmt.Errorf("load stored event %d: %w", id, err)
+		}
+	}
+	err := json.Unmarshal([]byte(payload), &ev)
+	return ev, err
+}
+
+// purge deletes stored events every listener has had time to read.
+func (b *PostgresBroker) purge() {
+	_, err := b.db.Exec(`DELETE FROM chat_broker_events WHERE created_at < NOW() - $1::float8 * INTERVAL '1 second'`, storedEventTTL.Seconds())
+	if err != nil {
+		log.Printf("Error purging stored chat broker events: %v", err)
+	}
+}
+
+// Subscribe registers fn for all subsequent events.
+func (b *PostgresBroker) Subscribe(fn func(Event)) {
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, fn)
+	b.mu.Unlock()
+}
+
+// Close stops the listener.
+func (b *PostgresBroker) Close() error {
+	return b.listener.Close()
+}
+
+func (b *PostgresBroker) listen() {
+	purge := time.NewTicker(storedEventTTL)
+	defer purge.Stop()
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// The listener reconnected; anything sent meanwhile is lost.
+				b.dispatch(Event{Kind: EventResync})
+				continue
+			}
+			ev, err := b.load(n.Extra)
+			if err != nil {
+				log.Printf("Error parsing chat broker event: %v", err)
+				continue
+			}
+			b.dispatch(ev)
+		case <-time.After(90 * time.Second):
+			// Detect a silently dropped connection.
+			go b.listener.Ping()
+		case <-purge.C:
+			go b.purge()
+		}
+	}
+}
+
+func (b *PostgresBroker) dispatch(ev Event) {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(ev)
+	}
+}
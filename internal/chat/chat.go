@@ -1,311 +1,583 @@
 package chat
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"go-robot/internal/auth"
+	"go-robot/internal/support"
+
 	"github.com/gorilla/websocket"
 )
 
-// Message represents a chat message with a read flag.
+// Message represents a chat message with its attachments and its delivery
+// and read receipts. Deleted messages keep their place in the history, but
+// are sent to clients without text and attachments.
 type Message struct {
-	ChatID    string `json:"chat_id"`
-	Sender    string `json:"sender"`
-	Text      string `json:"text"`
-	Timestamp int64  `json:"timestamp"`
-	Read      bool   `json:"read"`
+	ID          string       `json:"id"` // Assigned by the server, see NewMessageID.
+	ChatID      string       `json:"chat_id"`
+	Sender      string       `json:"sender"`              // Username at the time of sending.
+	SenderID    int          `json:"sender_id,omitempty"` // Guest ID of the sender; 0 for KindSystem and KindBot.
+	Kind        string       `json:"kind"`                // KindUser, KindSystem or KindBot.
+	Text        string       `json:"text"`                // May be empty if the message has attachments.
+	Timestamp   int64        `json:"timestamp"`
+	EditedAt    int64        `json:"edited_at,omitempty"`  // Last edit, unix ms.
+	DeletedAt   int64        `json:"deleted_at,omitempty"` // Unix ms.
+	DeletedBy   string       `json:"deleted_by,omitempty"` // The sender or an operator.
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Receipts    []Receipt    `json:"receipts,omitempty"`
+}
+
+// Message kinds. Server and bot messages are told apart by kind, never by
+// the sender name.
+const (
+	KindUser   = "user"   // Sent by a chat participant.
+	KindSystem = "system" // A notice of the server, see Post.
+	KindBot    = "bot"    // A reply of the bot.
+)
+
+// Receipt records when a recipient received and read a message.
+// Times are unix milliseconds; 0 means not yet.
+type Receipt struct {
+	MessageID   string `json:"message_id"`
+	GuestID     int    `json:"guest_id"`
+	Username    string `json:"username"` // Current username of the recipient.
+	DeliveredAt int64  `json:"delivered_at,omitempty"`
+	ReadAt      int64  `json:"read_at,omitempty"`
 }
 
-// StatusMessage represents the chat status with online users.
+// StatusMessage represents the presence of users in the chat.
 type StatusMessage struct {
-	Type        string   `json:"type"`
-	OnlineUsers []string `json:"onlineUsers"`
+	Users []UserPresence `json:"users"`
 }
 
 // ChatState represents the current state of a chat.
 type ChatState struct {
-	Messages    []Message `json:"messages"`
-	OnlineUsers []string  `json:"onlineUsers"`
+	// Messages are the latest HistoryLimit messages and any older messages
+	// not yet delivered to the user, in chronological order.
+	Messages []Message      `json:"messages"`
+	Users    []UserPresence `json:"users"`
+	Unread   int            `json:"unread"` // Messages the connecting user has not read.
+}
+
+// ReceiptUpdate is sent by a client to acknowledge messages by ID.
+type ReceiptUpdate struct {
+	Status     string   `json:"status"` // "delivered" or "read"
+	ChatID     string   `json:"chat_id,omitempty"`
+	MessageIDs []string `json:"message_ids"`
+}
+
+// ReceiptsMessage notifies the chat about new or changed receipts.
+type ReceiptsMessage struct {
+	ChatID   string    `json:"chat_id"`
+	Receipts []Receipt `json:"receipts"`
+}
+
+// inbound is a message waiting to be saved and delivered.
+type inbound struct {
+	msg  Message
+	from *client // Receives the ack or error; nil for server-generated messages.
+	ref  string  // Envelope ID of the client's frame.
 }
 
-// ReadStatusUpdate represents an update to a message's read status.
-type ReadStatusUpdate struct {
-	Update    string `json:"update"` // Expected value: "read"
-	ChatID    string `json:"chat_id"`
-	Timestamp int64  `json:"timestamp"`
-	Read      bool   `json:"read"`
+// outbox holds the messages waiting to be saved by chat ID. A chat with an
+// entry has a worker (see runOutbox) handling its messages in order.
+type outbox struct {
+	mu      sync.Mutex
+	pending map[string][]inbound
 }
 
-// ChatHub manages chats and message broadcasting.
+// maxReceiptBatch limits the number of message IDs in one ReceiptUpdate.
+const maxReceiptBatch = 500
+
+// ChatHub manages chats and message broadcasting. Clients connected to other
+// instances are reached through the broker.
 type ChatHub struct {
-	chats     map[string]map[*websocket.Conn]string // Stores username instead of just client_id.
-	messages  map[string][]Message                  // Message history by chatID.
-	broadcast chan Message                          // Channel for new messages.
-	mu        sync.Mutex                            // Synchronization for concurrent access.
+	chats       map[string]map[*client]*device       // Connected clients by chatID.
+	remote      map[string]map[string]remotePresence // Users on other instances by chatID and instance.
+	departed    map[string]map[int]UserPresence      // Users who left a chat by guest ID, by chatID.
+	status      map[string]string                    // Last status broadcast by chatID, see presenceKey.
+	store       Store                                // Persistent message history.
+	broker      Broker                               // Fan-out to other instances.
+	instance    string                               // Origin of events published by this hub.
+	outbox      outbox                               // Messages waiting to be saved, by chat.
+	support     *support.Service                     // Ticket queue for support chats; nil disables it.
+	bot         Bot                                  // Answers guests before handoff; nil disables it.
+	botQueue    botQueue                             // Guest messages waiting for the bot.
+	files       *Attachments                         // Uploaded attachments; nil disables them.
+	filter      *Filter                              // Checks texts before broadcast; nil disables it.
+	notifier    Notifier                             // Notifies about unread messages; nil disables it.
+	notifyAfter time.Duration                        // How long a message stays unread before notifying.
+	limits      RateLimits                           // Flood protection of client frames.
+	userBuckets userBuckets                          // Per-user rate limit state.
+	mu          sync.Mutex                           // Guards chats, remote, departed and status. Never held while writing to a socket.
 }
 
-// NewChatHub creates a new ChatHub instance.
-func NewChatHub() *ChatHub {
-	return &ChatHub{
-		chats:     make(map[string]map[*websocket.Conn]string),
-		messages:  make(map[string][]Message),
-		broadcast: make(chan Message),
+// NewChatHub creates a new ChatHub instance backed by store and subscribes it to broker.
+func NewChatHub(store Store, broker Broker) *ChatHub {
+	hub := &ChatHub{
+		chats:    make(map[string]map[*client]*device),
+		remote:   make(map[string]map[string]remotePresence),
+		departed: make(map[string]map[int]UserPresence),
+		status:   make(map[string]string),
+		store:    store,
+		broker:   broker,
+		instance: newInstanceID(),
+		limits:   DefaultRateLimits,
 	}
+	hub.userBuckets.buckets = make(map[int]*[limitClasses]bucket)
+	broker.Subscribe(hub.receive)
+	return hub
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
 
-// Run processes incoming messages and broadcasts them to all clients.
+// Run starts the background loops of the hub: presence and, with a
+// notifier, notifications about unread messages. It does not block.
 func (hub *ChatHub) Run() {
-	for msg := range hub.broadcast {
-		hub.mu.Lock()
-		clients, exists := hub.chats[msg.ChatID]
-		if !exists {
-			hub.mu.Unlock()
-			continue
-		}
+	go hub.presenceLoop()
+	if hub.notifier != nil {
+		go hub.notifyLoop()
+	}
+}
 
-		// Set timestamp if not provided.
-		if msg.Timestamp == 0 {
-			msg.Timestamp = time.Now().UnixMilli()
-		}
-		// Mark message as read if there are 2 or more clients (e.g., client and admin).
-		if len(clients) >= 2 {
-			msg.Read = true
-		} else {
-			msg.Read = false
+// submit queues a message to be saved and delivered. Messages of a chat are
+// handled one by one by a worker (see runOutbox), so a slow store delays
+// only that chat, and message IDs follow the order in which messages are
+// saved and delivered. It does not block.
+func (hub *ChatHub) submit(in inbound) {
+	q := &hub.outbox
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending == nil {
+		q.pending = make(map[string][]inbound)
+	}
+	chatID := in.msg.ChatID
+	_, busy := q.pending[chatID]
+	q.pending[chatID] = append(q.pending[chatID], in)
+	if !busy {
+		go hub.runOutbox(chatID)
+	}
+}
+
+// runOutbox saves and delivers the queued messages of a chat until none are left.
+func (hub *ChatHub) runOutbox(chatID string) {
+	q := &hub.outbox
+	for {
+		q.mu.Lock()
+		queued := q.pending[chatID]
+		if len(queued) == 0 {
+			delete(q.pending, chatID)
+			q.mu.Unlock()
+			return
 		}
-		// Save message to history.
-		hub.messages[msg.ChatID] = append(hub.messages[msg.ChatID], msg)
+		q.pending[chatID] = queued[1:]
+		q.mu.Unlock()
+		hub.deliver(queued[0])
+	}
+}
 
-		// Send message to all clients.
-		data, err := json.Marshal(msg)
-		if err != nil {
-			log.Printf("Error marshaling message: %v", err)
-			hub.mu.Unlock()
-			continue
+// deliver saves a message and sends it to the chat on all instances. The ID
+// and timestamp are assigned here, right before saving.
+func (hub *ChatHub) deliver(in inbound) {
+	msg := in.msg
+	msg.ID = NewMessageID()
+	msg.Timestamp = time.Now().UnixMilli()
+	// Save message to history before delivery, so it survives a restart.
+	if err := hub.store.Save(&msg); errors.Is(err, ErrAttachmentUnavailable) {
+		// Another message took the attachment after it was checked.
+		if in.from != nil {
+			in.from.enqueue(errorFrame(in.ref, ErrCodeInvalidPayload, err.Error()))
 		}
-		for client := range clients {
-			if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Printf("Error sending to client in chat %s: %v", msg.ChatID, err)
-				client.Close()
-				delete(clients, client)
-			}
+		return
+	} else if err != nil {
+		log.Printf("Error saving message in chat %s: %v", msg.ChatID, err)
+		if in.from != nil {
+			in.from.enqueue(errorFrame(in.ref, ErrCodeInternal, "message was not saved"))
+		}
+		return
+	}
+	if in.from != nil {
+		if ack, err := encodeFrame(FrameAck, in.ref, Ack{MessageID: msg.ID, Timestamp: msg.Timestamp}); err == nil {
+			in.from.enqueue(ack)
 		}
-
-		hub.broadcastStatus(msg.ChatID)
-		hub.mu.Unlock()
 	}
-}
 
-// broadcastStatus sends the status (list of online users) to the specified chat.
-func (hub *ChatHub) broadcastStatus(chatID string) {
-	clients, exists := hub.chats[chatID]
-	if !exists {
+	// Send message to all clients.
+	data, err := encodeFrame(FrameMessage, "", msg)
+	if err != nil {
+		log.Printf("Error marshaling message: %v", err)
 		return
 	}
-	onlineUsers := make([]string, 0, len(clients))
-	for _, username := range clients {
-		onlineUsers = append(onlineUsers, username)
+	hub.mu.Lock()
+	hub.sendToChat(msg.ChatID, data)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventFrame, ChatID: msg.ChatID, Frame: data})
+	if in.from != nil {
+		hub.openTicket(in.from.identity, msg.ChatID)
+		hub.queueBotReply(in.from.identity, msg)
+	}
+}
+
+// publish relays ev to other instances. Must be called without hub.mu held,
+// since an in-process broker delivers synchronously.
+func (hub *ChatHub) publish(ev Event) {
+	ev.Origin = hub.instance
+	if err := hub.broker.Publish(ev); err != nil {
+		log.Printf("Error publishing %s event for chat %s: %v", ev.Kind, ev.ChatID, err)
 	}
-	statusMsg := StatusMessage{
-		Type:        "status",
-		OnlineUsers: onlineUsers,
+}
+
+// receive handles an event from the broker.
+func (hub *ChatHub) receive(ev Event) {
+	if ev.Kind == EventResync {
+		hub.announceAll()
+		return
 	}
-	data, err := json.Marshal(statusMsg)
-	if err != nil {
-		log.Printf("Error marshaling status: %v", err)
+	if ev.Origin == hub.instance {
 		return
 	}
-	for client := range clients {
-		if err := client.WriteMessage(websocket.TextMessage, data); err != nil {
-			log.Printf("Error sending status in chat %s: %v", chatID, err)
-			client.Close()
-			delete(clients, client)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	switch ev.Kind {
+	case EventFrame:
+		if ev.GuestID != 0 {
+			hub.sendToUser(ev.ChatID, ev.GuestID, ev.Frame)
+		} else {
+			hub.sendToChat(ev.ChatID, ev.Frame)
 		}
+	case EventKick:
+		hub.kick(ev.ChatID, ev.GuestID)
+	case EventPresence:
+		hub.receivePresence(ev)
 	}
 }
 
-// sendChatState sends the current chat state (messages and online users) to a new client.
-func (hub *ChatHub) sendChatState(conn *websocket.Conn, chatID string) {
-	clients := hub.chats[chatID]
-	onlineUsers := make([]string, 0, len(clients))
-	for _, username := range clients {
-		onlineUsers = append(onlineUsers, username)
+// sendToChat queues data for every client of the chat. Must be called with hub.mu held;
+// queuing never blocks, and clients whose buffer is full are evicted.
+func (hub *ChatHub) sendToChat(chatID string, data []byte) {
+	for c := range hub.chats[chatID] {
+		c.enqueue(data)
 	}
+}
+
+// sendChatState sends the current chat state (messages and online users) to a new client,
+// followed by the unread summary of all its chats. The store is queried without holding hub.mu.
+func (hub *ChatHub) sendChatState(c *client, chatID string) {
+	history, err := hub.store.History(chatID, "", HistoryLimit)
+	if err != nil {
+		log.Printf("Error loading history of chat %s: %v", chatID, err)
+		history = []Message{}
+	}
+	// Messages that arrived while the user was away are queued until the
+	// client confirms their delivery with a receipt.
+	undelivered, err := hub.store.Undelivered(chatID, c.identity.GuestID, maxOfflineQueue)
+	if err != nil {
+		log.Printf("Error loading undelivered messages of %s in chat %s: %v", c.identity.Username, chatID, err)
+	}
+	history = mergeMessages(history, undelivered)
+	unread, err := hub.store.Unread(c.identity.GuestID)
+	if err != nil {
+		log.Printf("Error counting unread messages of %s: %v", c.identity.Username, err)
+	}
+	hub.mu.Lock()
 	state := ChatState{
-		Messages:    hub.messages[chatID],
-		OnlineUsers: onlineUsers,
+		Messages: history,
+		Users:    hub.presence(chatID),
+		Unread:   unread[chatID],
 	}
-	data, err := json.Marshal(state)
+	hub.mu.Unlock()
+	data, err := encodeFrame(FrameState, "", state)
 	if err != nil {
 		log.Printf("Error marshaling chat state %s: %v", chatID, err)
 		return
 	}
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		log.Printf("Error sending chat state %s: %v", chatID, err)
+	c.enqueue(data)
+	hub.sendUnreadSummary(c)
+}
+
+// handleReceipts records the receipts of c's user and notifies the chat.
+func (hub *ChatHub) handleReceipts(c *client, ref string, update ReceiptUpdate) {
+	if len(update.MessageIDs) > maxReceiptBatch {
+		c.enqueue(errorFrame(ref, ErrCodeInvalidPayload, "too many message ids"))
+		return
+	}
+	var (
+		changed []Receipt
+		err     error
+	)
+	switch update.Status {
+	case "delivered":
+		changed, err = hub.store.MarkDelivered(update.ChatID, c.identity.GuestID, update.MessageIDs)
+	case "read":
+		changed, err = hub.store.MarkRead(update.ChatID, c.identity.GuestID, update.MessageIDs)
+	default:
+		c.enqueue(errorFrame(ref, ErrCodeInvalidPayload, `status must be "delivered" or "read"`))
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording %s receipts in chat %s: %v", update.Status, update.ChatID, err)
+		c.enqueue(errorFrame(ref, ErrCodeInternal, "receipts were not saved"))
+		return
+	}
+	if ref != "" {
+		if ack, err := encodeFrame(FrameAck, ref, struct{}{}); err == nil {
+			c.enqueue(ack)
+		}
+	}
+	if len(changed) == 0 {
+		return
+	}
+	frames, err := receiptFrames(update.ChatID, changed)
+	if err != nil {
+		log.Printf("Error marshaling receipts: %v", err)
+		return
+	}
+	for _, data := range frames {
+		hub.mu.Lock()
+		hub.sendToChat(update.ChatID, data)
+		hub.mu.Unlock()
+		hub.publish(Event{Kind: EventFrame, ChatID: update.ChatID, Frame: data})
 	}
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true }, // Add Origin check for production.
+// maxReceiptFrame is the size limit of one receipts frame. It leaves room
+// for the broker event around the frame within a NOTIFY payload.
+const maxReceiptFrame = maxNotifyPayload - 512
+
+// receiptFrames encodes receipts as one or more receipts frames of at most
+// maxReceiptFrame bytes each.
+func receiptFrames(chatID string, receipts []Receipt) ([][]byte, error) {
+	data, err := encodeFrame(FrameReceipts, "", ReceiptsMessage{ChatID: chatID, Receipts: receipts})
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= maxReceiptFrame || len(receipts) == 1 {
+		return [][]byte{data}, nil
+	}
+	half := len(receipts) / 2
+	first, err := receiptFrames(chatID, receipts[:half])
+	if err != nil {
+		return nil, err
+	}
+	rest, err := receiptFrames(chatID, receipts[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, rest...), nil
 }
 
+// upgrader is shared by all hubs; its origin policy is set by AllowOrigins.
+// By default only same-host origins are accepted.
+var upgrader = websocket.Upgrader{}
+
 // ChatHandler handles WebSocket connections and message exchange.
+// Requires an authenticated identity in the request context: the sender name
+// is taken from the session, not from the query string.
+// Frames follow the negotiated subprotocol (see ProtocolV1).
 func (hub *ChatHub) ChatHandler(w http.ResponseWriter, r *http.Request) {
-	// Extract client_id and username.
-	clientID := r.URL.Query().Get("client_id")
-	username := r.URL.Query().Get("username")
-	if clientID == "" {
-		http.Error(w, "client_id is required", http.StatusBadRequest)
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
 		return
 	}
-	// Use clientID as username if not provided.
-	if username == "" {
-		username = clientID
-	}
-	// chat_id is optional at connection time.
+	clientID := strconv.Itoa(identity.GuestID)
+	username := identity.Username
+	// chat_id is optional at connection time; guests land in their support chat.
 	chatID := r.URL.Query().Get("chat_id")
 	if chatID == "" {
-		chatID = "global" // Use a default chatID if not specified.
+		chatID = SupportChatID(identity.GuestID)
+	}
+	if !CanJoin(identity, chatID) {
+		http.Error(w, "access to chat denied", http.StatusForbidden)
+		return
+	}
+	// Fail closed: a user whose ban could not be checked is not let in.
+	if err := hub.checkRestriction(chatID, identity.GuestID, RestrictBan); errors.Is(err, ErrBanned) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, "could not check chat restrictions", http.StatusInternalServerError)
+		return
 	}
 
-	ws, err := upgrader.Upgrade(w, r, nil)
+	protocol, ok := negotiateProtocol(r)
+	if !ok {
+		http.Error(w, "unsupported chat protocol, supported: "+strings.Join(protocols, ", "), http.StatusBadRequest)
+		return
+	}
+	var header http.Header
+	if len(websocket.Subprotocols(r)) > 0 {
+		// Echo the choice only if the client asked for one.
+		header = http.Header{"Sec-Websocket-Protocol": {protocol}}
+	}
+	ws, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
 		log.Printf("Error upgrading to WebSocket: %v", err)
 		return
 	}
 
+	if participates(identity, chatID) {
+		if err := hub.store.Join(chatID, identity); err != nil {
+			log.Printf("Error recording %s in chat %s: %v", username, chatID, err)
+		}
+	}
+
+	c := newClient(ws, identity)
+	// Queue the history first, so it precedes any message broadcast after joining.
+	hub.sendChatState(c, chatID)
 	hub.mu.Lock()
 	if _, exists := hub.chats[chatID]; !exists {
-		hub.chats[chatID] = make(map[*websocket.Conn]string)
-		hub.messages[chatID] = []Message{}
+		hub.chats[chatID] = make(map[*client]*device)
 	}
-	hub.chats[chatID][ws] = username
+	hub.chats[chatID][c] = &device{lastActive: time.Now()}
+	delete(hub.departed[chatID], identity.GuestID)
+	hub.broadcastStatus(chatID)
 	hub.mu.Unlock()
+	hub.announce(chatID)
 
-	log.Printf("Client %s (%s) connected to chat %s", clientID, username, chatID)
-	hub.sendChatState(ws, chatID)
-	hub.broadcastStatus(chatID)
+	log.Printf("Client %s (%s) connected to chat %s using %s", clientID, username, chatID, protocol)
 
-	go hub.pingLoop(ws, clientID, chatID)
+	defer func() {
+		c.close()
+		hub.mu.Lock()
+		hub.leave(c, chatID)
+		hub.mu.Unlock()
+		hub.announce(chatID)
+	}()
 
+	limiter := &connLimiter{}
 	for {
 		_, data, err := ws.ReadMessage()
 		if err != nil {
 			log.Printf("Client %s disconnected from chat %s: %v", clientID, chatID, err)
-			hub.mu.Lock()
-			delete(hub.chats[chatID], ws)
-			if len(hub.chats[chatID]) == 0 {
-				delete(hub.chats, chatID)
-				delete(hub.messages, chatID)
-			}
-			hub.broadcastStatus(chatID)
-			hub.mu.Unlock()
-			break
+			return
 		}
 
-		// Handle read status updates.
-		var potentialUpdate struct {
-			Update string `json:"update"`
-		}
-		if err := json.Unmarshal(data, &potentialUpdate); err == nil && potentialUpdate.Update == "read" {
-			var update ReadStatusUpdate
-			if err := json.Unmarshal(data, &update); err != nil {
-				log.Printf("Error parsing read status update: %v", err)
-				continue
+		var env Envelope
+		if err := json.Unmarshal(data, &env); err != nil || env.Type == "" {
+			if hub.allowFrame(c, chatID, limiter, "", "") {
+				c.enqueue(errorFrame("", ErrCodeBadFrame, "expected a JSON envelope with a type"))
 			}
-			hub.mu.Lock()
-			msgs := hub.messages[update.ChatID]
-			for i, m := range msgs {
-				if m.Timestamp == update.Timestamp {
-					hub.messages[update.ChatID][i].Read = update.Read
-					updatedMsg, err := json.Marshal(hub.messages[update.ChatID][i])
-					if err != nil {
-						log.Printf("Error marshaling updated message: %v", err)
-						continue
-					}
-					for client := range hub.chats[update.ChatID] {
-						if err := client.WriteMessage(websocket.TextMessage, updatedMsg); err != nil {
-							log.Printf("Error sending updated status: %v", err)
-							client.Close()
-							delete(hub.chats[update.ChatID], client)
-						}
-					}
-				}
-			}
-			hub.mu.Unlock()
 			continue
 		}
-
-		// Handle text messages.
-		var msg Message
-		if err := json.Unmarshal(data, &msg); err != nil {
-			log.Printf("Error parsing JSON from %s: %v", clientID, err)
+		if !hub.allowFrame(c, chatID, limiter, env.Type, env.ID) {
 			continue
 		}
+		switch env.Type {
+		case FrameSend:
+			var send SendMessage
+			if err := json.Unmarshal(env.Payload, &send); err != nil {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, err.Error()))
+				continue
+			}
+			// Messages may only be sent to the chat of this connection.
+			if send.ChatID != "" && send.ChatID != chatID {
+				log.Printf("Error: client %s tried to write to chat %s from chat %s", clientID, send.ChatID, chatID)
+				c.enqueue(errorFrame(env.ID, ErrCodeForbidden, "chat_id does not match the connection"))
+				continue
+			}
+			if err := hub.checkRestriction(chatID, identity.GuestID, RestrictMute); err != nil {
+				c.enqueue(errorFrame(env.ID, errorCode(err), errorText(err)))
+				continue
+			}
+			text, err := hub.prepareText(identity, send.Text, len(send.AttachmentIDs) > 0)
+			if err != nil {
+				c.enqueue(errorFrame(env.ID, errorCode(err), errorText(err)))
+				continue
+			}
+			attachments, err := hub.attachments(chatID, identity.GuestID, send.AttachmentIDs)
+			if err != nil {
+				c.enqueue(errorFrame(env.ID, errorCode(err), errorText(err)))
+				continue
+			}
+			msg := Message{
+				ChatID:      chatID,
+				Sender:      username,
+				SenderID:    identity.GuestID,
+				Kind:        KindUser,
+				Text:        text,
+				Attachments: attachments,
+			}
+			hub.touch(c, chatID, false)
+			log.Printf("Received from %s in chat %s: %s", clientID, msg.ChatID, msg.Text)
+			hub.submit(inbound{msg: msg, from: c, ref: env.ID})
+
+		case FrameEdit:
+			var edit EditMessage
+			if err := json.Unmarshal(env.Payload, &edit); err != nil {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, err.Error()))
+				continue
+			}
+			msg, err := hub.EditMessage(identity, chatID, edit.MessageID, edit.Text)
+			if err != nil {
+				c.enqueue(errorFrame(env.ID, errorCode(err), errorText(err)))
+				continue
+			}
+			if ack, err := encodeFrame(FrameAck, env.ID, Ack{MessageID: msg.ID, Timestamp: msg.EditedAt}); err == nil {
+				c.enqueue(ack)
+			}
 
-		// Ensure chat_id is provided in the message.
-		if msg.ChatID == "" {
-			log.Printf("Error: chat_id not provided in message from %s", clientID)
-			// Send error message to the client.
-			errorMsg := map[string]string{"error": "chat_id is required"}
-			data, _ := json.Marshal(errorMsg)
-			if err := ws.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Printf("Error sending error to client %s: %v", clientID, err)
+		case FrameDelete:
+			var del DeleteMessage
+			if err := json.Unmarshal(env.Payload, &del); err != nil {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, err.Error()))
+				continue
+			}
+			msg, err := hub.DeleteMessage(identity, chatID, del.MessageID)
+			if err != nil {
+				c.enqueue(errorFrame(env.ID, errorCode(err), errorText(err)))
+				continue
+			}
+			if ack, err := encodeFrame(FrameAck, env.ID, Ack{MessageID: msg.ID, Timestamp: msg.DeletedAt}); err == nil {
+				c.enqueue(ack)
 			}
-			continue
-		}
 
-		// Verify client exists in the chat and set sender.
-		hub.mu.Lock()
-		senderName, exists := hub.chats[chatID][ws]
-		if !exists {
-			log.Printf("Error: client %s not found in chat %s", clientID, chatID)
-			hub.mu.Unlock()
-			continue
-		}
-		hub.mu.Unlock()
-		msg.Sender = senderName
-		if msg.Timestamp == 0 {
-			msg.Timestamp = time.Now().UnixMilli()
-		}
+		case FrameReceipt:
+			var update ReceiptUpdate
+			if err := json.Unmarshal(env.Payload, &update); err != nil {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, err.Error()))
+				continue
+			}
+			// Receipts may only target the chat of this connection.
+			if update.ChatID != "" && update.ChatID != chatID {
+				c.enqueue(errorFrame(env.ID, ErrCodeForbidden, "chat_id does not match the connection"))
+				continue
+			}
+			update.ChatID = chatID
+			hub.handleReceipts(c, env.ID, update)
 
-		log.Printf("Received from %s in chat %s: %s", clientID, msg.ChatID, msg.Text)
-		hub.broadcast <- msg
-	}
-}
+		case FrameHeartbeat:
+			var hb Heartbeat
+			if err := json.Unmarshal(env.Payload, &hb); err != nil || hb.State != "active" && hb.State != "away" {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, `state must be "active" or "away"`))
+				continue
+			}
+			hub.touch(c, chatID, hb.State == "away")
 
-// pingLoop sends ping messages to keep the connection alive.
-func (hub *ChatHub) pingLoop(conn *websocket.Conn, clientID, chatID string) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-	for range ticker.C {
-		hub.mu.Lock()
-		_, exists := hub.chats[chatID][conn]
-		hub.mu.Unlock()
-		if !exists {
-			return
-		}
-		if err := conn.WriteControl(websocket.PingMessage, []byte{}, time.Now().Add(10*time.Second)); err != nil {
-			log.Printf("Error sending ping to client %s in chat %s: %v", clientID, chatID, err)
-			return
+		case FrameTyping:
+			var t Typing
+			if err := json.Unmarshal(env.Payload, &t); err != nil {
+				c.enqueue(errorFrame(env.ID, ErrCodeInvalidPayload, err.Error()))
+				continue
+			}
+			hub.touch(c, chatID, false)
+			hub.typing(chatID, c.identity, t.Typing)
+
+		default:
+			c.enqueue(errorFrame(env.ID, ErrCodeUnknownType, "unknown frame type "+strconv.Quote(env.Type)))
 		}
 	}
 }
-
-
-
-
-
-
-
-
-
-
-
-
-
-
-
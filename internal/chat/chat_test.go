@@ -0,0 +1,58 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+// blockingStore holds Save for one chat until release is closed.
+type blockingStore struct {
+	*MemoryStore
+	chatID  string
+	release chan struct{}
+}
+
+func (s *blockingStore) Save(msg *Message) error {
+	if msg.ChatID == s.chatID {
+		<-s.release
+	}
+	return s.MemoryStore.Save(msg)
+}
+
+// A slow save delays only its own chat, and messages of a chat are saved in
+// the order they were submitted, with IDs in the same order.
+func TestSubmitPerChat(t *testing.T) {
+	store := &blockingStore{MemoryStore: NewMemoryStore(), chatID: "slow", release: make(chan struct{})}
+	hub := NewChatHub(store, NewMemoryBroker())
+	for _, text := range []string{"1", "2", "3"} {
+		hub.Post(Message{ChatID: "slow", Sender: SystemSender, Text: text})
+	}
+	hub.Post(Message{ChatID: "fast", Sender: SystemSender, Text: "hi"})
+
+	waitHistory := func(chatID string, n int) []Message {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			history, err := store.History(chatID, "", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(history) >= n {
+				return history
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("chat %s: %d messages saved, want %d", chatID, len(history), n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitHistory("fast", 1)
+
+	close(store.release)
+	history := waitHistory("slow", 3)
+	for i, msg := range history {
+		if msg.Text != []string{"1", "2", "3"}[i] || msg.ID == "" || i > 0 && msg.ID <= history[i-1].ID {
+			t.Fatalf("history %+v, want messages 1, 2, 3 with ascending IDs", history)
+		}
+	}
+}
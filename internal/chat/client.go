@@ -0,0 +1,131 @@
+package chat
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"go-robot/internal/auth"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// sendBufferSize is the number of outbound frames queued per connection.
+	// A client that falls this far behind is evicted instead of stalling the hub.
+	sendBufferSize = 64
+	// writeWait is the time allowed to write a single frame.
+	writeWait = 10 * time.Second
+	// pongWait is the time allowed to read the next pong from the peer.
+	pongWait = 60 * time.Second
+	// pingPeriod must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+	// maxFrameSize is the maximum size of a client frame. It leaves room for
+	// a message of maxMessageLength with JSON escaping; larger frames close
+	// the connection.
+	maxFrameSize = 32 << 10
+)
+
+// client is a WebSocket connection with a buffered outbound queue.
+// gorilla/websocket allows only one concurrent writer, so every write,
+// including pings, goes through writePump.
+type client struct {
+	conn      *websocket.Conn
+	identity  auth.Identity
+	send      chan []byte
+	done      chan struct{}
+	once      sync.Once
+	closeCode int    // Sent in the close frame; set before done is closed.
+	closeText string // Reason sent with closeCode.
+}
+
+// newClient wraps conn and starts its writer goroutine.
+func newClient(conn *websocket.Conn, identity auth.Identity) *client {
+	c := &client{
+		conn:     conn,
+		identity: identity,
+		send:     make(chan []byte, sendBufferSize),
+		done:     make(chan struct{}),
+	}
+	conn.SetReadLimit(maxFrameSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go c.writePump()
+	return c
+}
+
+// enqueue queues data without blocking. It returns false if the client is closed
+// or its buffer is full; in the latter case the client is evicted.
+func (c *client) enqueue(data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- data:
+		return true
+	default:
+		log.Printf("Evicting slow client %d (%s): send buffer full", c.identity.GuestID, c.identity.Username)
+		c.close()
+		return false
+	}
+}
+
+// close stops the writer and closes the connection, which also ends the read loop.
+// It is safe to call more than once.
+func (c *client) close() {
+	c.closeWith(websocket.CloseGoingAway, "")
+}
+
+// closeWith is close with a specific close code and reason. Only the first
+// call has an effect.
+func (c *client) closeWith(code int, text string) {
+	c.once.Do(func() {
+		c.closeCode, c.closeText = code, text
+		close(c.done)
+	})
+}
+
+// writePump is the only goroutine writing to conn. It also closes the
+// connection when the access token it was opened with expires, so a logout,
+// role change or ban of the account takes effect on reconnect.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	var expired <-chan time.Time
+	if !c.identity.ExpiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(c.identity.ExpiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("Error writing to client %d: %v", c.identity.GuestID, err)
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				log.Printf("Error sending ping to client %d: %v", c.identity.GuestID, err)
+				c.close()
+				return
+			}
+		case <-expired:
+			log.Printf("Closing connection of client %d: access token expired", c.identity.GuestID)
+			c.closeWith(websocket.ClosePolicyViolation, closeTokenExpired)
+		case <-c.done:
+			c.conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(c.closeCode, c.closeText), time.Now().Add(writeWait))
+			return
+		}
+	}
+}
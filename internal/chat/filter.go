@@ -0,0 +1,159 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrLinkNotAllowed is returned by Filter for texts with links to domains
+// that are not allowed.
+var ErrLinkNotAllowed = errors.New("links are not allowed in this chat")
+
+// FilterConfig configures a Filter.
+type FilterConfig struct {
+	// Words are masked in messages. Matching ignores case and treats "ё" as "е";
+	// a trailing "*" matches every word starting with the rest ("дурак*").
+	Words []string
+	// BlockLinks rejects messages with links, except to AllowedDomains.
+	// Operators may always send links.
+	BlockLinks bool
+	// AllowedDomains are allowed together with their subdomains.
+	AllowedDomains []string
+}
+
+// Filter checks message texts before they are broadcast.
+type Filter struct {
+	words      map[string]bool
+	prefixes   []string
+	blockLinks bool
+	domains    []string
+}
+
+// NewFilter creates a Filter from cfg.
+func NewFilter(cfg FilterConfig) *Filter {
+	f := &Filter{words: make(map[string]bool), blockLinks: cfg.BlockLinks}
+	for _, w := range cfg.Words {
+		w = normalizeWord(strings.TrimSpace(w))
+		if prefix, ok := strings.CutSuffix(w, "*"); ok {
+			if prefix != "" {
+				f.prefixes = append(f.prefixes, prefix)
+			}
+		} else if w != "" {
+			f.words[w] = true
+		}
+	}
+	for _, d := range cfg.AllowedDomains {
+		if d = strings.ToLower(strings.Trim(strings.TrimSpace(d), ".")); d != "" {
+			f.domains = append(f.domains, d)
+		}
+	}
+	return f
+}
+
+// Apply returns text with banned words masked, or ErrLinkNotAllowed.
+// staff exempts the sender from the link check.
+func (f *Filter) Apply(text string, staff bool) (string, error) {
+	if f.blockLinks && !staff {
+		for _, field := range strings.Fields(text) {
+			if host, ok := linkHost(field); ok && !f.allowed(host) {
+				return "", ErrLinkNotAllowed
+			}
+		}
+	}
+	if len(f.words) == 0 && len(f.prefixes) == 0 {
+		return text, nil
+	}
+	var b strings.Builder
+	start := -1 // Start of the current word, -1 between words.
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			b.WriteString(f.mask(text[start:i]))
+			start = -1
+		}
+		if i < len(text) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), nil
+}
+
+// mask replaces all letters but the first of a banned word with asterisks.
+func (f *Filter) mask(word string) string {
+	w := normalizeWord(word)
+	banned := f.words[w]
+	for _, p := range f.prefixes {
+		banned = banned || strings.HasPrefix(w, p)
+	}
+	if !banned {
+		return word
+	}
+	first, size := utf8.DecodeRuneInString(word)
+	return string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+}
+
+func (f *Filter) allowed(host string) bool {
+	for _, d := range f.domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeWord(w string) string {
+	return strings.ReplaceAll(strings.ToLower(w), "ё", "е")
+}
+
+// linkTLDs are the top-level domains recognized in links without a scheme
+// ("example.ru"). Other words with dots, like "т.е." or "1.5", are not links.
+var linkTLDs = map[string]bool{
+	"ru": true, "рф": true, "su": true, "by": true, "kz": true, "ua": true,
+	"com": true, "net": true, "org": true, "info": true, "biz": true,
+	"io": true, "me": true, "co": true, "app": true, "dev": true, "ly": true,
+	"gg": true, "cc": true, "xyz": true, "online": true, "site": true, "shop": true, "store": true,
+}
+
+// linkHost reports whether a whitespace-separated field of a message is a link
+// (or an e-mail address) and returns its lowercase host.
+func linkHost(field string) (string, bool) {
+	s := strings.ToLower(strings.Trim(field, `.,;:!?()[]{}<>"'«»`))
+	explicit := false
+	if i := strings.Index(s, "://"); i > 0 {
+		s, explicit = s[i+3:], true
+	} else if strings.HasPrefix(s, "www.") {
+		explicit = true
+	}
+	// Browsers treat "\" like "/", so "evil.com\@allowed.ru" leads to evil.com.
+	if i := strings.IndexAny(s, `/\?#`); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.LastIndexByte(s, '@'); i >= 0 {
+		s = s[i+1:]
+	}
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		s = s[:i]
+	}
+	if explicit {
+		return s, s != ""
+	}
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 || !linkTLDs[labels[len(labels)-1]] {
+		return "", false
+	}
+	for _, l := range labels {
+		if l == "" || strings.IndexFunc(l, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+		}) >= 0 {
+			return "", false
+		}
+	}
+	return s, true
+}
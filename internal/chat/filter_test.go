@@ -0,0 +1,101 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLinkHost(t *testing.T) {
+	tests := []struct {
+		field  string
+		host   string
+		isLink bool
+	}{
+		{field: "https://Example.com/path?q=1", host: "example.com", isLink: true},
+		{field: "HTTP://example.com", host: "example.com", isLink: true},
+		{field: "ftp://files", host: "files", isLink: true},
+		{field: "www.test.ru,", host: "www.test.ru", isLink: true},
+		{field: "(google.com)", host: "google.com", isLink: true},
+		{field: "«пример.рф»", host: "пример.рф", isLink: true},
+		{field: "example.ru:8080/x", host: "example.ru", isLink: true},
+		{field: "mail@Site.ru", host: "site.ru", isLink: true},
+		{field: "user:pass@evil.com", host: "evil.com", isLink: true},
+		{field: "http://allowed.ru@evil.com/", host: "evil.com", isLink: true},
+		{field: `http://evil.com\@allowed.ru`, host: "evil.com", isLink: true},
+		{field: "sub-domain.shop", host: "sub-domain.shop", isLink: true},
+
+		{field: "т.е.", isLink: false},
+		{field: "1.5", isLink: false},
+		{field: "file.txt", isLink: false},
+		{field: "a..com", isLink: false},
+		{field: "under_score.com", isLink: false},
+		{field: "http://", isLink: false},
+		{field: "javascript:alert(1)", isLink: false},
+		{field: "hello", isLink: false},
+		{field: "", isLink: false},
+	}
+	for _, tt := range tests {
+		host, ok := linkHost(tt.field)
+		if ok != tt.isLink || host != tt.host {
+			t.Errorf("linkHost(%q) = %q, %v; want %q, %v", tt.field, host, ok, tt.host, tt.isLink)
+		}
+	}
+}
+
+func TestFilterWords(t *testing.T) {
+	f := NewFilter(FilterConfig{Words: []string{"дурак*", " плохо ", "ЕЛКА", "*", ""}})
+	tests := []struct {
+		in, want string
+	}{
+		{in: "Вы Дураки!", want: "Вы Д*****!"},
+		{in: "Ты дурачок", want: "Ты дурачок"},
+		{in: "дурак, дурак", want: "д****, д****"},
+		{in: "Это плохо.", want: "Это п****."},
+		{in: "плохой день", want: "плохой день"},
+		{in: "плохо123", want: "плохо123"},
+		{in: "Ёлка", want: "Ё***"},
+		{in: "ёлка-палка", want: "ё***-палка"},
+		{in: "всё хорошо", want: "всё хорошо"},
+		{in: "", want: ""},
+	}
+	for _, tt := range tests {
+		got, err := f.Apply(tt.in, false)
+		if err != nil {
+			t.Errorf("Apply(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilterLinks(t *testing.T) {
+	f := NewFilter(FilterConfig{BlockLinks: true, AllowedDomains: []string{" Example.com. ", ""}})
+	tests := []struct {
+		text    string
+		staff   bool
+		blocked bool
+	}{
+		{text: "see https://example.com/menu", blocked: false},
+		{text: "see sub.example.com", blocked: false},
+		{text: "go to evil.ru now", blocked: true},
+		{text: "go to evil.ru now", staff: true, blocked: false},
+		{text: "example.com.evil.ru", blocked: true},
+		{text: "notexample.com", blocked: true},
+		{text: "https://example.com@evil.ru", blocked: true},
+		{text: `https://evil.ru\@example.com`, blocked: true},
+		{text: "т.е. цена 1.5", blocked: false},
+	}
+	for _, tt := range tests {
+		_, err := f.Apply(tt.text, tt.staff)
+		if blocked := errors.Is(err, ErrLinkNotAllowed); blocked != tt.blocked {
+			t.Errorf("Apply(%q, staff=%v) error = %v, want blocked %v", tt.text, tt.staff, err, tt.blocked)
+		}
+	}
+
+	open := NewFilter(FilterConfig{})
+	if got, err := open.Apply("evil.ru", false); err != nil || got != "evil.ru" {
+		t.Errorf("Apply without rules = %q, %v; want text unchanged", got, err)
+	}
+}
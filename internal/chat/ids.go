@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageIDLen is the length of IDs returned by NewMessageID.
+const messageIDLen = 32
+
+// idGen keeps NewMessageID monotonic within a millisecond.
+var idGen struct {
+	sync.Mutex
+	lastMs  int64
+	entropy [10]byte
+}
+
+// NewMessageID returns a ULID-like message ID: a 48-bit millisecond timestamp
+// followed by 80 random bits, hex-encoded. IDs sort by creation time as plain
+// strings, so they double as pagination cursors. IDs generated within the same
+// millisecond increment the random part instead of drawing a new one.
+func NewMessageID() string {
+	idGen.Lock()
+	defer idGen.Unlock()
+	ms := time.Now().UnixMilli()
+	if ms > idGen.lastMs {
+		idGen.lastMs = ms
+		rand.Read(idGen.entropy[:])
+	} else {
+		// Same millisecond, or the clock went back: stay after the previous ID.
+		for i := len(idGen.entropy) - 1; i >= 0; i-- {
+			idGen.entropy[i]++
+			if idGen.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%012x%s", idGen.lastMs, hex.EncodeToString(idGen.entropy[:]))
+}
+
+// ValidMessageID reports whether id has the format produced by NewMessageID.
+func ValidMessageID(id string) bool {
+	if len(id) != messageIDLen || strings.ToLower(id) != id {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
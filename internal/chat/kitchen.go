@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/kitchen"
+	"go-robot/internal/orders"
+)
+
+// KitchenEvent is a frame of the kitchen display stream.
+type KitchenEvent struct {
+	Type       string          `json:"type"` // "kitchen.queue", "kitchen.order" or "kitchen.remove".
+	ServerTime time.Time       `json:"server_time"`
+	Orders     []kitchen.Order `json:"orders,omitempty"`   // Full queue for "kitchen.queue".
+	Order      *kitchen.Order  `json:"order,omitempty"`    // Updated order for "kitchen.order".
+	OrderID    int             `json:"order_id,omitempty"` // Order that left the queue for "kitchen.remove".
+}
+
+// KitchenHub streams the live kitchen queue: a full snapshot on connect,
+// then an update whenever an order changes status or an item is bumped.
+// Updates are loaded and sent one at a time by refreshLoop, so a screen
+// never receives an older state of an order after a newer one.
+type KitchenHub struct {
+	kitchen *kitchen.Service
+	mu      sync.Mutex
+	conns   map[*client]bool
+	pending map[int]bool  // Orders waiting for refreshLoop. Guarded by mu.
+	wake    chan struct{} // Signals refreshLoop that pending is not empty.
+	loading sync.Mutex    // Held while loading and queuing an update or a snapshot.
+}
+
+// NewKitchenHub creates a KitchenHub subscribed to order status changes and item bumps.
+func NewKitchenHub(svc *kitchen.Service, orderSvc *orders.Service) *KitchenHub {
+	hub := &KitchenHub{
+		kitchen: svc,
+		conns:   make(map[*client]bool),
+		pending: make(map[int]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	orderSvc.OnStatusChange(func(ev orders.Event) { hub.schedule(ev.OrderID) })
+	svc.OnChange(hub.schedule)
+	go hub.refreshLoop()
+	return hub
+}
+
+// schedule queues a refresh of the order. Several changes of one order
+// before the refresh runs result in a single update.
+func (hub *KitchenHub) schedule(orderID int) {
+	hub.mu.Lock()
+	hub.pending[orderID] = true
+	hub.mu.Unlock()
+	select {
+	case hub.wake <- struct{}{}:
+	default: // A wake-up is already pending.
+	}
+}
+
+// refreshLoop refreshes scheduled orders one at a time.
+func (hub *KitchenHub) refreshLoop() {
+	for range hub.wake {
+		hub.mu.Lock()
+		ids := make([]int, 0, len(hub.pending))
+		for id := range hub.pending {
+			ids = append(ids, id)
+		}
+		hub.pending = make(map[int]bool)
+		hub.mu.Unlock()
+		sort.Ints(ids)
+		for _, id := range ids {
+			hub.refresh(id)
+		}
+	}
+}
+
+// refresh reloads one order and pushes it (or its removal) to all kitchen screens.
+// Only refreshLoop calls it.
+func (hub *KitchenHub) refresh(orderID int) {
+	hub.loading.Lock()
+	defer hub.loading.Unlock()
+	order, active, err := hub.kitchen.Order(orderID)
+	if err != nil {
+		log.Printf("Error loading kitchen order %d: %v", orderID, err)
+		return
+	}
+	ev := KitchenEvent{Type: "kitchen.remove", ServerTime: time.Now(), OrderID: orderID}
+	if active {
+		ev = KitchenEvent{Type: "kitchen.order", ServerTime: ev.ServerTime, Order: &order}
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error marshaling kitchen event: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for c := range hub.conns {
+		c.enqueue(data)
+	}
+}
+
+// KitchenHandler handles WebSocket connections of kitchen screens.
+// Requires an identity with the kitchen permission in the request context.
+func (hub *KitchenHub) KitchenHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok || !identity.Can(auth.PermKitchen) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading to WebSocket: %v", err)
+		return
+	}
+	c := newClient(ws, identity)
+	defer func() {
+		c.close()
+		hub.mu.Lock()
+		delete(hub.conns, c)
+		hub.mu.Unlock()
+	}()
+
+	// No update is loaded while the snapshot is: updates queued after it
+	// are at least as new.
+	hub.loading.Lock()
+	queue, err := hub.kitchen.Queue()
+	if err != nil {
+		hub.loading.Unlock()
+		log.Printf("Error loading kitchen queue: %v", err)
+		return
+	}
+	data, err := json.Marshal(KitchenEvent{Type: "kitchen.queue", ServerTime: time.Now(), Orders: queue})
+	if err != nil {
+		hub.loading.Unlock()
+		log.Printf("Error marshaling kitchen queue: %v", err)
+		return
+	}
+	// Register before queuing the snapshot, so later updates follow it in order.
+	hub.mu.Lock()
+	hub.conns[c] = true
+	c.enqueue(data)
+	hub.mu.Unlock()
+	hub.loading.Unlock()
+	log.Printf("Kitchen screen connected (user %d)", identity.GuestID)
+
+	// The stream is server-to-client; reading only detects disconnects and pongs.
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			log.Printf("Kitchen screen disconnected (user %d): %v", identity.GuestID, err)
+			return
+		}
+	}
+}
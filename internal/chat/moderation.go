@@ -0,0 +1,317 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+// Restriction kinds.
+const (
+	RestrictMute = "mute" // The user may read the chat, but not write to it.
+	RestrictBan  = "ban"  // The user may not connect to the chat.
+)
+
+var (
+	// ErrMessageNotFound is returned for unknown message IDs.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrMessageDeleted is returned when editing or deleting a deleted message.
+	ErrMessageDeleted = errors.New("message is deleted")
+	// ErrNotSender is returned when a user edits or deletes another user's message.
+	ErrNotSender = errors.New("message belongs to another user")
+	// ErrInvalidText is returned for empty or too long message texts.
+	ErrInvalidText = fmt.Errorf("text must be 1-%d bytes", maxMessageLength)
+	// ErrMuted is returned when a muted user writes to the chat.
+	ErrMuted = errors.New("you are muted in this chat")
+	// ErrBanned is returned when a banned user connects to the chat.
+	ErrBanned = errors.New("you are banned from this chat")
+	// ErrInvalidRestriction is returned for restrictions of an unknown kind
+	// or with an expiry in the past.
+	ErrInvalidRestriction = errors.New("invalid restriction")
+	// ErrRestrictionNotFound is returned when lifting a restriction that is not in effect.
+	ErrRestrictionNotFound = errors.New("restriction not found")
+)
+
+// Revision is a previous text of an edited message.
+type Revision struct {
+	Text      string `json:"text"`
+	Timestamp int64  `json:"timestamp"` // When the text was sent or set by an edit, unix ms.
+}
+
+// Restriction mutes or bans a guest in a chat until ExpiresAt.
+type Restriction struct {
+	ChatID    string `json:"chat_id"`
+	GuestID   int    `json:"guest_id"`
+	Username  string `json:"username,omitempty"` // Current username of the guest; set by the store.
+	Kind      string `json:"kind"`               // RestrictMute or RestrictBan
+	Reason    string `json:"reason,omitempty"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt int64  `json:"created_at"`           // Unix ms.
+	ExpiresAt int64  `json:"expires_at,omitempty"` // Unix ms; 0 means permanent.
+	Lifted    bool   `json:"lifted,omitempty"`     // Set in the frame sent when a restriction is lifted.
+}
+
+// Active reports whether the restriction is in effect at now.
+func (r Restriction) Active(now time.Time) bool {
+	return r.ExpiresAt == 0 || r.ExpiresAt > now.UnixMilli()
+}
+
+func (r Restriction) until() string {
+	if r.ExpiresAt == 0 {
+		return "permanently"
+	}
+	return "until " + time.UnixMilli(r.ExpiresAt).UTC().Format(time.RFC3339)
+}
+
+// redacted hides the content of a deleted message.
+func (m Message) redacted() Message {
+	if m.DeletedAt != 0 {
+		m.Text = ""
+		m.Attachments = nil
+	}
+	return m
+}
+
+// UseFilter enables the profanity and link filter for messages sent through the hub.
+func (hub *ChatHub) UseFilter(f *Filter) {
+	hub.filter = f
+}
+
+// prepareText validates text and applies the filter. Empty text is allowed
+// only if the message has attachments.
+func (hub *ChatHub) prepareText(sender auth.Identity, text string, hasAttachments bool) (string, error) {
+	if len(text) > maxMessageLength || strings.TrimSpace(text) == "" && !hasAttachments {
+		return "", ErrInvalidText
+	}
+	if hub.filter == nil {
+		return text, nil
+	}
+	return hub.filter.Apply(text, sender.Can(auth.PermChatAdmin))
+}
+
+// restriction returns the restriction of the given kind in effect for the
+// guest in the chat, or nil.
+func (hub *ChatHub) restriction(chatID string, guestID int, kind string) (*Restriction, error) {
+	list, err := hub.store.Restrictions(chatID, guestID)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range list {
+		if r.Kind == kind {
+			return &r, nil
+		}
+	}
+	return nil, nil
+}
+
+// checkRestriction returns ErrMuted or ErrBanned if the guest is restricted.
+func (hub *ChatHub) checkRestriction(chatID string, guestID int, kind string) error {
+	r, err := hub.restriction(chatID, guestID, kind)
+	if err != nil {
+		log.Printf("Error loading restrictions of guest %d in chat %s: %v", guestID, chatID, err)
+		return err
+	}
+	if r == nil {
+		return nil
+	}
+	if kind == RestrictBan {
+		return fmt.Errorf("%w %s", ErrBanned, r.until())
+	}
+	return fmt.Errorf("%w %s", ErrMuted, r.until())
+}
+
+// CheckBan returns an error wrapping ErrBanned if the guest is banned from
+// the chat, or the error that prevented the check. Access must be denied
+// on any error.
+func (hub *ChatHub) CheckBan(chatID string, guestID int) error {
+	return hub.checkRestriction(chatID, guestID, RestrictBan)
+}
+
+// EditMessage replaces the text of a message. Only the sender may edit
+// their own user messages, and muted users may not. The previous text is kept as a Revision.
+func (hub *ChatHub) EditMessage(editor auth.Identity, chatID, messageID, text string) (Message, error) {
+	if err := hub.checkRestriction(chatID, editor.GuestID, RestrictMute); err != nil {
+		return Message{}, err
+	}
+	msg, err := hub.store.Message(chatID, messageID)
+	if err != nil {
+		return Message{}, err
+	}
+	if msg.SenderID != editor.GuestID || msg.Kind != KindUser {
+		return Message{}, ErrNotSender
+	}
+	if msg.DeletedAt != 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	text, err = hub.prepareText(editor, text, len(msg.Attachments) > 0)
+	if err != nil {
+		return Message{}, err
+	}
+	msg, err = hub.store.Edit(chatID, messageID, text, time.Now().UnixMilli())
+	if err != nil {
+		return Message{}, err
+	}
+	hub.broadcastUpdate(msg)
+	return msg, nil
+}
+
+// DeleteMessage soft-deletes a message: it stays in the history with its
+// text hidden. Users may delete their own messages, operators any message.
+func (hub *ChatHub) DeleteMessage(by auth.Identity, chatID, messageID string) (Message, error) {
+	msg, err := hub.store.Message(chatID, messageID)
+	if err != nil {
+		return Message{}, err
+	}
+	if msg.SenderID != by.GuestID && !by.Can(auth.PermChatAdmin) {
+		return Message{}, ErrNotSender
+	}
+	if msg.DeletedAt != 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	msg, err = hub.store.Delete(chatID, messageID, by.Username, time.Now().UnixMilli())
+	if err != nil {
+		return Message{}, err
+	}
+	hub.broadcastUpdate(msg)
+	return msg, nil
+}
+
+// broadcastUpdate sends an edited or deleted message to the chat on all instances.
+func (hub *ChatHub) broadcastUpdate(msg Message) {
+	data, err := encodeFrame(FrameUpdated, "", msg.redacted())
+	if err != nil {
+		log.Printf("Error marshaling message update: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	hub.sendToChat(msg.ChatID, data)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventFrame, ChatID: msg.ChatID, Frame: data})
+}
+
+// Restrict mutes or bans a guest in a chat, replacing an earlier restriction
+// of the same kind. A banned guest is disconnected on all instances.
+func (hub *ChatHub) Restrict(r Restriction) (Restriction, error) {
+	now := time.Now()
+	if r.Kind != RestrictMute && r.Kind != RestrictBan || r.GuestID <= 0 || !r.Active(now) {
+		return Restriction{}, ErrInvalidRestriction
+	}
+	r.CreatedAt = now.UnixMilli()
+	if err := hub.store.Restrict(&r); err != nil {
+		return Restriction{}, err
+	}
+	log.Printf("Guest %d (%s) restricted in chat %s by %s: %s %s", r.GuestID, r.Username, r.ChatID, r.CreatedBy, r.Kind, r.until())
+	hub.notifyRestriction(r)
+	if r.Kind == RestrictBan {
+		hub.mu.Lock()
+		hub.kick(r.ChatID, r.GuestID)
+		hub.mu.Unlock()
+		hub.publish(Event{Kind: EventKick, ChatID: r.ChatID, GuestID: r.GuestID})
+	}
+	return r, nil
+}
+
+// Unrestrict lifts a restriction before it expires.
+func (hub *ChatHub) Unrestrict(chatID string, guestID int, kind string) error {
+	if err := hub.store.Unrestrict(chatID, guestID, kind); err != nil {
+		return err
+	}
+	log.Printf("Guest %d is no longer restricted (%s) in chat %s", guestID, kind, chatID)
+	hub.notifyRestriction(Restriction{ChatID: chatID, GuestID: guestID, Kind: kind, Lifted: true})
+	return nil
+}
+
+// Restrictions returns the restrictions in effect in the chat.
+func (hub *ChatHub) Restrictions(chatID string) ([]Restriction, error) {
+	return hub.store.Restrictions(chatID, 0)
+}
+
+// Revisions returns the previous texts of a message, oldest first.
+func (hub *ChatHub) Revisions(chatID, messageID string) ([]Revision, error) {
+	return hub.store.Revisions(chatID, messageID)
+}
+
+// notifyRestriction tells the restricted guest's clients about r.
+func (hub *ChatHub) notifyRestriction(r Restriction) {
+	data, err := encodeFrame(FrameRestriction, "", r)
+	if err != nil {
+		log.Printf("Error marshaling restriction: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	hub.sendToUser(r.ChatID, r.GuestID, data)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventFrame, ChatID: r.ChatID, GuestID: r.GuestID, Frame: data})
+}
+
+// sendToUser queues data for the clients of one guest in the chat.
+// Must be called with hub.mu held.
+func (hub *ChatHub) sendToUser(chatID string, guestID int, data []byte) {
+	for c := range hub.chats[chatID] {
+		if c.identity.GuestID == guestID {
+			c.enqueue(data)
+		}
+	}
+}
+
+// kick disconnects the clients of a guest from the chat; their read loops
+// then leave the chat as usual. Must be called with hub.mu held.
+func (hub *ChatHub) kick(chatID string, guestID int) {
+	for c := range hub.chats[chatID] {
+		if c.identity.GuestID == guestID {
+			log.Printf("Disconnecting client %d (%s) from chat %s", guestID, c.identity.Username, chatID)
+			c.closeWith(CloseBanned, "banned")
+		}
+	}
+}
+
+// errorCode maps errors of hub operations to error frame codes.
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		return ErrCodeNotFound
+	case errors.Is(err, ErrNotSender):
+		return ErrCodeForbidden
+	case errors.Is(err, ErrMuted):
+		return ErrCodeMuted
+	case errors.Is(err, ErrLinkNotAllowed):
+		return ErrCodeFiltered
+	case errors.Is(err, ErrMessageDeleted), errors.Is(err, ErrInvalidText),
+		errors.Is(err, ErrAttachmentUnavailable):
+		return ErrCodeInvalidPayload
+	}
+	return ErrCodeInternal
+}
+
+// errorText is the message of an error frame for err; internal errors are not exposed.
+func errorText(err error) string {
+	if errorCode(err) == ErrCodeInternal {
+		return "internal error, try again"
+	}
+	return err.Error()
+}
+
+// ParseRestrictionDuration parses a restriction duration such as "30m", "24h"
+// or "7d"; "" means permanent and returns 0.
+func ParseRestrictionDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("%w: duration %q", ErrInvalidRestriction, s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%w: duration %q", ErrInvalidRestriction, s)
+	}
+	return d, nil
+}
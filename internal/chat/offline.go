@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	// maxOfflineQueue limits the undelivered messages sent on connect in
+	// addition to the latest history.
+	maxOfflineQueue = 500
+	// notifyInterval is how often unread messages are checked for notifications.
+	notifyInterval = time.Minute
+	// notifyWindow bounds how old an unread message may be to trigger a
+	// notification, so that old history does not cause a burst after an upgrade.
+	notifyWindow = 24 * time.Hour
+	// maxNotifyMessages limits the messages included in one notification.
+	maxNotifyMessages = 5
+)
+
+// ChatUnread is the unread state of one chat in an UnreadSummary.
+type ChatUnread struct {
+	ChatID string  `json:"chat_id"`
+	Count  int     `json:"count"`
+	Last   Message `json:"last"` // The newest unread message.
+}
+
+// UnreadSummary is sent to a client after the chat state and lists every
+// chat of the user with unread messages.
+type UnreadSummary struct {
+	Total int          `json:"total"`
+	Chats []ChatUnread `json:"chats"`
+}
+
+// Notification tells a user who is not connected that messages have been
+// waiting unread in a chat.
+type Notification struct {
+	GuestID  int       `json:"guest_id"`
+	Username string    `json:"username"` // Current username of the recipient.
+	ChatID   string    `json:"chat_id"`
+	Messages []Message `json:"messages"` // Oldest first, at most maxNotifyMessages.
+	Unread   int       `json:"unread"`   // All messages waiting for this notification.
+}
+
+// Notifier delivers notifications outside the chat, e.g. by e-mail or webhook.
+// Notify is called from the hub's notification loop and may block. If it
+// returns an error, the notification is retried on a later check, so a
+// Notifier that fails after delivering may deliver it twice.
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// LogNotifier only logs notifications. It stands in until a real channel
+// is configured.
+type LogNotifier struct{}
+
+// Notify logs n.
+func (LogNotifier) Notify(n Notification) error {
+	log.Printf("Notification for %s: %d unread message(s) in chat %s", n.Username, n.Unread, n.ChatID)
+	return nil
+}
+
+// UseNotifier enables notifications about messages that stayed unread for
+// after. It must be called before Run.
+func (hub *ChatHub) UseNotifier(n Notifier, after time.Duration) {
+	hub.notifier = n
+	hub.notifyAfter = after
+}
+
+// mergeMessages merges two lists of messages sorted by ID, dropping duplicates.
+func mergeMessages(a, b []Message) []Message {
+	if len(b) == 0 {
+		return a
+	}
+	seen := make(map[string]bool, len(a))
+	for _, m := range a {
+		seen[m.ID] = true
+	}
+	merged := append([]Message{}, a...)
+	for _, m := range b {
+		if !seen[m.ID] {
+			merged = append(merged, m)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].ID < merged[j].ID })
+	return merged
+}
+
+// sendUnreadSummary sends the unread messages of the user in all chats to c.
+func (hub *ChatHub) sendUnreadSummary(c *client) {
+	chats, err := hub.store.UnreadSummary(c.identity.GuestID)
+	if err != nil {
+		log.Printf("Error loading unread summary of %s: %v", c.identity.Username, err)
+		return
+	}
+	summary := UnreadSummary{Chats: chats}
+	for _, u := range chats {
+		summary.Total += u.Count
+	}
+	data, err := encodeFrame(FrameUnread, "", summary)
+	if err != nil {
+		log.Printf("Error marshaling unread summary: %v", err)
+		return
+	}
+	c.enqueue(data)
+}
+
+// online reports whether the guest is connected to the chat on any instance.
+func (hub *ChatHub) online(chatID string, guestID int) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, p := range hub.presence(chatID) {
+		if p.GuestID == guestID {
+			return p.State != StateOffline
+		}
+	}
+	return false
+}
+
+// notifyLoop periodically notifies users about messages that stayed unread.
+func (hub *ChatHub) notifyLoop() {
+	ticker := time.NewTicker(notifyInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		hub.notifyPending()
+	}
+}
+
+// notifyPending sends a notification per user and chat with messages unread
+// for longer than notifyAfter. Every message triggers at most one successful
+// notification, even with several instances: messages are claimed in the
+// store before notifying, and released if notifying fails. Users still
+// connected to the chat are skipped until they leave.
+func (hub *ChatHub) notifyPending() {
+	now := time.Now()
+	pending, err := hub.store.PendingNotifications(now.Add(-notifyWindow).UnixMilli(), now.Add(-hub.notifyAfter).UnixMilli())
+	if err != nil {
+		log.Printf("Error loading pending notifications: %v", err)
+		return
+	}
+	for _, n := range pending {
+		if hub.online(n.ChatID, n.GuestID) {
+			continue
+		}
+		ids := make([]string, len(n.Messages))
+		for i, m := range n.Messages {
+			ids[i] = m.ID
+		}
+		claimed, err := hub.store.MarkNotified(n.GuestID, ids)
+		if err != nil {
+			log.Printf("Error marking notifications of %s: %v", n.Username, err)
+			continue
+		}
+		if len(claimed) == 0 {
+			continue // Another instance notified first.
+		}
+		isClaimed := make(map[string]bool, len(claimed))
+		for _, id := range claimed {
+			isClaimed[id] = true
+		}
+		var messages []Message
+		for _, m := range n.Messages {
+			if isClaimed[m.ID] {
+				messages = append(messages, m)
+			}
+		}
+		n.Unread = len(messages)
+		if len(messages) > maxNotifyMessages {
+			messages = messages[len(messages)-maxNotifyMessages:]
+		}
+		n.Messages = messages
+		if err := hub.notifier.Notify(n); err != nil {
+			log.Printf("Error notifying %s about chat %s: %v", n.Username, n.ChatID, err)
+			if err := hub.store.UnmarkNotified(n.GuestID, claimed); err != nil {
+				log.Printf("Error releasing notifications of %s: %v", n.Username, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+// failingNotifier fails the first fails notifications and records the rest.
+type failingNotifier struct {
+	fails int
+	sent  []Notification
+}
+
+func (n *failingNotifier) Notify(notification Notification) error {
+	if n.fails > 0 {
+		n.fails--
+		return errors.New("smtp unavailable")
+	}
+	n.sent = append(n.sent, notification)
+	return nil
+}
+
+func TestNotifyPendingRetriesFailedNotifications(t *testing.T) {
+	store := NewMemoryStore()
+	hub := NewChatHub(store, NewMemoryBroker())
+	notifier := &failingNotifier{fails: 1}
+	hub.UseNotifier(notifier, 0)
+
+	const chatID = "support:1"
+	if err := store.Join(chatID, auth.Identity{GuestID: 1, Username: "guest"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: NewMessageID(), ChatID: chatID, Sender: "operator", SenderID: 7, Kind: KindUser, Text: "your order is ready", Timestamp: time.Now().UnixMilli()}
+	if err := store.Save(&msg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond) // The message must be older than notifyAfter.
+
+	hub.notifyPending()
+	if len(notifier.sent) != 0 {
+		t.Fatalf("sent %d notifications while the notifier was failing", len(notifier.sent))
+	}
+	hub.notifyPending()
+	if len(notifier.sent) != 1 || notifier.sent[0].GuestID != 1 || notifier.sent[0].Unread != 1 {
+		t.Fatalf("after a failure, sent = %+v, want one retried notification for guest 1", notifier.sent)
+	}
+	hub.notifyPending()
+	if len(notifier.sent) != 1 {
+		t.Errorf("sent %d notifications, want the message notified only once", len(notifier.sent))
+	}
+}
+
+// A guest connected with a token issued before a rename is online under the
+// new name and gets no notification.
+func TestNotifyPendingSkipsRenamedOnlineGuest(t *testing.T) {
+	store := NewMemoryStore()
+	hub := NewChatHub(store, NewMemoryBroker())
+	notifier := &failingNotifier{}
+	hub.UseNotifier(notifier, 0)
+
+	const chatID = "support:1"
+	if err := store.Join(chatID, auth.Identity{GuestID: 1, Username: "alicia"}); err != nil {
+		t.Fatal(err)
+	}
+	hub.mu.Lock()
+	hub.chats[chatID] = map[*client]*device{newTestClient(1, "alice"): {lastActive: time.Now()}}
+	hub.mu.Unlock()
+	msg := Message{ID: NewMessageID(), ChatID: chatID, Sender: "operator", SenderID: 7, Kind: KindUser, Text: "hello", Timestamp: time.Now().UnixMilli()}
+	if err := store.Save(&msg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond) // The message must be older than notifyAfter.
+
+	hub.notifyPending()
+	if len(notifier.sent) != 0 {
+		t.Errorf("sent %+v to a guest connected to the chat", notifier.sent)
+	}
+}
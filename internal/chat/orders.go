@@ -0,0 +1,187 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/orders"
+)
+
+// OrderEvent is pushed to clients whenever an order they follow changes status.
+type OrderEvent struct {
+	Type    string    `json:"type"` // Always "order.status".
+	Seq     int64     `json:"seq"`  // Grows with every change of the order; clients may ignore lower values.
+	OrderID int       `json:"order_id"`
+	Status  string    `json:"status"`
+	From    string    `json:"from,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	At      time.Time `json:"at"`
+	Replay  bool      `json:"replay,omitempty"` // True for the current status sent on subscribe.
+}
+
+// orderCommand is a client frame: {"action": "subscribe", "order_id": 42}.
+type orderCommand struct {
+	Action  string `json:"action"` // "subscribe" or "unsubscribe".
+	OrderID int    `json:"order_id"`
+}
+
+// orderConn is the state of one order tracking connection.
+type orderConn struct {
+	subs map[int]bool  // Explicit order subscriptions.
+	sent map[int]int64 // Seq of the last event sent per order.
+}
+
+// OrderHub pushes order status changes to connected clients. A guest receives
+// every change of their own orders; staff with orders:manage may subscribe to any order.
+//
+// Changes may be published out of order, and a replay may be loaded before a
+// change that is published first, so each connection only gets events newer
+// than the last one it was sent for the order.
+type OrderHub struct {
+	orders *orders.Service
+	mu     sync.Mutex
+	conns  map[*client]*orderConn
+}
+
+// NewOrderHub creates an OrderHub and subscribes it to status changes of svc.
+func NewOrderHub(svc *orders.Service) *OrderHub {
+	hub := &OrderHub{
+		orders: svc,
+		conns:  make(map[*client]*orderConn),
+	}
+	svc.OnStatusChange(hub.Publish)
+	return hub
+}
+
+// newOrderEvent converts a stored status event into a wire event.
+func newOrderEvent(ev orders.Event, replay bool) OrderEvent {
+	out := OrderEvent{
+		Type:    "order.status",
+		Seq:     ev.ID,
+		OrderID: ev.OrderID,
+		Status:  string(ev.To),
+		Note:    ev.Note,
+		At:      ev.CreatedAt,
+		Replay:  replay,
+	}
+	if ev.From != nil {
+		out.From = string(*ev.From)
+	}
+	return out
+}
+
+// Publish sends a status change to the order owner's connections and to explicit subscribers.
+func (hub *OrderHub) Publish(ev orders.Event) {
+	data, err := json.Marshal(newOrderEvent(ev, false))
+	if err != nil {
+		log.Printf("Error marshaling order event: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for c, conn := range hub.conns {
+		if c.identity.GuestID == ev.GuestID || conn.subs[ev.OrderID] {
+			conn.send(c, ev.OrderID, ev.ID, data)
+		}
+	}
+}
+
+// send queues data unless a newer event of the order was already sent.
+// The caller must hold hub.mu.
+func (conn *orderConn) send(c *client, orderID int, seq int64, data []byte) {
+	if seq <= conn.sent[orderID] {
+		return
+	}
+	conn.sent[orderID] = seq
+	c.enqueue(data)
+}
+
+// OrderHandler handles WebSocket connections for order tracking.
+// Requires an authenticated identity in the request context.
+func (hub *OrderHub) OrderHandler(w http.ResponseWriter, r *http.Request) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Error upgrading to WebSocket: %v", err)
+		return
+	}
+	c := newClient(ws, identity)
+
+	hub.mu.Lock()
+	hub.conns[c] = &orderConn{subs: make(map[int]bool), sent: make(map[int]int64)}
+	hub.mu.Unlock()
+	log.Printf("Guest %d connected to order tracking", identity.GuestID)
+
+	defer func() {
+		c.close()
+		hub.mu.Lock()
+		delete(hub.conns, c)
+		hub.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			log.Printf("Guest %d disconnected from order tracking: %v", identity.GuestID, err)
+			return
+		}
+		var cmd orderCommand
+		if err := json.Unmarshal(data, &cmd); err != nil || cmd.OrderID == 0 {
+			hub.sendError(c, "invalid command")
+			continue
+		}
+		switch cmd.Action {
+		case "subscribe":
+			hub.subscribe(c, cmd.OrderID)
+		case "unsubscribe":
+			hub.mu.Lock()
+			delete(hub.conns[c].subs, cmd.OrderID)
+			hub.mu.Unlock()
+		default:
+			hub.sendError(c, "unknown action")
+		}
+	}
+}
+
+// subscribe checks access to the order and replays its current status.
+func (hub *OrderHub) subscribe(c *client, orderID int) {
+	current, err := hub.orders.Current(orderID)
+	if errors.Is(err, orders.ErrNotFound) {
+		hub.sendError(c, "order not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error loading order %d: %v", orderID, err)
+		hub.sendError(c, "internal error")
+		return
+	}
+	if current.GuestID != c.identity.GuestID && !c.identity.Can(auth.PermManageOrders) {
+		hub.sendError(c, "order not found")
+		return
+	}
+	data, err := json.Marshal(newOrderEvent(current, true))
+	if err != nil {
+		log.Printf("Error marshaling order event: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	if conn, ok := hub.conns[c]; ok {
+		conn.subs[orderID] = true
+		conn.send(c, orderID, current.ID, data)
+	}
+	hub.mu.Unlock()
+}
+
+func (hub *OrderHub) sendError(c *client, msg string) {
+	data, _ := json.Marshal(map[string]string{"type": "error", "error": msg})
+	c.enqueue(data)
+}
@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go-robot/internal/orders"
+)
+
+// Changes published out of order reach a client only if they are newer
+// than the last event it was sent for the order.
+func TestOrderHubPublishOutOfOrder(t *testing.T) {
+	hub := &OrderHub{conns: make(map[*client]*orderConn)}
+	owner := newTestClient(1, "guest")
+	hub.conns[owner] = &orderConn{subs: make(map[int]bool), sent: make(map[int]int64)}
+
+	hub.Publish(orders.Event{ID: 3, OrderID: 42, GuestID: 1, To: orders.StatusCooking})
+	hub.Publish(orders.Event{ID: 2, OrderID: 42, GuestID: 1, To: orders.StatusAccepted})
+	hub.Publish(orders.Event{ID: 1, OrderID: 7, GuestID: 1, To: orders.StatusPlaced})
+
+	var got []OrderEvent
+	for len(owner.send) > 0 {
+		var ev OrderEvent
+		if err := json.Unmarshal(<-owner.send, &ev); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 2 || got[0].Seq != 3 || got[0].Status != string(orders.StatusCooking) || got[1].OrderID != 7 {
+		t.Errorf("sent %+v, want the cooking status of order 42 and order 7", got)
+	}
+}
@@ -0,0 +1,354 @@
+package chat
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+const (
+	// presenceInterval is how often an instance re-announces its connected users
+	// and re-evaluates idle devices.
+	presenceInterval = 30 * time.Second
+	// presenceTTL is how long users announced by another instance are considered online.
+	presenceTTL = 3 * presenceInterval
+	// idleAfter is how long a device may go without activity before it counts as idle.
+	idleAfter = 5 * time.Minute
+	// typingTTL tells clients when to drop a typing indicator that was never stopped.
+	typingTTL = 6 * time.Second
+)
+
+// Presence states, from most to least present.
+const (
+	StateOnline  = "online"
+	StateAway    = "away" // The client reported it is in the background.
+	StateIdle    = "idle" // No activity for idleAfter.
+	StateOffline = "offline"
+)
+
+var stateRank = map[string]int{StateOnline: 3, StateAway: 2, StateIdle: 1, StateOffline: 0}
+
+// UserPresence is the presence of one user in a chat over all their devices
+// and instances. Users are told apart by GuestID; Username is the name the
+// most recently active device connected with, which lags behind a rename
+// until its token is refreshed.
+type UserPresence struct {
+	GuestID  int    `json:"guest_id"`
+	Username string `json:"username"`
+	State    string `json:"state"`
+	Devices  int    `json:"devices"`   // Open connections; 0 when offline.
+	LastSeen int64  `json:"last_seen"` // Last activity, unix milliseconds.
+}
+
+// Heartbeat is sent by clients periodically and whenever they go to or come
+// back from the background.
+type Heartbeat struct {
+	State string `json:"state"` // "active" or "away"
+}
+
+// Typing is sent by a client when the user starts or stops typing.
+type Typing struct {
+	Typing bool `json:"typing"`
+}
+
+// TypingEvent tells the chat that a user is typing. Clients drop the
+// indicator after ExpiresIn seconds unless it is repeated.
+type TypingEvent struct {
+	ChatID    string `json:"chat_id"`
+	GuestID   int    `json:"guest_id"`
+	Username  string `json:"username"`
+	Typing    bool   `json:"typing"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// device is the presence of one connection. Guarded by ChatHub.mu.
+type device struct {
+	away       bool
+	lastActive time.Time
+}
+
+func (d *device) state(now time.Time) string {
+	switch {
+	case now.Sub(d.lastActive) > idleAfter:
+		return StateIdle
+	case d.away:
+		return StateAway
+	}
+	return StateOnline
+}
+
+// remotePresence is the presence of users connected to a chat on another instance.
+type remotePresence struct {
+	users []UserPresence
+	seen  time.Time
+}
+
+// merge adds p to users, summing devices and keeping the most present state
+// and the name of the most recently active device.
+func merge(users map[int]*UserPresence, p UserPresence) {
+	u, ok := users[p.GuestID]
+	if !ok {
+		users[p.GuestID] = &p
+		return
+	}
+	u.Devices += p.Devices
+	if stateRank[p.State] > stateRank[u.State] {
+		u.State = p.State
+	}
+	if p.LastSeen > u.LastSeen {
+		u.Username, u.LastSeen = p.Username, p.LastSeen
+	}
+}
+
+func sorted(users map[int]*UserPresence) []UserPresence {
+	list := make([]UserPresence, 0, len(users))
+	for _, u := range users {
+		list = append(list, *u)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Username != list[j].Username {
+			return list[i].Username < list[j].Username
+		}
+		return list[i].GuestID < list[j].GuestID
+	})
+	return list
+}
+
+// localPresence returns the users connected to this instance.
+// Must be called with hub.mu held.
+func (hub *ChatHub) localPresence(chatID string) []UserPresence {
+	now := time.Now()
+	users := make(map[int]*UserPresence)
+	for c, d := range hub.chats[chatID] {
+		merge(users, UserPresence{
+			GuestID:  c.identity.GuestID,
+			Username: c.identity.Username,
+			State:    d.state(now),
+			Devices:  1,
+			LastSeen: d.lastActive.UnixMilli(),
+		})
+	}
+	return sorted(users)
+}
+
+// presence returns the users of the chat on all instances, including users
+// who recently left. Must be called with hub.mu held.
+func (hub *ChatHub) presence(chatID string) []UserPresence {
+	users := make(map[int]*UserPresence)
+	for _, p := range hub.localPresence(chatID) {
+		merge(users, p)
+	}
+	for _, r := range hub.remote[chatID] {
+		for _, p := range r.users {
+			merge(users, p)
+		}
+	}
+	for guestID, p := range hub.departed[chatID] {
+		if _, online := users[guestID]; !online {
+			users[guestID] = &p
+		}
+	}
+	return sorted(users)
+}
+
+// presenceKey identifies what clients must be told about: states and device
+// counts. LastSeen alone changes with every heartbeat and is not broadcast.
+func presenceKey(users []UserPresence) string {
+	var b strings.Builder
+	for _, u := range users {
+		b.WriteString(strconv.Itoa(u.GuestID) + ":" + u.Username + ":" + u.State + ":" + strconv.Itoa(u.Devices) + ";")
+	}
+	return b.String()
+}
+
+// broadcastStatus sends the presence of users to the chat if it changed since
+// the last broadcast, and reports whether it did. Must be called with hub.mu held.
+func (hub *ChatHub) broadcastStatus(chatID string) bool {
+	if _, exists := hub.chats[chatID]; !exists {
+		return false
+	}
+	users := hub.presence(chatID)
+	key := presenceKey(users)
+	if hub.status[chatID] == key {
+		return false
+	}
+	hub.status[chatID] = key
+	data, err := encodeFrame(FrameStatus, "", StatusMessage{Users: users})
+	if err != nil {
+		log.Printf("Error marshaling status: %v", err)
+		return false
+	}
+	hub.sendToChat(chatID, data)
+	return true
+}
+
+// touch records activity (or going away) of c and broadcasts the presence if
+// the user's state changed.
+func (hub *ChatHub) touch(c *client, chatID string, away bool) {
+	hub.mu.Lock()
+	d := hub.chats[chatID][c]
+	if d == nil {
+		hub.mu.Unlock()
+		return
+	}
+	d.away = away
+	if !away {
+		d.lastActive = time.Now()
+	}
+	changed := hub.broadcastStatus(chatID)
+	hub.mu.Unlock()
+	if changed {
+		hub.announce(chatID)
+	}
+}
+
+// leave removes c from the chat and remembers when its user was last seen
+// if it was their last device. Must be called with hub.mu held.
+func (hub *ChatHub) leave(c *client, chatID string) {
+	d := hub.chats[chatID][c]
+	delete(hub.chats[chatID], c)
+	if len(hub.chats[chatID]) == 0 {
+		// Nobody is left to show presence to. History stays in the store.
+		delete(hub.chats, chatID)
+		delete(hub.departed, chatID)
+		delete(hub.status, chatID)
+		return
+	}
+	if d != nil {
+		for _, p := range hub.presence(chatID) {
+			if p.GuestID == c.identity.GuestID && p.State != StateOffline {
+				hub.broadcastStatus(chatID) // Another device is still connected.
+				return
+			}
+		}
+		hub.depart(chatID, UserPresence{GuestID: c.identity.GuestID, Username: c.identity.Username, LastSeen: d.lastActive.UnixMilli()})
+	}
+	hub.broadcastStatus(chatID)
+}
+
+// depart remembers when the user of p was last seen, so they are shown as
+// offline. Must be called with hub.mu held.
+func (hub *ChatHub) depart(chatID string, p UserPresence) {
+	if hub.departed[chatID] == nil {
+		hub.departed[chatID] = make(map[int]UserPresence)
+	}
+	p.State, p.Devices = StateOffline, 0
+	hub.departed[chatID][p.GuestID] = p
+}
+
+// typing relays a typing indicator to the chat on all instances.
+func (hub *ChatHub) typing(chatID string, user auth.Identity, typing bool) {
+	data, err := encodeFrame(FrameTyping, "", TypingEvent{
+		ChatID:    chatID,
+		GuestID:   user.GuestID,
+		Username:  user.Username,
+		Typing:    typing,
+		ExpiresIn: int(typingTTL / time.Second),
+	})
+	if err != nil {
+		log.Printf("Error marshaling typing event: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	hub.sendToChat(chatID, data)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventFrame, ChatID: chatID, Frame: data})
+}
+
+// receivePresence stores the presence announced by another instance. Users
+// who disappeared from it are shown as offline. Must be called with hub.mu held.
+func (hub *ChatHub) receivePresence(ev Event) {
+	prev := hub.remote[ev.ChatID][ev.Origin]
+	if len(ev.Presence) == 0 {
+		delete(hub.remote[ev.ChatID], ev.Origin)
+		if len(hub.remote[ev.ChatID]) == 0 {
+			delete(hub.remote, ev.ChatID)
+		}
+	} else {
+		if hub.remote[ev.ChatID] == nil {
+			hub.remote[ev.ChatID] = make(map[string]remotePresence)
+		}
+		hub.remote[ev.ChatID][ev.Origin] = remotePresence{users: ev.Presence, seen: time.Now()}
+	}
+	if _, local := hub.chats[ev.ChatID]; !local {
+		return
+	}
+	hub.markDeparted(ev.ChatID, prev.users)
+	hub.broadcastStatus(ev.ChatID)
+}
+
+// markDeparted records users of gone that are no longer present anywhere.
+// Must be called with hub.mu held.
+func (hub *ChatHub) markDeparted(chatID string, gone []UserPresence) {
+	if len(gone) == 0 {
+		return
+	}
+	present := make(map[int]bool)
+	for _, p := range hub.presence(chatID) {
+		if p.State != StateOffline {
+			present[p.GuestID] = true
+		}
+	}
+	for _, p := range gone {
+		if !present[p.GuestID] {
+			hub.depart(chatID, p)
+		}
+	}
+}
+
+// announce publishes the presence of users connected to chatID on this instance.
+func (hub *ChatHub) announce(chatID string) {
+	hub.mu.Lock()
+	users := hub.localPresence(chatID)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventPresence, ChatID: chatID, Presence: users})
+}
+
+// announceAll publishes the presence of every chat with local clients.
+func (hub *ChatHub) announceAll() {
+	hub.mu.Lock()
+	chatIDs := make([]string, 0, len(hub.chats))
+	for chatID := range hub.chats {
+		chatIDs = append(chatIDs, chatID)
+	}
+	hub.mu.Unlock()
+	for _, chatID := range chatIDs {
+		hub.announce(chatID)
+	}
+}
+
+// presenceLoop turns inactive devices idle, keeps this instance's presence
+// fresh on other instances and forgets users of instances that stopped
+// announcing (e.g. crashed).
+func (hub *ChatHub) presenceLoop() {
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		hub.announceAll()
+
+		hub.mu.Lock()
+		for chatID, instances := range hub.remote {
+			var gone []UserPresence
+			for instance, p := range instances {
+				if time.Since(p.seen) > presenceTTL {
+					delete(instances, instance)
+					gone = append(gone, p.users...)
+				}
+			}
+			if len(instances) == 0 {
+				delete(hub.remote, chatID)
+			}
+			if _, local := hub.chats[chatID]; local {
+				hub.markDeparted(chatID, gone)
+			}
+		}
+		for chatID := range hub.chats {
+			hub.broadcastStatus(chatID) // Sends only if a device became idle.
+		}
+		hub.mu.Unlock()
+	}
+}
@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"testing"
+	"time"
+)
+
+// A renamed user whose older tab still carries the old name is one user,
+// and closing one tab keeps them online.
+func TestPresenceRenamedUser(t *testing.T) {
+	hub := NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	const chatID = "support:1"
+	old, renamed, other := newTestClient(1, "alice"), newTestClient(1, "alicia"), newTestClient(2, "alice")
+	now := time.Now()
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.chats[chatID] = map[*client]*device{
+		old:     {lastActive: now.Add(-time.Minute)},
+		renamed: {lastActive: now},
+		other:   {lastActive: now},
+	}
+
+	users := hub.presence(chatID)
+	if len(users) != 2 {
+		t.Fatalf("presence = %+v, want two users", users)
+	}
+	for _, u := range users {
+		if u.GuestID == 1 && (u.Username != "alicia" || u.Devices != 2) {
+			t.Errorf("guest 1 = %+v, want alicia with two devices", u)
+		}
+	}
+
+	hub.leave(renamed, chatID)
+	hub.leave(other, chatID)
+	for _, u := range hub.presence(chatID) {
+		switch {
+		case u.GuestID == 1 && u.State == StateOffline:
+			t.Errorf("guest 1 offline with a tab still open: %+v", u)
+		case u.GuestID == 2 && u.State != StateOffline:
+			t.Errorf("guest 2 = %+v, want offline", u)
+		}
+	}
+}
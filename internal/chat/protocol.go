@@ -0,0 +1,135 @@
+package chat
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// ProtocolV1 is the chat WebSocket subprotocol. Every frame in either direction
+// is an Envelope. Clients select it with the Sec-WebSocket-Protocol header;
+// a client that offers no subprotocol gets the latest version.
+const ProtocolV1 = "chat.v1"
+
+// protocols lists supported subprotocols, most preferred first.
+var protocols = []string{ProtocolV1}
+
+// Frame types.
+const (
+	// Client to server.
+	FrameSend      = "message.send"   // payload: SendMessage
+	FrameReceipt   = "receipt"        // payload: ReceiptUpdate
+	FrameHeartbeat = "heartbeat"      // payload: Heartbeat, at least every idleAfter
+	FrameTyping    = "typing"         // payload: Typing; relayed as TypingEvent, never stored
+	FrameEdit      = "message.edit"   // payload: EditMessage, own messages only
+	FrameDelete    = "message.delete" // payload: DeleteMessage, own messages or any for operators
+
+	// Server to client.
+	FrameState       = "state"           // payload: ChatState, sent once after connecting
+	FrameUnread      = "unread"          // payload: UnreadSummary, sent once after the state
+	FrameMessage     = "message"         // payload: Message
+	FrameUpdated     = "message.updated" // payload: Message after an edit or deletion
+	FrameStatus      = "status"          // payload: StatusMessage
+	FrameReceipts    = "receipts"        // payload: ReceiptsMessage
+	FrameTicket      = "ticket"          // payload: support.Change, support chats only
+	FrameRestriction = "restriction"     // payload: Restriction, sent to the restricted user only
+	FrameWarning     = "warning"         // payload: Warning, e.g. before a flood mute
+	FrameAck         = "ack"             // payload: Ack; id echoes the acknowledged frame
+	FrameError       = "error"           // payload: ErrorPayload; id echoes the offending frame, if any
+)
+
+// Error codes sent in error frames.
+const (
+	ErrCodeBadFrame       = "bad_frame"       // not a JSON envelope
+	ErrCodeUnknownType    = "unknown_type"    // unsupported frame type
+	ErrCodeInvalidPayload = "invalid_payload" // payload does not match the frame type
+	ErrCodeForbidden      = "forbidden"       // frame targets another chat or another user's message
+	ErrCodeNotFound       = "not_found"       // the referenced message does not exist
+	ErrCodeMuted          = "muted"           // the user is muted in the chat
+	ErrCodeFiltered       = "filtered"        // the text was rejected by the chat filter
+	ErrCodeRateLimited    = "rate_limited"    // too many frames, the frame was dropped
+	ErrCodeInternal       = "internal"        // server-side failure, the frame may be retried
+)
+
+// CloseBanned is the WebSocket close code sent to connections of a user banned from the chat.
+const CloseBanned = 4003
+
+// closeTokenExpired is the close reason sent with websocket.ClosePolicyViolation
+// when the access token of the connection expires; clients reconnect with a
+// fresh token.
+const closeTokenExpired = "token expired"
+
+// Envelope wraps every frame. ID is chosen by the sender of a client frame
+// and echoed in the matching ack or error; server-initiated frames have no ID.
+type Envelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SendMessage is the payload of a message.send frame.
+type SendMessage struct {
+	ChatID        string   `json:"chat_id,omitempty"` // Defaults to the chat of the connection.
+	Text          string   `json:"text"`
+	AttachmentIDs []string `json:"attachment_ids,omitempty"` // Uploaded via POST /chats/{id}/attachments.
+}
+
+// EditMessage is the payload of a message.edit frame.
+type EditMessage struct {
+	MessageID string `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+// DeleteMessage is the payload of a message.delete frame.
+type DeleteMessage struct {
+	MessageID string `json:"message_id"`
+}
+
+// Ack confirms that a sent message was stored.
+type Ack struct {
+	MessageID string `json:"message_id"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// ErrorPayload describes a rejected frame.
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// maxMessageLength is the maximum length of a message text in bytes.
+const maxMessageLength = 4000
+
+// encodeFrame wraps payload in an Envelope.
+func encodeFrame(frameType, id string, payload interface{}) ([]byte, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{Type: frameType, ID: id, Payload: raw})
+}
+
+// errorFrame encodes an error frame; it cannot fail.
+func errorFrame(id, code, message string) []byte {
+	data, _ := encodeFrame(FrameError, id, ErrorPayload{Code: code, Message: message})
+	return data
+}
+
+// negotiateProtocol picks the subprotocol for r. ok is false if the client
+// offered subprotocols, but none of them is supported.
+func negotiateProtocol(r *http.Request) (protocol string, ok bool) {
+	offered := websocket.Subprotocols(r)
+	if len(offered) == 0 {
+		return protocols[0], true
+	}
+	for _, p := range protocols {
+		for _, o := range offered {
+			if strings.EqualFold(o, p) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
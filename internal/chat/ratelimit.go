@@ -0,0 +1,284 @@
+package chat
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Frame classes with separate rate limits.
+const (
+	limitMessages = iota // message.send, message.edit, message.delete
+	limitReads           // receipt
+	limitTyping          // typing
+	limitClasses
+)
+
+var limitNames = [limitClasses]string{"message", "read", "typing"}
+
+// frameClass returns the rate limit class of a client frame type.
+func frameClass(frameType string) (int, bool) {
+	switch frameType {
+	case FrameSend, FrameEdit, FrameDelete:
+		return limitMessages, true
+	case FrameReceipt:
+		return limitReads, true
+	case FrameTyping:
+		return limitTyping, true
+	}
+	return 0, false
+}
+
+const (
+	// escalationGrace is the time a client has to slow down after a warning
+	// or mute before the next violation escalates further. Frames exceeding
+	// the limits in the meantime are only dropped.
+	escalationGrace = 5 * time.Second
+	// escalationReset is the time without violations after which a
+	// connection starts again from a warning.
+	escalationReset = 10 * time.Minute
+	// userBucketTTL is how long per-user buckets are kept after the last
+	// frame; full buckets carry no state.
+	userBucketTTL = 10 * time.Minute
+)
+
+// Escalation levels of a connection exceeding its rate limits.
+const (
+	escalateWarn = iota + 1
+	escalateMute
+	escalateDisconnect
+)
+
+// RateLimit is a token bucket: up to Burst frames at once, refilled at
+// PerSecond. A zero PerSecond disables the limit.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ClassLimit limits a frame class per connection and per user across all
+// connections of the user to this instance.
+type ClassLimit struct {
+	Connection RateLimit
+	User       RateLimit
+}
+
+// RateLimits configures flood protection of chat connections. A connection
+// exceeding a limit gets a warning frame first, then its user is muted in
+// the chat for MuteFor, and finally the connection is closed. A zero MuteFor
+// skips the mute.
+type RateLimits struct {
+	Messages ClassLimit
+	Reads    ClassLimit
+	Typing   ClassLimit
+	Frames   RateLimit // All frames of a connection, including heartbeats and malformed ones.
+	MuteFor  time.Duration
+}
+
+// DefaultRateLimits are used unless UseRateLimits is called.
+var DefaultRateLimits = RateLimits{
+	Messages: ClassLimit{Connection: RateLimit{1, 5}, User: RateLimit{2, 10}},
+	Reads:    ClassLimit{Connection: RateLimit{5, 20}, User: RateLimit{10, 40}},
+	Typing:   ClassLimit{Connection: RateLimit{2, 5}, User: RateLimit{4, 10}},
+	Frames:   RateLimit{20, 60},
+	MuteFor:  5 * time.Minute,
+}
+
+// class returns the limits of a frame class.
+func (l RateLimits) class(class int) ClassLimit {
+	switch class {
+	case limitMessages:
+		return l.Messages
+	case limitReads:
+		return l.Reads
+	}
+	return l.Typing
+}
+
+// UseRateLimits replaces DefaultRateLimits. It must be called before Run.
+func (hub *ChatHub) UseRateLimits(l RateLimits) {
+	hub.limits = l
+}
+
+// bucket is the state of a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take removes a token and reports whether one was available.
+func (b *bucket) take(l RateLimit, now time.Time) bool {
+	if l.PerSecond <= 0 {
+		return true
+	}
+	if b.last.IsZero() {
+		b.tokens = float64(l.Burst)
+	} else {
+		b.tokens = min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.PerSecond)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// userBuckets holds the per-user buckets of the hub.
+type userBuckets struct {
+	mu      sync.Mutex
+	buckets map[int]*[limitClasses]bucket // By guest ID.
+	swept   time.Time
+}
+
+// take takes a token from the user's bucket of the class.
+func (u *userBuckets) take(guestID int, class int, l RateLimit, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if now.Sub(u.swept) > userBucketTTL {
+		for id, b := range u.buckets {
+			idle := true
+			for i := range b {
+				idle = idle && now.Sub(b[i].last) > userBucketTTL
+			}
+			if idle {
+				delete(u.buckets, id)
+			}
+		}
+		u.swept = now
+	}
+	b := u.buckets[guestID]
+	if b == nil {
+		b = new([limitClasses]bucket)
+		u.buckets[guestID] = b
+	}
+	return b[class].take(l, now)
+}
+
+// connLimiter is the rate limit state of one connection. It is used only
+// by the connection's read loop.
+type connLimiter struct {
+	frames    bucket
+	classes   [limitClasses]bucket
+	level     int       // Escalation level reached.
+	escalated time.Time // Time of the last escalation.
+}
+
+// Escalation actions, as recorded for operators.
+const (
+	EscalationWarning    = "warning"
+	EscalationMute       = "mute"
+	EscalationDisconnect = "disconnect"
+)
+
+// Escalation records the response to a connection exceeding its rate
+// limits, for operators to review (see Store.Escalations).
+type Escalation struct {
+	ChatID    string `json:"chat_id"`
+	GuestID   int    `json:"guest_id"`
+	Username  string `json:"username,omitempty"` // Current username of the guest; set by the store.
+	Action    string `json:"action"`             // EscalationWarning, EscalationMute or EscalationDisconnect
+	Exceeded  string `json:"exceeded"`           // The limit: "frame", "message", "read" or "typing".
+	CreatedAt int64  `json:"created_at"`         // Unix ms.
+}
+
+// Warning is the payload of a warning frame.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// allowFrame checks a frame of c against the rate limits and reports
+// whether it may be processed. Rejected frames are dropped, with an error
+// frame if they have an ID, and escalate the response to the connection.
+// frameType is empty for malformed frames.
+func (hub *ChatHub) allowFrame(c *client, chatID string, l *connLimiter, frameType, ref string) bool {
+	now := time.Now()
+	exceeded := ""
+	if !l.frames.take(hub.limits.Frames, now) {
+		exceeded = "frame"
+	} else if class, ok := frameClass(frameType); ok {
+		limit := hub.limits.class(class)
+		if !l.classes[class].take(limit.Connection, now) ||
+			!hub.userBuckets.take(c.identity.GuestID, class, limit.User, now) {
+			exceeded = limitNames[class]
+		}
+	}
+	if exceeded == "" {
+		return true
+	}
+	if ref != "" {
+		c.enqueue(errorFrame(ref, ErrCodeRateLimited, "too many "+exceeded+" frames, slow down"))
+	}
+	if l.level > 0 && now.Sub(l.escalated) > escalationReset {
+		l.level = 0
+	}
+	if l.level > 0 && now.Sub(l.escalated) < escalationGrace {
+		return false
+	}
+	l.level++
+	l.escalated = now
+	hub.escalate(c, chatID, l.level, exceeded, now)
+	return false
+}
+
+// escalate responds to a connection exceeding its rate limits.
+func (hub *ChatHub) escalate(c *client, chatID string, level int, exceeded string, now time.Time) {
+	username := c.identity.Username
+	switch level {
+	case escalateWarn:
+		log.Printf("Rate limit: client %d (%s) in chat %s exceeded the %s limit, warned", c.identity.GuestID, username, chatID, exceeded)
+		data, err := encodeFrame(FrameWarning, "", Warning{
+			Code:    ErrCodeRateLimited,
+			Message: "too many " + exceeded + " frames; keep sending at this rate and you will be muted",
+		})
+		if err == nil {
+			c.enqueue(data)
+		}
+		hub.recordEscalation(c, chatID, EscalationWarning, exceeded, now)
+
+	case escalateMute:
+		if hub.limits.MuteFor <= 0 {
+			return
+		}
+		// An existing mute, e.g. set by an operator, is not shortened.
+		if r, err := hub.restriction(chatID, c.identity.GuestID, RestrictMute); err != nil || r != nil {
+			log.Printf("Rate limit: client %d (%s) in chat %s exceeded the %s limit again, already muted", c.identity.GuestID, username, chatID, exceeded)
+			return
+		}
+		hub.recordEscalation(c, chatID, EscalationMute, exceeded, now)
+		log.Printf("Rate limit: client %d (%s) in chat %s exceeded the %s limit again, muting for %s", c.identity.GuestID, username, chatID, exceeded, hub.limits.MuteFor)
+		_, err := hub.Restrict(Restriction{
+			ChatID:    chatID,
+			GuestID:   c.identity.GuestID,
+			Kind:      RestrictMute,
+			Reason:    "flood",
+			CreatedBy: SystemSender,
+			ExpiresAt: now.Add(hub.limits.MuteFor).UnixMilli(),
+		})
+		if err != nil {
+			log.Printf("Error muting %s in chat %s: %v", username, chatID, err)
+		}
+
+	default: // escalateDisconnect
+		log.Printf("Rate limit: client %d (%s) in chat %s kept exceeding the %s limit, disconnecting", c.identity.GuestID, username, chatID, exceeded)
+		hub.recordEscalation(c, chatID, EscalationDisconnect, exceeded, now)
+		c.closeWith(websocket.ClosePolicyViolation, "rate limit exceeded")
+	}
+}
+
+// recordEscalation stores an escalation for operators.
+func (hub *ChatHub) recordEscalation(c *client, chatID, action, exceeded string, now time.Time) {
+	err := hub.store.RecordEscalation(Escalation{
+		ChatID:    chatID,
+		GuestID:   c.identity.GuestID,
+		Action:    action,
+		Exceeded:  exceeded,
+		CreatedAt: now.UnixMilli(),
+	})
+	if err != nil {
+		log.Printf("Error recording %s of client %d in chat %s: %v", action, c.identity.GuestID, chatID, err)
+	}
+}
@@ -0,0 +1,350 @@
+package chat
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-robot/internal/auth"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBucketTake(t *testing.T) {
+	t0 := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name  string
+		limit RateLimit
+		takes []time.Duration // Offsets from t0.
+		want  []bool
+	}{
+		{
+			name:  "burst",
+			limit: RateLimit{1, 3},
+			takes: []time.Duration{0, 0, 0, 0},
+			want:  []bool{true, true, true, false},
+		},
+		{
+			name:  "refill",
+			limit: RateLimit{1, 2},
+			takes: []time.Duration{0, 0, 0, time.Second, time.Second},
+			want:  []bool{true, true, false, true, false},
+		},
+		{
+			name:  "refill capped at burst",
+			limit: RateLimit{1, 2},
+			takes: []time.Duration{0, 0, time.Hour, time.Hour, time.Hour},
+			want:  []bool{true, true, true, true, false},
+		},
+		{
+			name:  "fractional rate",
+			limit: RateLimit{0.5, 1},
+			takes: []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second},
+			want:  []bool{true, false, true, false},
+		},
+		{
+			name:  "zero burst",
+			limit: RateLimit{10, 0},
+			takes: []time.Duration{0, time.Second},
+			want:  []bool{false, false},
+		},
+		{
+			name:  "unlimited",
+			limit: RateLimit{0, 0},
+			takes: []time.Duration{0, 0, 0, 0},
+			want:  []bool{true, true, true, true},
+		},
+	}
+	for _, tt := range tests {
+		var b bucket
+		for i, offset := range tt.takes {
+			if got := b.take(tt.limit, t0.Add(offset)); got != tt.want[i] {
+				t.Errorf("%s: take #%d at +%s = %v, want %v", tt.name, i+1, offset, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestUserBuckets(t *testing.T) {
+	t0 := time.Unix(1_700_000_000, 0)
+	u := userBuckets{buckets: make(map[int]*[limitClasses]bucket)}
+	limit := RateLimit{1, 1}
+	const alice, bob, carol = 1, 2, 3
+
+	if !u.take(alice, limitMessages, limit, t0) {
+		t.Fatal("first message of alice rejected")
+	}
+	if u.take(alice, limitMessages, limit, t0) {
+		t.Error("second message of alice within a second allowed")
+	}
+	// Classes and users have separate buckets.
+	if !u.take(alice, limitTyping, limit, t0) {
+		t.Error("typing of alice rejected after her message limit ran out")
+	}
+	if !u.take(bob, limitMessages, limit, t0) {
+		t.Error("message of bob rejected after alice's limit ran out")
+	}
+
+	// A user active in any class within the TTL keeps the buckets.
+	u.take(bob, limitReads, limit, t0.Add(userBucketTTL/2))
+	later := t0.Add(userBucketTTL + time.Second)
+	u.take(carol, limitMessages, limit, later)
+	if _, ok := u.buckets[alice]; ok {
+		t.Error("idle buckets of alice were not swept")
+	}
+	if _, ok := u.buckets[bob]; !ok {
+		t.Error("buckets of bob were swept while in use")
+	}
+	if _, ok := u.buckets[carol]; !ok {
+		t.Error("buckets of carol were not created")
+	}
+}
+
+func TestFrameClass(t *testing.T) {
+	tests := []struct {
+		frameType string
+		class     int
+		limited   bool
+	}{
+		{frameType: FrameSend, class: limitMessages, limited: true},
+		{frameType: FrameEdit, class: limitMessages, limited: true},
+		{frameType: FrameDelete, class: limitMessages, limited: true},
+		{frameType: FrameReceipt, class: limitReads, limited: true},
+		{frameType: FrameTyping, class: limitTyping, limited: true},
+		{frameType: "", limited: false},
+		{frameType: "unknown", limited: false},
+	}
+	for _, tt := range tests {
+		class, ok := frameClass(tt.frameType)
+		if ok != tt.limited || class != tt.class {
+			t.Errorf("frameClass(%q) = %d, %v; want %d, %v", tt.frameType, class, ok, tt.class, tt.limited)
+		}
+	}
+}
+
+// newTestClient returns a client without a connection; frames queued to it
+// stay in send.
+func newTestClient(guestID int, username string) *client {
+	return &client{
+		identity: auth.Identity{GuestID: guestID, Username: username},
+		send:     make(chan []byte, sendBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// drainFrames returns the frames queued to c.
+func drainFrames(t *testing.T, c *client) []Envelope {
+	t.Helper()
+	var frames []Envelope
+	for {
+		select {
+		case data := <-c.send:
+			var env Envelope
+			if err := json.Unmarshal(data, &env); err != nil {
+				t.Fatalf("invalid frame %s: %v", data, err)
+			}
+			frames = append(frames, env)
+		default:
+			return frames
+		}
+	}
+}
+
+// frameTypes returns the types of frames, with the error code for error frames.
+func frameTypes(t *testing.T, frames []Envelope) []string {
+	t.Helper()
+	var types []string
+	for _, f := range frames {
+		if f.Type != FrameError {
+			types = append(types, f.Type)
+			continue
+		}
+		var p ErrorPayload
+		if err := json.Unmarshal(f.Payload, &p); err != nil {
+			t.Fatalf("invalid error payload %s: %v", f.Payload, err)
+		}
+		types = append(types, f.Type+":"+p.Code)
+	}
+	return types
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pastGrace moves the last escalation of l back beyond escalationGrace.
+func pastGrace(l *connLimiter) {
+	l.escalated = l.escalated.Add(-escalationGrace - time.Second)
+}
+
+func TestAllowFrameEscalation(t *testing.T) {
+	const chatID = "support:1"
+	hub := NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	hub.UseRateLimits(RateLimits{
+		Messages: ClassLimit{Connection: RateLimit{0.001, 1}},
+		Frames:   RateLimit{1000, 1000},
+		MuteFor:  time.Minute,
+	})
+	c := newTestClient(1, "guest")
+	l := &connLimiter{}
+	rateLimited := FrameError + ":" + ErrCodeRateLimited
+
+	steps := []struct {
+		name   string
+		before func()
+		ref    string
+		allow  bool
+		frames []string
+		level  int
+	}{
+		{name: "within limit", ref: "r1", allow: true},
+		{name: "warning", ref: "r2", frames: []string{rateLimited, FrameWarning}, level: escalateWarn},
+		{name: "dropped within grace", ref: "r3", frames: []string{rateLimited}, level: escalateWarn},
+		{name: "dropped without ref", frames: nil, level: escalateWarn},
+		{name: "mute", before: func() { pastGrace(l) }, ref: "r4", frames: []string{rateLimited}, level: escalateMute},
+		{name: "disconnect", before: func() { pastGrace(l) }, ref: "r5", frames: []string{rateLimited}, level: escalateDisconnect},
+	}
+	for _, st := range steps {
+		if st.before != nil {
+			st.before()
+		}
+		if got := hub.allowFrame(c, chatID, l, FrameSend, st.ref); got != st.allow {
+			t.Fatalf("%s: allowFrame = %v, want %v", st.name, got, st.allow)
+		}
+		if got := frameTypes(t, drainFrames(t, c)); !equalStrings(got, st.frames) {
+			t.Errorf("%s: frames %q, want %q", st.name, got, st.frames)
+		}
+		if l.level != st.level {
+			t.Errorf("%s: level %d, want %d", st.name, l.level, st.level)
+		}
+		if st.level == escalateMute {
+			r, err := hub.restriction(chatID, 1, RestrictMute)
+			if err != nil || r == nil {
+				t.Fatalf("%s: mute = %v, %v; want a mute", st.name, r, err)
+			}
+			if r.CreatedBy != SystemSender || r.Reason != "flood" {
+				t.Errorf("%s: mute by %q for %q, want %q for %q", st.name, r.CreatedBy, r.Reason, SystemSender, "flood")
+			}
+		}
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("client not closed after the last escalation")
+	}
+	if c.closeCode != websocket.ClosePolicyViolation {
+		t.Errorf("close code %d, want %d", c.closeCode, websocket.ClosePolicyViolation)
+	}
+	// Operators see every escalation, newest first.
+	escalations, err := hub.store.Escalations(chatID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range escalations {
+		if e.GuestID != 1 || e.Exceeded != "message" {
+			t.Errorf("escalation %+v, want one of guest 1 for the message limit", e)
+		}
+		actions = append(actions, e.Action)
+	}
+	if want := []string{EscalationDisconnect, EscalationMute, EscalationWarning}; !equalStrings(actions, want) {
+		t.Errorf("escalations %q, want %q", actions, want)
+	}
+}
+
+func TestAllowFrameEscalationReset(t *testing.T) {
+	hub := NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	hub.UseRateLimits(RateLimits{
+		Typing: ClassLimit{Connection: RateLimit{0.001, 1}},
+		Frames: RateLimit{1000, 1000},
+	})
+	c := newTestClient(1, "guest")
+	l := &connLimiter{classes: [limitClasses]bucket{limitTyping: {last: time.Now()}}}
+
+	// A connection that last escalated long ago starts again from a warning.
+	l.level = escalateMute
+	l.escalated = time.Now().Add(-escalationReset - time.Second)
+	if hub.allowFrame(c, "support:1", l, FrameTyping, "") {
+		t.Fatal("typing frame over the limit allowed")
+	}
+	if l.level != escalateWarn {
+		t.Errorf("level %d after reset, want %d", l.level, escalateWarn)
+	}
+	if got := frameTypes(t, drainFrames(t, c)); !equalStrings(got, []string{FrameWarning}) {
+		t.Errorf("frames %q, want a warning", got)
+	}
+}
+
+func TestAllowFrameMute(t *testing.T) {
+	const chatID = "support:1"
+	limits := RateLimits{
+		Messages: ClassLimit{Connection: RateLimit{0.001, 1}},
+		Frames:   RateLimit{1000, 1000},
+		MuteFor:  time.Minute,
+	}
+	muteAt := func(hub *ChatHub, c *client) {
+		l := &connLimiter{classes: [limitClasses]bucket{limitMessages: {last: time.Now()}}}
+		l.level, l.escalated = escalateWarn, time.Now()
+		pastGrace(l)
+		hub.allowFrame(c, chatID, l, FrameSend, "")
+	}
+
+	// No mute without MuteFor.
+	hub := NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	noMute := limits
+	noMute.MuteFor = 0
+	hub.UseRateLimits(noMute)
+	muteAt(hub, newTestClient(1, "guest"))
+	if r, err := hub.restriction(chatID, 1, RestrictMute); err != nil || r != nil {
+		t.Errorf("mute with zero MuteFor = %v, %v; want none", r, err)
+	}
+
+	// An existing longer mute is kept.
+	hub = NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	hub.UseRateLimits(limits)
+	expires := time.Now().Add(time.Hour).UnixMilli()
+	if _, err := hub.Restrict(Restriction{ChatID: chatID, GuestID: 1, Kind: RestrictMute, CreatedBy: "operator", ExpiresAt: expires}); err != nil {
+		t.Fatal(err)
+	}
+	muteAt(hub, newTestClient(1, "guest"))
+	r, err := hub.restriction(chatID, 1, RestrictMute)
+	if err != nil || r == nil {
+		t.Fatalf("mute = %v, %v; want the operator's mute", r, err)
+	}
+	if r.CreatedBy != "operator" || r.ExpiresAt != expires {
+		t.Errorf("mute by %q until %d, want the operator's until %d", r.CreatedBy, r.ExpiresAt, expires)
+	}
+}
+
+func TestAllowFrameUserLimit(t *testing.T) {
+	hub := NewChatHub(NewMemoryStore(), NewMemoryBroker())
+	hub.UseRateLimits(RateLimits{
+		Messages: ClassLimit{Connection: RateLimit{1000, 1000}, User: RateLimit{0.001, 2}},
+		Frames:   RateLimit{1000, 1000},
+	})
+	// The user limit is shared by all connections of the user, also after a
+	// rename, and not with another user who took the old name.
+	first, second, other := newTestClient(1, "guest"), newTestClient(1, "renamed"), newTestClient(2, "guest")
+	if !hub.allowFrame(first, "support:1", &connLimiter{}, FrameSend, "") ||
+		!hub.allowFrame(second, "support:1", &connLimiter{}, FrameSend, "") {
+		t.Fatal("messages within the user limit rejected")
+	}
+	if hub.allowFrame(second, "support:1", &connLimiter{}, FrameSend, "") {
+		t.Error("message over the user limit allowed on a new connection")
+	}
+	if !hub.allowFrame(other, "support:1", &connLimiter{}, FrameSend, "") {
+		t.Error("message of another user rejected")
+	}
+	// Frames outside the limited classes only count against Frames.
+	if !hub.allowFrame(second, "support:1", &connLimiter{}, "", "") {
+		t.Error("malformed frame rejected by the message limit")
+	}
+}
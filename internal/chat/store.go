@@ -0,0 +1,568 @@
+package chat
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+// HistoryLimit is the number of recent messages sent to a client on join.
+const HistoryLimit = 50
+
+// Store persists chats, their participants, messages, receipts and moderation.
+// Participants are identified by guest ID: usernames may change and be taken
+// by another guest.
+type Store interface {
+	// Join creates the chat if needed and records the participant.
+	Join(chatID string, user auth.Identity) error
+	// Leave removes the participant; their receipts are kept.
+	Leave(chatID string, guestID int) error
+	// Save stores a message. msg.ID must already be set (see NewMessageID).
+	// Attachments of the message are linked to it atomically; if any of them
+	// is missing, belongs to another chat or sender (by SenderID), or is already linked,
+	// nothing is saved and ErrAttachmentUnavailable is returned.
+	Save(msg *Message) error
+	// History returns up to limit messages older than beforeID ("" means newest),
+	// in chronological order, with their receipts and attachments. Deleted
+	// messages are returned without text and attachments.
+	History(chatID, beforeID string, limit int) ([]Message, error)
+	// Message returns a message with its attachments, including the content
+	// of a deleted message, or ErrMessageNotFound.
+	Message(chatID, id string) (Message, error)
+	// Edit replaces the text of a message and keeps the previous text as a
+	// Revision. It returns ErrMessageDeleted for deleted messages.
+	Edit(chatID, id, text string, editedAt int64) (Message, error)
+	// Delete marks a message deleted by the given user. It returns
+	// ErrMessageDeleted if the message is already deleted.
+	Delete(chatID, id, by string, deletedAt int64) (Message, error)
+	// Revisions returns the previous texts of a message, oldest first.
+	Revisions(chatID, id string) ([]Revision, error)
+	// MarkDelivered records that the guest received the messages and returns
+	// the receipts that changed. Messages of other chats and the guest's own
+	// messages are ignored.
+	MarkDelivered(chatID string, guestID int, messageIDs []string) ([]Receipt, error)
+	// MarkRead records that the guest read the messages (which implies delivery)
+	// and returns the receipts that changed.
+	MarkRead(chatID string, guestID int, messageIDs []string) ([]Receipt, error)
+	// Unread returns, per chat the guest participates in, the number of
+	// messages from others that the guest has not read. Chats without unread
+	// messages are omitted. Like UnreadSummary, Undelivered and
+	// PendingNotifications, it skips messages sent before the guest joined
+	// the chat (see Join).
+	Unread(guestID int) (map[string]int, error)
+	// UnreadSummary returns, per chat with unread messages, their number and
+	// the newest of them, newest chats first.
+	UnreadSummary(guestID int) ([]ChatUnread, error)
+	// Undelivered returns up to limit messages from others in the chat that
+	// the guest has no delivery receipt for, oldest first.
+	Undelivered(chatID string, guestID int, limit int) ([]Message, error)
+	// PendingNotifications returns, per participant and chat, the messages
+	// from others sent in [sentAfter, sentBefore) (unix ms) that the
+	// participant has neither read nor been notified about. System messages
+	// and deleted messages are skipped.
+	PendingNotifications(sentAfter, sentBefore int64) ([]Notification, error)
+	// MarkNotified records that the guest was notified about the messages and
+	// returns the IDs that were not marked before.
+	MarkNotified(guestID int, messageIDs []string) ([]string, error)
+	// UnmarkNotified releases marks of MarkNotified, e.g. after the
+	// notification could not be delivered.
+	UnmarkNotified(guestID int, messageIDs []string) error
+	// SaveAttachment records an uploaded attachment not yet linked to a message.
+	SaveAttachment(a *Attachment) error
+	// Attachment returns an attachment by ID, or ErrAttachmentNotFound,
+	// also if its message was deleted.
+	Attachment(id string) (Attachment, error)
+	// PendingAttachments counts the uploads of the guest not linked to a message.
+	PendingAttachments(uploaderID int) (int, error)
+	// DeleteUnsentAttachments deletes the attachments created before the
+	// time (unix ms) and not linked to a message, and returns them.
+	DeleteUnsentAttachments(before int64) ([]Attachment, error)
+	// Restrict adds a restriction, replacing one of the same kind for the
+	// guest, and sets r.Username. It returns ErrInvalidRestriction if the
+	// guest does not exist.
+	Restrict(r *Restriction) error
+	// Unrestrict lifts a restriction, or returns ErrRestrictionNotFound.
+	Unrestrict(chatID string, guestID int, kind string) error
+	// Restrictions returns the unexpired restrictions in the chat, of one
+	// guest or of all guests if guestID is 0.
+	Restrictions(chatID string, guestID int) ([]Restriction, error)
+	// RecordEscalation stores a response to a flooding connection.
+	RecordEscalation(e Escalation) error
+	// Escalations returns up to limit escalations in the chat, newest first.
+	Escalations(chatID string, limit int) ([]Escalation, error)
+}
+
+// MemoryStore is a process-local Store, useful for development without Postgres.
+type MemoryStore struct {
+	mu       sync.Mutex
+	messages map[string][]Message
+	members  map[string]map[int]int64            // Join time (unix ms) by chat ID and guest ID.
+	names    map[int]string                      // Last known username by guest ID, see Join.
+	receipts map[string]map[int]*Receipt         // By message ID and guest ID.
+	files    map[string]*Attachment              // Attachments by ID.
+	revs     map[string][]Revision               // By message ID.
+	limits   map[string]map[limitKey]Restriction // By chat ID.
+	notified map[string]map[int]bool             // By message ID and guest ID.
+	flood    map[string][]Escalation             // By chat ID, oldest first.
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages: make(map[string][]Message),
+		members:  make(map[string]map[int]int64),
+		names:    make(map[int]string),
+		receipts: make(map[string]map[int]*Receipt),
+		files:    make(map[string]*Attachment),
+		revs:     make(map[string][]Revision),
+		limits:   make(map[string]map[limitKey]Restriction),
+		notified: make(map[string]map[int]bool),
+		flood:    make(map[string][]Escalation),
+	}
+}
+
+// Join records the participant and their current username.
+func (s *MemoryStore) Join(chatID string, user auth.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.members[chatID] == nil {
+		s.members[chatID] = make(map[int]int64)
+	}
+	if _, ok := s.members[chatID][user.GuestID]; !ok {
+		s.members[chatID][user.GuestID] = time.Now().UnixMilli()
+	}
+	s.names[user.GuestID] = user.Username
+	return nil
+}
+
+// Leave removes the participant.
+func (s *MemoryStore) Leave(chatID string, guestID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.members[chatID], guestID)
+	return nil
+}
+
+// Save appends the message to the chat history and links its attachments.
+func (s *MemoryStore) Save(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg.Kind == "" {
+		msg.Kind = KindUser
+	}
+	for _, a := range msg.Attachments {
+		f := s.files[a.ID]
+		if f == nil || f.ChatID != msg.ChatID || f.UploaderID != msg.SenderID || f.MessageID != "" {
+			return ErrAttachmentUnavailable
+		}
+	}
+	for i, a := range msg.Attachments {
+		s.files[a.ID].MessageID = msg.ID
+		msg.Attachments[i] = s.files[a.ID].withURLs()
+	}
+	stored := *msg
+	stored.Attachments = append([]Attachment(nil), msg.Attachments...)
+	s.messages[msg.ChatID] = append(s.messages[msg.ChatID], stored)
+	return nil
+}
+
+// History returns a page of messages in chronological order.
+func (s *MemoryStore) History(chatID, beforeID string, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.messages[chatID]
+	end := len(all)
+	if beforeID != "" {
+		end = sort.Search(len(all), func(i int) bool { return all[i].ID >= beforeID })
+	}
+	start := end - limit
+	if start < 0 {
+		start = 0
+	}
+	page := make([]Message, end-start)
+	copy(page, all[start:end])
+	for i := range page {
+		page[i] = page[i].redacted()
+		page[i].Receipts = s.receiptsOf(page[i].ID)
+	}
+	return page, nil
+}
+
+// find returns the stored message. Must be called with s.mu held.
+func (s *MemoryStore) find(chatID, id string) (*Message, error) {
+	all := s.messages[chatID]
+	i := sort.Search(len(all), func(i int) bool { return all[i].ID >= id })
+	if i == len(all) || all[i].ID != id {
+		return nil, ErrMessageNotFound
+	}
+	return &all[i], nil
+}
+
+// Message returns a message by ID.
+func (s *MemoryStore) Message(chatID, id string) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.find(chatID, id)
+	if err != nil {
+		return Message{}, err
+	}
+	return *m, nil
+}
+
+// Edit replaces the text of a message.
+func (s *MemoryStore) Edit(chatID, id, text string, editedAt int64) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.find(chatID, id)
+	if err != nil {
+		return Message{}, err
+	}
+	if m.DeletedAt != 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	since := m.Timestamp
+	if m.EditedAt != 0 {
+		since = m.EditedAt
+	}
+	s.revs[id] = append(s.revs[id], Revision{Text: m.Text, Timestamp: since})
+	m.Text, m.EditedAt = text, editedAt
+	return *m, nil
+}
+
+// Delete marks a message deleted.
+func (s *MemoryStore) Delete(chatID, id, by string, deletedAt int64) (Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.find(chatID, id)
+	if err != nil {
+		return Message{}, err
+	}
+	if m.DeletedAt != 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	m.DeletedAt, m.DeletedBy = deletedAt, by
+	return *m, nil
+}
+
+// Revisions returns the previous texts of a message.
+func (s *MemoryStore) Revisions(chatID, id string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.find(chatID, id); err != nil {
+		return nil, err
+	}
+	return append([]Revision{}, s.revs[id]...), nil
+}
+
+func (s *MemoryStore) receiptsOf(messageID string) []Receipt {
+	byUser := s.receipts[messageID]
+	if len(byUser) == 0 {
+		return nil
+	}
+	list := make([]Receipt, 0, len(byUser))
+	for _, r := range byUser {
+		r.Username = s.names[r.GuestID]
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Username < list[j].Username })
+	return list
+}
+
+// MarkDelivered records delivery receipts.
+func (s *MemoryStore) MarkDelivered(chatID string, guestID int, messageIDs []string) ([]Receipt, error) {
+	return s.mark(chatID, guestID, messageIDs, false)
+}
+
+// MarkRead records read receipts.
+func (s *MemoryStore) MarkRead(chatID string, guestID int, messageIDs []string) ([]Receipt, error) {
+	return s.mark(chatID, guestID, messageIDs, true)
+}
+
+func (s *MemoryStore) mark(chatID string, guestID int, messageIDs []string, read bool) ([]Receipt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wanted := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+	now := time.Now().UnixMilli()
+	var changed []Receipt
+	for _, m := range s.messages[chatID] {
+		if !wanted[m.ID] || m.SenderID == guestID {
+			continue
+		}
+		if s.receipts[m.ID] == nil {
+			s.receipts[m.ID] = make(map[int]*Receipt)
+		}
+		r := s.receipts[m.ID][guestID]
+		if r == nil {
+			r = &Receipt{MessageID: m.ID, GuestID: guestID}
+			s.receipts[m.ID][guestID] = r
+		}
+		r.Username = s.names[guestID]
+		updated := false
+		if r.DeliveredAt == 0 {
+			r.DeliveredAt, updated = now, true
+		}
+		if read && r.ReadAt == 0 {
+			r.ReadAt, updated = now, true
+		}
+		if updated {
+			changed = append(changed, *r)
+		}
+	}
+	return changed, nil
+}
+
+// Unread counts unread messages per chat.
+func (s *MemoryStore) Unread(guestID int) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[string]int)
+	for chatID, members := range s.members {
+		joined, ok := members[guestID]
+		if !ok {
+			continue
+		}
+		for _, m := range s.messages[chatID] {
+			if m.SenderID == guestID || m.Timestamp < joined {
+				continue
+			}
+			if r := s.receipts[m.ID][guestID]; r == nil || r.ReadAt == 0 {
+				counts[chatID]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+// UnreadSummary returns the unread messages per chat.
+func (s *MemoryStore) UnreadSummary(guestID int) ([]ChatUnread, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []ChatUnread{}
+	for chatID, members := range s.members {
+		joined, ok := members[guestID]
+		if !ok {
+			continue
+		}
+		u := ChatUnread{ChatID: chatID}
+		for _, m := range s.messages[chatID] {
+			if m.SenderID == guestID || m.Timestamp < joined {
+				continue
+			}
+			if r := s.receipts[m.ID][guestID]; r == nil || r.ReadAt == 0 {
+				u.Count++
+				u.Last = m.redacted()
+			}
+		}
+		if u.Count > 0 {
+			list = append(list, u)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Last.ID > list[j].Last.ID })
+	return list, nil
+}
+
+// Undelivered returns messages without a delivery receipt of the user.
+func (s *MemoryStore) Undelivered(chatID string, guestID int, limit int) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []Message{}
+	joined, ok := s.members[chatID][guestID]
+	if !ok {
+		return list, nil
+	}
+	for _, m := range s.messages[chatID] {
+		if len(list) == limit {
+			break
+		}
+		if m.SenderID == guestID || m.Timestamp < joined {
+			continue
+		}
+		if r := s.receipts[m.ID][guestID]; r == nil || r.DeliveredAt == 0 {
+			m = m.redacted()
+			m.Receipts = s.receiptsOf(m.ID)
+			list = append(list, m)
+		}
+	}
+	return list, nil
+}
+
+// PendingNotifications returns unread messages waiting for a notification.
+func (s *MemoryStore) PendingNotifications(sentAfter, sentBefore int64) ([]Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []Notification
+	for chatID, members := range s.members {
+		for guestID, joined := range members {
+			n := Notification{GuestID: guestID, Username: s.names[guestID], ChatID: chatID}
+			for _, m := range s.messages[chatID] {
+				if m.SenderID == guestID || m.Kind == KindSystem || m.DeletedAt != 0 || m.Timestamp < joined ||
+					m.Timestamp < sentAfter || m.Timestamp >= sentBefore || s.notified[m.ID][guestID] {
+					continue
+				}
+				if r := s.receipts[m.ID][guestID]; r == nil || r.ReadAt == 0 {
+					n.Messages = append(n.Messages, m)
+				}
+			}
+			if len(n.Messages) > 0 {
+				n.Unread = len(n.Messages)
+				list = append(list, n)
+			}
+		}
+	}
+	return list, nil
+}
+
+// MarkNotified records notifications.
+func (s *MemoryStore) MarkNotified(guestID int, messageIDs []string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var marked []string
+	for _, id := range messageIDs {
+		if s.notified[id][guestID] {
+			continue
+		}
+		if s.notified[id] == nil {
+			s.notified[id] = make(map[int]bool)
+		}
+		s.notified[id][guestID] = true
+		marked = append(marked, id)
+	}
+	return marked, nil
+}
+
+// UnmarkNotified releases notification marks.
+func (s *MemoryStore) UnmarkNotified(guestID int, messageIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range messageIDs {
+		delete(s.notified[id], guestID)
+	}
+	return nil
+}
+
+// SaveAttachment records the attachment.
+func (s *MemoryStore) SaveAttachment(a *Attachment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *a
+	s.files[a.ID] = &stored
+	return nil
+}
+
+// Attachment returns an attachment by ID.
+func (s *MemoryStore) Attachment(id string) (Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.files[id]
+	if a == nil {
+		return Attachment{}, ErrAttachmentNotFound
+	}
+	if a.MessageID != "" {
+		if m, err := s.find(a.ChatID, a.MessageID); err != nil || m.DeletedAt != 0 {
+			return Attachment{}, ErrAttachmentNotFound
+		}
+	}
+	return *a, nil
+}
+
+// PendingAttachments counts unsent uploads of the user.
+func (s *MemoryStore) PendingAttachments(uploaderID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, a := range s.files {
+		if a.UploaderID == uploaderID && a.MessageID == "" {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DeleteUnsentAttachments deletes expired unsent uploads.
+func (s *MemoryStore) DeleteUnsentAttachments(before int64) ([]Attachment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deleted []Attachment
+	for id, a := range s.files {
+		if a.MessageID == "" && a.CreatedAt < before {
+			deleted = append(deleted, *a)
+			delete(s.files, id)
+		}
+	}
+	return deleted, nil
+}
+
+// limitKey identifies a restriction of a guest within a chat.
+type limitKey struct {
+	guestID int
+	kind    string
+}
+
+// Restrict adds a restriction. Guests are not checked: the username is
+// empty if the guest never joined a chat.
+func (s *MemoryStore) Restrict(r *Restriction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limits[r.ChatID] == nil {
+		s.limits[r.ChatID] = make(map[limitKey]Restriction)
+	}
+	r.Username = s.names[r.GuestID]
+	s.limits[r.ChatID][limitKey{r.GuestID, r.Kind}] = *r
+	return nil
+}
+
+// Unrestrict lifts a restriction.
+func (s *MemoryStore) Unrestrict(chatID string, guestID int, kind string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := limitKey{guestID, kind}
+	r, ok := s.limits[chatID][key]
+	if !ok || !r.Active(time.Now()) {
+		return ErrRestrictionNotFound
+	}
+	delete(s.limits[chatID], key)
+	return nil
+}
+
+// Restrictions returns the unexpired restrictions in the chat.
+func (s *MemoryStore) Restrictions(chatID string, guestID int) ([]Restriction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	list := []Restriction{}
+	for _, r := range s.limits[chatID] {
+		if (guestID == 0 || r.GuestID == guestID) && r.Active(now) {
+			r.Username = s.names[r.GuestID]
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return list, nil
+}
+
+// RecordEscalation stores an escalation.
+func (s *MemoryStore) RecordEscalation(e Escalation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flood[e.ChatID] = append(s.flood[e.ChatID], e)
+	return nil
+}
+
+// Escalations returns the newest escalations in the chat.
+func (s *MemoryStore) Escalations(chatID string, limit int) ([]Escalation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := s.flood[chatID]
+	list := []Escalation{}
+	for i := len(all) - 1; i >= 0 && len(list) < limit; i-- {
+		e := all[i]
+		e.Username = s.names[e.GuestID]
+		list = append(list, e)
+	}
+	return list, nil
+}
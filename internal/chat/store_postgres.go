@@ -0,0 +1,669 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"go-robot/internal/auth"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStore is a Store backed by the chats, chat_participants, chat_messages,
+// chat_message_revisions, chat_receipts, chat_attachments and chat_restrictions tables.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Join creates the chat if needed and records the participant. The
+// username is not stored: current names are read from guests.
+func (s *PostgresStore) Join(chatID string, user auth.Identity) error {
+	if _, err := s.db.Exec(`INSERT INTO chats (id) VALUES ($1) ON CONFLICT DO NOTHING`, chatID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO chat_participants (chat_id, guest_id) VALUES ($1, $2)
+		ON CONFLICT (chat_id, guest_id) DO UPDATE SET last_seen_at = NOW()`, chatID, user.GuestID)
+	return err
+}
+
+// Leave removes the participant.
+func (s *PostgresStore) Leave(chatID string, guestID int) error {
+	_, err := s.db.Exec(`DELETE FROM chat_participants WHERE chat_id = $1 AND guest_id = $2`, chatID, guestID)
+	return err
+}
+
+// Save stores the message and links its attachments in one transaction.
+func (s *PostgresStore) Save(msg *Message) error {
+	if msg.Kind == "" {
+		msg.Kind = KindUser
+	}
+	if _, err := s.db.Exec(`INSERT INTO chats (id) VALUES ($1) ON CONFLICT DO NOTHING`, msg.ChatID); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		INSERT INTO chat_messages (id, chat_id, sender, sender_id, kind, text, sent_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7)`,
+		msg.ID, msg.ChatID, msg.Sender, msg.SenderID, msg.Kind, msg.Text, msg.Timestamp); err != nil {
+		return err
+	}
+	if len(msg.Attachments) > 0 {
+		ids := make([]string, len(msg.Attachments))
+		for i, a := range msg.Attachments {
+			ids[i] = a.ID
+		}
+		linked, err := queryAttachments(tx, `
+			UPDATE chat_attachments SET message_id = $1
+			WHERE id = ANY($2) AND chat_id = $3 AND uploader_id = $4 AND message_id IS NULL
+			RETURNING `+attachmentColumns, msg.ID, pq.Array(ids), msg.ChatID, msg.SenderID)
+		if err != nil {
+			return err
+		}
+		if len(linked) != len(ids) {
+			return ErrAttachmentUnavailable
+		}
+		byID := make(map[string]Attachment, len(linked))
+		for _, a := range linked {
+			byID[a.ID] = a
+		}
+		for i, id := range ids {
+			msg.Attachments[i] = byID[id]
+		}
+	}
+	return tx.Commit()
+}
+
+// History returns a page of messages in chronological order.
+func (s *PostgresStore) History(chatID, beforeID string, limit int) ([]Message, error) {
+	messages, err := s.queryMessages(`
+		SELECT `+messageColumns+` FROM (
+			SELECT *
+			FROM chat_messages
+			WHERE chat_id = $1 AND ($2 = '' OR id < $2)
+			ORDER BY id DESC
+			LIMIT $3
+		) page
+		ORDER BY id`, chatID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDetails(messages)
+}
+
+// queryMessages runs a query selecting messageColumns.
+func (s *PostgresStore) queryMessages(query string, args ...interface{}) ([]Message, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// withDetails loads the receipts and attachments of messages and redacts
+// deleted ones.
+func (s *PostgresStore) withDetails(messages []Message) ([]Message, error) {
+	if len(messages) == 0 {
+		return messages, nil
+	}
+	index := make(map[string]int, len(messages))
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		index[m.ID] = i
+		ids[i] = m.ID
+	}
+
+	receipts, err := s.queryReceipts(`
+		SELECT r.message_id, r.guest_id, g.username, r.delivered_at, r.read_at
+		FROM chat_receipts r
+		JOIN guests g ON g.id = r.guest_id
+		WHERE r.message_id = ANY($1)
+		ORDER BY r.message_id, g.username`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range receipts {
+		i := index[r.MessageID]
+		messages[i].Receipts = append(messages[i].Receipts, r)
+	}
+
+	attachments, err := queryAttachments(s.db, `
+		SELECT `+attachmentColumns+`
+		FROM chat_attachments
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range attachments {
+		i := index[a.MessageID]
+		messages[i].Attachments = append(messages[i].Attachments, a)
+	}
+	for i := range messages {
+		messages[i] = messages[i].redacted()
+	}
+	return messages, nil
+}
+
+const messageColumns = `id, chat_id, sender, sender_id, kind, text, sent_at, edited_at, deleted_at, deleted_by`
+
+// scanMessage scans a row of messageColumns followed by extra columns.
+func scanMessage(row interface{ Scan(...interface{}) error }, extra ...interface{}) (Message, error) {
+	var (
+		m                             Message
+		senderID, editedAt, deletedAt sql.NullInt64
+		deletedBy                     sql.NullString
+	)
+	dest := []interface{}{&m.ID, &m.ChatID, &m.Sender, &senderID, &m.Kind, &m.Text, &m.Timestamp, &editedAt, &deletedAt, &deletedBy}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return Message{}, err
+	}
+	m.SenderID = int(senderID.Int64)
+	m.EditedAt, m.DeletedAt, m.DeletedBy = editedAt.Int64, deletedAt.Int64, deletedBy.String
+	return m, nil
+}
+
+// Message returns a message by ID.
+func (s *PostgresStore) Message(chatID, id string) (Message, error) {
+	m, err := scanMessage(s.db.QueryRow(`
+		SELECT `+messageColumns+` FROM chat_messages WHERE chat_id = $1 AND id = $2`, chatID, id))
+	if err == sql.ErrNoRows {
+		return Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	m.Attachments, err = queryAttachments(s.db, `
+		SELECT `+attachmentColumns+` FROM chat_attachments WHERE message_id = $1 ORDER BY id`, id)
+	return m, err
+}
+
+// Edit replaces the text of a message and records the previous one.
+func (s *PostgresStore) Edit(chatID, id, text string, editedAt int64) (Message, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Message{}, err
+	}
+	defer tx.Rollback()
+	m, err := scanMessage(tx.QueryRow(`
+		SELECT `+messageColumns+` FROM chat_messages WHERE chat_id = $1 AND id = $2 FOR UPDATE`, chatID, id))
+	if err == sql.ErrNoRows {
+		return Message{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return Message{}, err
+	}
+	if m.DeletedAt != 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	since := m.Timestamp
+	if m.EditedAt != 0 {
+		since = m.EditedAt
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO chat_message_revisions (message_id, text, created_at) VALUES ($1, $2, $3)`,
+		id, m.Text, since); err != nil {
+		return Message{}, err
+	}
+	if _, err := tx.Exec(`UPDATE chat_messages SET text = $1, edited_at = $2 WHERE id = $3`, text, editedAt, id); err != nil {
+		return Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Message{}, err
+	}
+	return s.Message(chatID, id)
+}
+
+// Delete marks a message deleted.
+func (s *PostgresStore) Delete(chatID, id, by string, deletedAt int64) (Message, error) {
+	res, err := s.db.Exec(`
+		UPDATE chat_messages SET deleted_at = $1, deleted_by = $2
+		WHERE chat_id = $3 AND id = $4 AND deleted_at IS NULL`, deletedAt, by, chatID, id)
+	if err != nil {
+		return Message{}, err
+	}
+	m, err := s.Message(chatID, id)
+	if err != nil {
+		return Message{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Message{}, ErrMessageDeleted
+	}
+	return m, nil
+}
+
+// Revisions returns the previous texts of a message.
+func (s *PostgresStore) Revisions(chatID, id string) ([]Revision, error) {
+	if _, err := s.Message(chatID, id); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`
+		SELECT text, created_at FROM chat_message_revisions WHERE message_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Revision{}
+	for rows.Next() {
+		var r Revision
+		if err := rows.Scan(&r.Text, &r.Timestamp); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// MarkDelivered records delivery receipts.
+func (s *PostgresStore) MarkDelivered(chatID string, guestID int, messageIDs []string) ([]Receipt, error) {
+	return s.queryReceipts(`
+		WITH marked AS (
+			INSERT INTO chat_receipts (message_id, guest_id, delivered_at)
+			SELECT id, $2::integer, NOW() FROM chat_messages
+			WHERE chat_id = $1 AND sender_id IS DISTINCT FROM $2::integer AND id = ANY($3)
+			ON CONFLICT (message_id, guest_id) DO UPDATE SET delivered_at = EXCLUDED.delivered_at
+			WHERE chat_receipts.delivered_at IS NULL
+			RETURNING message_id, guest_id, delivered_at, read_at
+		)
+		SELECT r.message_id, r.guest_id, g.username, r.delivered_at, r.read_at
+		FROM marked r JOIN guests g ON g.id = r.guest_id`, chatID, guestID, pq.Array(messageIDs))
+}
+
+// MarkRead records read receipts.
+func (s *PostgresStore) MarkRead(chatID string, guestID int, messageIDs []string) ([]Receipt, error) {
+	return s.queryReceipts(`
+		WITH marked AS (
+			INSERT INTO chat_receipts (message_id, guest_id, delivered_at, read_at)
+			SELECT id, $2::integer, NOW(), NOW() FROM chat_messages
+			WHERE chat_id = $1 AND sender_id IS DISTINCT FROM $2::integer AND id = ANY($3)
+			ON CONFLICT (message_id, guest_id) DO UPDATE
+			SET delivered_at = COALESCE(chat_receipts.delivered_at, EXCLUDED.delivered_at),
+			    read_at = EXCLUDED.read_at
+			WHERE chat_receipts.read_at IS NULL
+			RETURNING message_id, guest_id, delivered_at, read_at
+		)
+		SELECT r.message_id, r.guest_id, g.username, r.delivered_at, r.read_at
+		FROM marked r JOIN guests g ON g.id = r.guest_id`, chatID, guestID, pq.Array(messageIDs))
+}
+
+// Unread counts unread messages per chat.
+func (s *PostgresStore) Unread(guestID int) (map[string]int, error) {
+	rows, err := s.db.Query(`
+		SELECT m.chat_id, COUNT(*)
+		FROM chat_messages m
+		JOIN chat_participants p ON p.chat_id = m.chat_id AND p.guest_id = $1
+		WHERE m.sender_id IS DISTINCT FROM p.guest_id
+		  AND m.sent_at >= floor(extract(epoch FROM p.joined_at) * 1000)::bigint
+		  AND NOT EXISTS (
+		      SELECT 1 FROM chat_receipts r
+		      WHERE r.message_id = m.id AND r.guest_id = p.guest_id AND r.read_at IS NOT NULL)
+		GROUP BY m.chat_id`, guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var chatID string
+		var n int
+		if err := rows.Scan(&chatID, &n); err != nil {
+			return nil, err
+		}
+		counts[chatID] = n
+	}
+	return counts, rows.Err()
+}
+
+// UnreadSummary returns the unread messages per chat.
+func (s *PostgresStore) UnreadSummary(guestID int) ([]ChatUnread, error) {
+	rows, err := s.db.Query(`
+		SELECT `+messageColumns+`, unread FROM (
+			SELECT DISTINCT ON (m.chat_id) m.*, COUNT(*) OVER (PARTITION BY m.chat_id) AS unread
+			FROM chat_messages m
+			JOIN chat_participants p ON p.chat_id = m.chat_id AND p.guest_id = $1
+			WHERE m.sender_id IS DISTINCT FROM p.guest_id
+			  AND m.sent_at >= floor(extract(epoch FROM p.joined_at) * 1000)::bigint
+			  AND NOT EXISTS (
+			      SELECT 1 FROM chat_receipts r
+			      WHERE r.message_id = m.id AND r.guest_id = p.guest_id AND r.read_at IS NOT NULL)
+			ORDER BY m.chat_id, m.id DESC
+		) last
+		ORDER BY id DESC`, guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []ChatUnread{}
+	for rows.Next() {
+		var u ChatUnread
+		m, err := scanMessage(rows, &u.Count)
+		if err != nil {
+			return nil, err
+		}
+		u.ChatID, u.Last = m.ChatID, m.redacted()
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// Undelivered returns messages without a delivery receipt of the user.
+func (s *PostgresStore) Undelivered(chatID string, guestID int, limit int) ([]Message, error) {
+	messages, err := s.queryMessages(`
+		SELECT m.id, m.chat_id, m.sender, m.sender_id, m.kind, m.text, m.sent_at, m.edited_at, m.deleted_at, m.deleted_by -- messageColumns
+		FROM chat_messages m
+		JOIN chat_participants p ON p.chat_id = m.chat_id AND p.guest_id = $2
+		WHERE m.chat_id = $1 AND m.sender_id IS DISTINCT FROM p.guest_id
+		  AND m.sent_at >= floor(extract(epoch FROM p.joined_at) * 1000)::bigint
+		  AND NOT EXISTS (
+		      SELECT 1 FROM chat_receipts r
+		      WHERE r.message_id = m.id AND r.guest_id = p.guest_id AND r.delivered_at IS NOT NULL)
+		ORDER BY m.id
+		LIMIT $3`, chatID, guestID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDetails(messages)
+}
+
+// PendingNotifications returns unread messages waiting for a notification.
+func (s *PostgresStore) PendingNotifications(sentAfter, sentBefore int64) ([]Notification, error) {
+	rows, err := s.db.Query(`
+		SELECT m.id, m.chat_id, m.sender, m.sender_id, m.kind, m.text, m.sent_at, m.edited_at, m.deleted_at, m.deleted_by, -- messageColumns
+		       p.guest_id, g.username
+		FROM chat_messages m
+		JOIN chat_participants p ON p.chat_id = m.chat_id AND m.sender_id IS DISTINCT FROM p.guest_id
+		JOIN guests g ON g.id = p.guest_id
+		WHERE m.sent_at >= $1 AND m.sent_at < $2 AND m.kind <> $3 AND m.deleted_at IS NULL
+		  AND m.sent_at >= floor(extract(epoch FROM p.joined_at) * 1000)::bigint
+		  AND NOT EXISTS (
+		      SELECT 1 FROM chat_receipts r
+		      WHERE r.message_id = m.id AND r.guest_id = p.guest_id AND r.read_at IS NOT NULL)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM chat_notifications n
+		      WHERE n.message_id = m.id AND n.guest_id = p.guest_id)
+		ORDER BY p.guest_id, m.chat_id, m.id`, sentAfter, sentBefore, KindSystem)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Notification
+	for rows.Next() {
+		var (
+			guestID  int
+			username string
+		)
+		m, err := scanMessage(rows, &guestID, &username)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(list); n == 0 || list[n-1].GuestID != guestID || list[n-1].ChatID != m.ChatID {
+			list = append(list, Notification{GuestID: guestID, Username: username, ChatID: m.ChatID})
+		}
+		last := &list[len(list)-1]
+		last.Messages = append(last.Messages, m)
+		last.Unread++
+	}
+	return list, rows.Err()
+}
+
+// MarkNotified records notifications.
+func (s *PostgresStore) MarkNotified(guestID int, messageIDs []string) ([]string, error) {
+	rows, err := s.db.Query(`
+		INSERT INTO chat_notifications (message_id, guest_id)
+		SELECT unnest($2::text[]), $1
+		ON CONFLICT DO NOTHING
+		RETURNING message_id`, guestID, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var marked []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		marked = append(marked, id)
+	}
+	return marked, rows.Err()
+}
+
+// UnmarkNotified releases notification marks.
+func (s *PostgresStore) UnmarkNotified(guestID int, messageIDs []string) error {
+	_, err := s.db.Exec(`
+		DELETE FROM chat_notifications WHERE guest_id = $1 AND message_id = ANY($2)`, guestID, pq.Array(messageIDs))
+	return err
+}
+
+func (s *PostgresStore) queryReceipts(query string, args ...interface{}) ([]Receipt, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var receipts []Receipt
+	for rows.Next() {
+		var (
+			r                   Receipt
+			delivered, readTime sql.NullTime
+		)
+		if err := rows.Scan(&r.MessageID, &r.GuestID, &r.Username, &delivered, &readTime); err != nil {
+			return nil, err
+		}
+		if delivered.Valid {
+			r.DeliveredAt = delivered.Time.UnixMilli()
+		}
+		if readTime.Valid {
+			r.ReadAt = readTime.Time.UnixMilli()
+		}
+		receipts = append(receipts, r)
+	}
+	return receipts, rows.Err()
+}
+
+// SaveAttachment records the attachment.
+func (s *PostgresStore) SaveAttachment(a *Attachment) error {
+	if _, err := s.db.Exec(`INSERT INTO chats (id) VALUES ($1) ON CONFLICT DO NOTHING`, a.ChatID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO chat_attachments (id, chat_id, uploader, uploader_id, file_name, content_type, size,
+		                              width, height, storage_key, thumbnail_key, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, to_timestamp($12 / 1000.0))`,
+		a.ID, a.ChatID, a.Uploader, a.UploaderID, a.FileName, a.ContentType, a.Size,
+		a.Width, a.Height, a.StorageKey, a.ThumbnailKey, a.CreatedAt)
+	return err
+}
+
+// PendingAttachments counts unsent uploads of the user.
+func (s *PostgresStore) PendingAttachments(uploaderID int) (int, error) {
+	var n int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM chat_attachments WHERE uploader_id = $1 AND message_id IS NULL`, uploaderID).Scan(&n)
+	return n, err
+}
+
+// DeleteUnsentAttachments deletes expired unsent uploads. An upload being
+// linked to a message concurrently is either linked or deleted, not both.
+func (s *PostgresStore) DeleteUnsentAttachments(before int64) ([]Attachment, error) {
+	return queryAttachments(s.db, `
+		DELETE FROM chat_attachments
+		WHERE message_id IS NULL AND created_at < to_timestamp($1 / 1000.0)
+		RETURNING `+attachmentColumns, before)
+}
+
+// Attachment returns an attachment by ID unless its message was deleted.
+func (s *PostgresStore) Attachment(id string) (Attachment, error) {
+	list, err := queryAttachments(s.db, `
+		SELECT `+attachmentColumns+` FROM chat_attachments
+		WHERE id = $1 AND NOT EXISTS (
+			SELECT 1 FROM chat_messages m WHERE m.id = chat_attachments.message_id AND m.deleted_at IS NOT NULL)`, id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if len(list) == 0 {
+		return Attachment{}, ErrAttachmentNotFound
+	}
+	return list[0], nil
+}
+
+const attachmentColumns = `id, chat_id, uploader, COALESCE(uploader_id, 0), COALESCE(message_id, ''), file_name, content_type,
+	size, width, height, storage_key, thumbnail_key, (extract(epoch FROM created_at) * 1000)::bigint`
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryAttachments(q querier, query string, args ...interface{}) ([]Attachment, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []Attachment
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.ChatID, &a.Uploader, &a.UploaderID, &a.MessageID, &a.FileName, &a.ContentType,
+			&a.Size, &a.Width, &a.Height, &a.StorageKey, &a.ThumbnailKey, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a.withURLs())
+	}
+	return list, rows.Err()
+}
+
+// foreignKeyViolation is the PostgreSQL error code of a missing referenced row.
+const foreignKeyViolation = "23503"
+
+// Restrict adds a restriction, replacing one of the same kind.
+func (s *PostgresStore) Restrict(r *Restriction) error {
+	if _, err := s.db.Exec(`INSERT INTO chats (id) VALUES ($1) ON CONFLICT DO NOTHING`, r.ChatID); err != nil {
+		return err
+	}
+	var expiresAt sql.NullTime
+	if r.ExpiresAt != 0 {
+		expiresAt = sql.NullTime{Time: time.UnixMilli(r.ExpiresAt), Valid: true}
+	}
+	err := s.db.QueryRow(`
+		WITH saved AS (
+			INSERT INTO chat_restrictions (chat_id, guest_id, kind, reason, created_by, created_at, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (chat_id, guest_id, kind) DO UPDATE
+			SET reason = EXCLUDED.reason, created_by = EXCLUDED.created_by,
+			    created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+			RETURNING guest_id
+		)
+		SELECT g.username FROM saved JOIN guests g ON g.id = saved.guest_id`,
+		r.ChatID, r.GuestID, r.Kind, r.Reason, r.CreatedBy, time.UnixMilli(r.CreatedAt), expiresAt).Scan(&r.Username)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return ErrInvalidRestriction
+	}
+	return err
+}
+
+// Unrestrict lifts a restriction.
+func (s *PostgresStore) Unrestrict(chatID string, guestID int, kind string) error {
+	res, err := s.db.Exec(`
+		DELETE FROM chat_restrictions
+		WHERE chat_id = $1 AND guest_id = $2 AND kind = $3 AND (expires_at IS NULL OR expires_at > NOW())`,
+		chatID, guestID, kind)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRestrictionNotFound
+	}
+	return nil
+}
+
+// Restrictions returns the unexpired restrictions in the chat.
+func (s *PostgresStore) Restrictions(chatID string, guestID int) ([]Restriction, error) {
+	rows, err := s.db.Query(`
+		SELECT r.chat_id, r.guest_id, g.username, r.kind, r.reason, r.created_by, r.created_at, r.expires_at
+		FROM chat_restrictions r
+		JOIN guests g ON g.id = r.guest_id
+		WHERE r.chat_id = $1 AND ($2 = 0 OR r.guest_id = $2) AND (r.expires_at IS NULL OR r.expires_at > NOW())
+		ORDER BY r.created_at`, chatID, guestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Restriction{}
+	for rows.Next() {
+		var (
+			r         Restriction
+			createdAt time.Time
+			expiresAt sql.NullTime
+		)
+		if err := rows.Scan(&r.ChatID, &r.GuestID, &r.Username, &r.Kind, &r.Reason, &r.CreatedBy, &createdAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		r.CreatedAt = createdAt.UnixMilli()
+		if expiresAt.Valid {
+			r.ExpiresAt = expiresAt.Time.UnixMilli()
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// RecordEscalation stores an escalation.
+func (s *PostgresStore) RecordEscalation(e Escalation) error {
+	if _, err := s.db.Exec(`INSERT INTO chats (id) VALUES ($1) ON CONFLICT DO NOTHING`, e.ChatID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO chat_escalations (chat_id, guest_id, action, exceeded, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		e.ChatID, e.GuestID, e.Action, e.Exceeded, time.UnixMilli(e.CreatedAt))
+	return err
+}
+
+// Escalations returns the newest escalations in the chat.
+func (s *PostgresStore) Escalations(chatID string, limit int) ([]Escalation, error) {
+	rows, err := s.db.Query(`
+		SELECT e.chat_id, e.guest_id, g.username, e.action, e.exceeded, e.created_at
+		FROM chat_escalations e
+		JOIN guests g ON g.id = e.guest_id
+		WHERE e.chat_id = $1
+		ORDER BY e.id DESC
+		LIMIT $2`, chatID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Escalation{}
+	for rows.Next() {
+		var (
+			e         Escalation
+			createdAt time.Time
+		)
+		if err := rows.Scan(&e.ChatID, &e.GuestID, &e.Username, &e.Action, &e.Exceeded, &createdAt); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = createdAt.UnixMilli()
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+// A guest renames their account and another guest registers the freed
+// name: the chats and unread messages stay with the first guest.
+func TestMemoryStoreReusedUsername(t *testing.T) {
+	s := NewMemoryStore()
+	const chatID = "support:1"
+	first := auth.Identity{GuestID: 1, Username: "alice"}
+	if err := s.Join(chatID, first); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().UnixMilli()
+	own := Message{ID: NewMessageID(), ChatID: chatID, Sender: "alice", SenderID: 1, Kind: KindUser, Text: "hi", Timestamp: now}
+	reply := Message{ID: NewMessageID(), ChatID: chatID, Sender: "operator", SenderID: 7, Kind: KindUser, Text: "hello", Timestamp: now}
+	for _, m := range []*Message{&own, &reply} {
+		if err := s.Save(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renamed := auth.Identity{GuestID: 1, Username: "alicia"}
+	if err := s.Join(chatID, renamed); err != nil {
+		t.Fatal(err)
+	}
+	unread, err := s.Unread(renamed.GuestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unread[chatID] != 1 {
+		t.Errorf("Unread of the renamed guest = %v, want only the operator's reply", unread)
+	}
+	pending, err := s.PendingNotifications(0, now+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].GuestID != 1 || pending[0].Username != "alicia" || pending[0].Unread != 1 {
+		t.Errorf("PendingNotifications = %+v, want the reply for guest 1 (alicia)", pending)
+	}
+	receipts, err := s.MarkRead(chatID, renamed.GuestID, []string{own.ID, reply.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipts) != 1 || receipts[0].MessageID != reply.ID || receipts[0].GuestID != 1 || receipts[0].Username != "alicia" {
+		t.Errorf("MarkRead = %+v, want a receipt of guest 1 (alicia) for the reply only", receipts)
+	}
+
+	newcomer := auth.Identity{GuestID: 2, Username: "alice"}
+	if err := s.Join("support:2", newcomer); err != nil {
+		t.Fatal(err)
+	}
+	if unread, err := s.Unread(newcomer.GuestID); err != nil || len(unread) != 0 {
+		t.Errorf("Unread of the new owner of the name = %v, %v; want none", unread, err)
+	}
+	if summary, err := s.UnreadSummary(newcomer.GuestID); err != nil || len(summary) != 0 {
+		t.Errorf("UnreadSummary of the new owner of the name = %v, %v; want none", summary, err)
+	}
+	if queued, err := s.Undelivered(chatID, newcomer.GuestID, maxOfflineQueue); err != nil || len(queued) != 0 {
+		t.Errorf("Undelivered of the new owner of the name = %v, %v; want none", queued, err)
+	}
+}
+
+// Unsent uploads belong to the guest who uploaded them, not to their name.
+func TestMemoryStoreAttachmentOwner(t *testing.T) {
+	s := NewMemoryStore()
+	const chatID = "operators"
+	upload := Attachment{ID: NewMessageID(), ChatID: chatID, Uploader: "alice", UploaderID: 1, CreatedAt: time.Now().UnixMilli()}
+	if err := s.SaveAttachment(&upload); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.PendingAttachments(2); err != nil || n != 0 {
+		t.Errorf("PendingAttachments(2) = %d, %v; want 0", n, err)
+	}
+
+	// Guest 2 registered the name after guest 1 renamed their account.
+	msg := Message{ID: NewMessageID(), ChatID: chatID, Sender: "alice", SenderID: 2, Kind: KindUser,
+		Timestamp: time.Now().UnixMilli(), Attachments: []Attachment{{ID: upload.ID}}}
+	if err := s.Save(&msg); err != ErrAttachmentUnavailable {
+		t.Fatalf("Save with another guest's upload = %v, want ErrAttachmentUnavailable", err)
+	}
+	msg.Sender, msg.SenderID = "alicia", 1
+	if err := s.Save(&msg); err != nil {
+		t.Fatalf("Save by the uploader = %v", err)
+	}
+	if n, err := s.PendingAttachments(1); err != nil || n != 0 {
+		t.Errorf("PendingAttachments(1) after sending = %d, %v; want 0", n, err)
+	}
+}
+
+// A ban stays with the guest after a rename and does not pass to the
+// guest who registers the freed name.
+func TestBanFollowsGuest(t *testing.T) {
+	s := NewMemoryStore()
+	hub := NewChatHub(s, NewMemoryBroker())
+	const chatID = "support:1"
+	if err := s.Join(chatID, auth.Identity{GuestID: 1, Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hub.Restrict(Restriction{ChatID: chatID, GuestID: 1, Kind: RestrictBan, CreatedBy: "operator"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Join(chatID, auth.Identity{GuestID: 1, Username: "alicia"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.CheckBan(chatID, 1); !errors.Is(err, ErrBanned) {
+		t.Errorf("CheckBan of the renamed guest = %v, want ErrBanned", err)
+	}
+	if err := hub.CheckBan(chatID, 2); err != nil {
+		t.Errorf("CheckBan of the new owner of the name = %v, want nil", err)
+	}
+	list, err := hub.Restrictions(chatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].GuestID != 1 || list[0].Username != "alicia" {
+		t.Errorf("Restrictions = %+v, want the ban of guest 1 (alicia)", list)
+	}
+}
+
+// Attachments of a deleted message are no longer served.
+func TestMemoryStoreDeletedMessageAttachment(t *testing.T) {
+	s := NewMemoryStore()
+	const chatID = "support:1"
+	upload := Attachment{ID: NewMessageID(), ChatID: chatID, Uploader: "alice", UploaderID: 1, CreatedAt: time.Now().UnixMilli()}
+	if err := s.SaveAttachment(&upload); err != nil {
+		t.Fatal(err)
+	}
+	msg := Message{ID: NewMessageID(), ChatID: chatID, Sender: "alice", SenderID: 1, Kind: KindUser,
+		Timestamp: time.Now().UnixMilli(), Attachments: []Attachment{{ID: upload.ID}}}
+	if err := s.Save(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Attachment(upload.ID); err != nil {
+		t.Fatalf("Attachment of a sent message = %v", err)
+	}
+	if _, err := s.Delete(chatID, msg.ID, "operator", time.Now().UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Attachment(upload.ID); err != ErrAttachmentNotFound {
+		t.Errorf("Attachment of a deleted message = %v, want ErrAttachmentNotFound", err)
+	}
+}
@@ -0,0 +1,98 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/support"
+)
+
+// SystemSender is the sender name of messages generated by the server.
+// Guests may not take it, but messages are told apart by Kind.
+const SystemSender = "system"
+
+// UseSupport routes support chats through the ticket queue of svc: a guest
+// writing in their support chat gets a ticket, and ticket changes are
+// announced in the chat.
+func (hub *ChatHub) UseSupport(svc *support.Service) {
+	hub.support = svc
+	svc.OnChange(hub.ticketChanged)
+}
+
+// Post delivers a server-generated message, e.g. a system notice, after the
+// messages already queued in its chat. The ID and timestamp are assigned when
+// it is saved. Messages without a kind are sent as KindSystem. It does not block.
+func (hub *ChatHub) Post(msg Message) {
+	if msg.Kind == "" {
+		msg.Kind = KindSystem
+	}
+	hub.submit(inbound{msg: msg})
+}
+
+// openTicket opens (or finds) the ticket of a guest who wrote in their own
+// support chat; it is called after each saved guest message, so a guest
+// who only reconnects does not reopen a closed ticket.
+// With a bot, the ticket is opened on handoff instead (see botReply).
+func (hub *ChatHub) openTicket(identity auth.Identity, chatID string) {
+	if hub.support == nil || hub.bot != nil || chatID != SupportChatID(identity.GuestID) {
+		return
+	}
+	if _, err := hub.support.Open(identity.GuestID, chatID); err != nil {
+		log.Printf("Error opening support ticket for chat %s: %v", chatID, err)
+	}
+}
+
+// ticketChanged notifies the chat of a ticket about the change and keeps
+// the assigned operator its only staff participant.
+func (hub *ChatHub) ticketChanged(ch support.Change) {
+	chatID := ch.Ticket.ChatID
+	hub.updateOperator(ch)
+	data, err := encodeFrame(FrameTicket, "", ch)
+	if err != nil {
+		log.Printf("Error marshaling ticket change: %v", err)
+		return
+	}
+	hub.mu.Lock()
+	hub.sendToChat(chatID, data)
+	hub.mu.Unlock()
+	hub.publish(Event{Kind: EventFrame, ChatID: chatID, Frame: data})
+
+	// A message keeps the notice in history for guests who are offline right now.
+	var text string
+	switch ch.Action {
+	case support.ActionAssigned, support.ActionTransferred:
+		text = fmt.Sprintf("Оператор %s подключился к чату", ch.Ticket.OperatorName)
+	case support.ActionClosed:
+		text = "Обращение закрыто"
+	default:
+		return
+	}
+	hub.Post(Message{ChatID: chatID, Sender: SystemSender, Kind: KindSystem, Text: text})
+}
+
+// updateOperator makes the operator assigned to the ticket a participant of
+// its chat, and removes the operator who handed it over or closed it.
+func (hub *ChatHub) updateOperator(ch support.Change) {
+	t := ch.Ticket
+	var leaving *int
+	switch ch.Action {
+	case support.ActionTransferred:
+		if ch.PreviousOperatorID != nil && (t.OperatorID == nil || *ch.PreviousOperatorID != *t.OperatorID) {
+			leaving = ch.PreviousOperatorID
+		}
+	case support.ActionClosed:
+		leaving = t.OperatorID
+	}
+	if leaving != nil {
+		if err := hub.store.Leave(t.ChatID, *leaving); err != nil {
+			log.Printf("Error removing operator %d from chat %s: %v", *leaving, t.ChatID, err)
+		}
+	}
+	if t.Status == support.StatusAssigned && t.OperatorID != nil {
+		operator := auth.Identity{GuestID: *t.OperatorID, Username: t.OperatorName}
+		if err := hub.store.Join(t.ChatID, operator); err != nil {
+			log.Printf("Error recording operator %d in chat %s: %v", *t.OperatorID, t.ChatID, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"testing"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/support"
+)
+
+// Only the guest and the operator assigned to the ticket get unread counts
+// for a support chat, not every operator who opened it.
+func TestSupportChatParticipants(t *testing.T) {
+	s := NewMemoryStore()
+	hub := NewChatHub(s, NewMemoryBroker())
+	chatID := SupportChatID(1)
+	guest := auth.Identity{GuestID: 1, Username: "guest", Role: auth.RoleGuest}
+	first := auth.Identity{GuestID: 7, Username: "olga", Role: auth.RoleOperator}
+	second := auth.Identity{GuestID: 8, Username: "ivan", Role: auth.RoleOperator}
+	if !participates(guest, chatID) || participates(first, chatID) || !participates(first, "operators") {
+		t.Fatal("participates: want the guest only in their support chat, staff in other chats")
+	}
+	if err := s.Join(chatID, guest); err != nil {
+		t.Fatal(err)
+	}
+
+	ticket := support.Ticket{ChatID: chatID, GuestID: 1, Status: support.StatusAssigned, OperatorID: &first.GuestID, OperatorName: first.Username}
+	hub.updateOperator(support.Change{Action: support.ActionAssigned, Ticket: ticket})
+	ticket.OperatorID, ticket.OperatorName = &second.GuestID, second.Username
+	hub.updateOperator(support.Change{Action: support.ActionTransferred, Ticket: ticket, PreviousOperatorID: &first.GuestID})
+
+	msg := Message{ID: NewMessageID(), ChatID: chatID, Sender: "guest", SenderID: 1, Kind: KindUser, Text: "hi", Timestamp: time.Now().UnixMilli()}
+	if err := s.Save(&msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		operator auth.Identity
+		want     int
+	}{{first, 0}, {second, 1}} {
+		if unread, err := s.Unread(c.operator.GuestID); err != nil || unread[chatID] != c.want {
+			t.Errorf("Unread of %s = %v, %v; want %d", c.operator.Username, unread, err, c.want)
+		}
+	}
+
+	ticket.Status = support.StatusClosed
+	hub.updateOperator(support.Change{Action: support.ActionClosed, Ticket: ticket})
+	if unread, err := s.Unread(second.GuestID); err != nil || len(unread) != 0 {
+		t.Errorf("Unread of %s after closing = %v, %v; want none", second.Username, unread, err)
+	}
+}
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// Transcript formats.
+const (
+	TranscriptJSON = "json"
+	TranscriptText = "txt"
+	TranscriptHTML = "html"
+)
+
+// TranscriptContentTypes maps transcript formats to Content-Type headers.
+var TranscriptContentTypes = map[string]string{
+	TranscriptJSON: "application/json; charset=utf-8",
+	TranscriptText: "text/plain; charset=utf-8",
+	TranscriptHTML: "text/html; charset=utf-8",
+}
+
+// transcriptTime is the time format of text and HTML transcripts.
+const transcriptTime = "2006-01-02 15:04:05"
+
+// WriteTranscript writes the conversation in the given format. Deleted
+// messages are included and marked, since transcripts are for operators.
+// Times are shown in loc.
+func WriteTranscript(w io.Writer, format, chatID string, messages []Message, loc *time.Location) error {
+	exported := time.Now().In(loc)
+	switch format {
+	case TranscriptJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			ChatID     string    `json:"chat_id"`
+			ExportedAt int64     `json:"exported_at"`
+			Messages   []Message `json:"messages"`
+		}{chatID, exported.UnixMilli(), messages})
+
+	case TranscriptText:
+		var b strings.Builder
+		fmt.Fprintf(&b, "Чат %s\nВыгружен %s %s, сообщений: %d\n\n",
+			chatID, exported.Format(transcriptTime), loc, len(messages))
+		for _, m := range messages {
+			fmt.Fprintf(&b, "[%s] %s", time.UnixMilli(m.Timestamp).In(loc).Format(transcriptTime), m.Sender)
+			if note := transcriptNote(m, loc); note != "" {
+				fmt.Fprintf(&b, " (%s)", note)
+			}
+			b.WriteString(": " + strings.ReplaceAll(m.Text, "\n", "\n    ") + "\n")
+			for _, a := range m.Attachments {
+				fmt.Fprintf(&b, "    вложение: %s (%s, %d×%d) %s\n", a.FileName, a.ContentType, a.Width, a.Height, a.URL)
+			}
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+
+	case TranscriptHTML:
+		type row struct {
+			Message
+			Time   string
+			Note   string
+			System bool
+		}
+		rows := make([]row, len(messages))
+		for i, m := range messages {
+			rows[i] = row{m, time.UnixMilli(m.Timestamp).In(loc).Format(transcriptTime), transcriptNote(m, loc), m.Kind == KindSystem}
+		}
+		return transcriptHTML.Execute(w, struct {
+			ChatID   string
+			Exported string
+			Zone     string
+			Rows     []row
+		}{chatID, exported.Format(transcriptTime), loc.String(), rows})
+	}
+	return fmt.Errorf("unknown transcript format %q", format)
+}
+
+// transcriptNote describes edits and deletion of a message.
+func transcriptNote(m Message, loc *time.Location) string {
+	var notes []string
+	if m.EditedAt != 0 {
+		notes = append(notes, "изменено "+time.UnixMilli(m.EditedAt).In(loc).Format(transcriptTime))
+	}
+	if m.DeletedAt != 0 {
+		notes = append(notes, "удалено "+time.UnixMilli(m.DeletedAt).In(loc).Format(transcriptTime)+" пользователем "+m.DeletedBy)
+	}
+	return strings.Join(notes, ", ")
+}
+
+var transcriptHTML = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Чат {{.ChatID}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+.meta { color: #777; font-size: 0.9em; }
+.msg { margin: 0.8em 0; }
+.msg .text { white-space: pre-wrap; margin-top: 0.2em; }
+.deleted .text { color: #999; text-decoration: line-through; }
+.system { color: #555; font-style: italic; }
+img { max-width: 160px; margin: 0.3em 0.3em 0 0; }
+</style>
+</head>
+<body>
+<h1>Чат {{.ChatID}}</h1>
+<p class="meta">Выгружен {{.Exported}} {{.Zone}}, сообщений: {{len .Rows}}</p>
+{{range .Rows}}<div class="msg{{if .DeletedAt}} deleted{{end}}{{if .System}} system{{end}}">
+<span class="meta">{{.Time}}</span> <b>{{.Sender}}</b>{{if .Note}} <span class="meta">({{.Note}})</span>{{end}}
+<div class="text">{{.Text}}</div>
+{{range .Attachments}}<a href="{{.URL}}"><img src="{{.ThumbnailURL}}" alt="{{.FileName}}" title="{{.FileName}}"></a>{{end}}
+</div>
+{{end}}</body>
+</html>
+`))
@@ -56,15 +56,6 @@ func Connect() (*sql.DB, error) {
 		log.Printf("Количество уникальных Email: %d", uniqueEmailCount)
 	}
 
-	// Подсчёт уникальных Password
-	var uniquePasswordCount int
-	err = db.QueryRow("SELECT COUNT(DISTINCT password) FROM guests").Scan(&uniquePasswordCount)
-	if err != nil {
-		log.Printf("Ошибка при подсчёте уникальных Password: %v", err)
-	} else {
-		log.Printf("Количество уникальных Password: %d", uniquePasswordCount)
-	}
-
 	// Подсчёт уникальных Phone
 	var uniquePhoneCount int
 	err = db.QueryRow("SELECT COUNT(DISTINCT phone) FROM guests").Scan(&uniquePhoneCount)
@@ -74,32 +65,5 @@ func Connect() (*sql.DB, error) {
 		log.Printf("Количество уникальных Phone: %d", uniquePhoneCount)
 	}
 
-	// Подсчёт уникальных FirstName
-	var uniqueFirstNameCount int
-	err = db.QueryRow("SELECT COUNT(DISTINCT first_name) FROM guests").Scan(&uniqueFirstNameCount)
-	if err != nil {
-		log.Printf("Ошибка при подсчёте уникальных FirstName: %v", err)
-	} else {
-		log.Printf("Количество уникальных FirstName: %d", uniqueFirstNameCount)
-	}
-
-	// Подсчёт уникальных Address
-	var uniqueAddressCount int
-	err = db.QueryRow("SELECT COUNT(DISTINCT address) FROM guests").Scan(&uniqueAddressCount)
-	if err != nil {
-		log.Printf("Ошибка при подсчёте уникальных Address: %v", err)
-	} else {
-		log.Printf("Количество уникальных Address: %d", uniqueAddressCount)
-	}
-
-	// Подсчёт уникальных BirthDate
-	var uniqueBirthDateCount int
-	err = db.QueryRow("SELECT COUNT(DISTINCT birth_date) FROM guests").Scan(&uniqueBirthDateCount)
-	if err != nil {
-		log.Printf("Ошибка при подсчёте уникальных BirthDate: %v", err)
-	} else {
-		log.Printf("Количество уникальных BirthDate: %d", uniqueBirthDateCount)
-	}
-
 	return db, nil
 }
\ No newline at end of file
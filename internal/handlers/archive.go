@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-robot/internal/chat"
+)
+
+// maxSearchPage – максимальное число результатов поиска за запрос.
+const maxSearchPage = 200
+
+// ChatSearchHandler – полнотекстовый поиск по сообщениям всех чатов для операторов.
+// Требует RequirePermission(auth.PermChatAdmin).
+// URL: GET /chats/search?q=доставка -курьер&participant=bob&chat_id=support:42&from=2026-01-01&to=2026-01-31&limit=50&offset=0
+// q понимает синтаксис веб-поиска: "фраза", -исключение, or. from и to – даты
+// (YYYY-MM-DD, to включительно) или время в RFC 3339. Без q результаты идут от новых к старым.
+func ChatSearchHandler(archive chat.Archive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		q := chat.SearchQuery{
+			Text:        query.Get("q"),
+			Participant: query.Get("participant"),
+			ChatID:      query.Get("chat_id"),
+			Limit:       chat.HistoryLimit,
+		}
+		var err error
+		if q.From, err = parseSearchTime(query.Get("from"), false); err != nil {
+			http.Error(w, "Некорректный параметр from", http.StatusBadRequest)
+			return
+		}
+		if q.To, err = parseSearchTime(query.Get("to"), true); err != nil {
+			http.Error(w, "Некорректный параметр to", http.StatusBadRequest)
+			return
+		}
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Некорректный параметр limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = min(n, maxSearchPage)
+		}
+		if v := query.Get("offset"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "Некорректный параметр offset", http.StatusBadRequest)
+				return
+			}
+			q.Offset = n
+		}
+
+		results, err := archive.Search(q)
+		if err != nil {
+			log.Printf("Ошибка поиска по чатам (%q): %v", q.Text, err)
+			http.Error(w, "Ошибка поиска", http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Results []chat.SearchResult `json:"results"`
+			HasMore bool                `json:"has_more"`
+		}{Results: results, HasMore: len(results) == q.Limit}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// parseSearchTime разбирает дату YYYY-MM-DD или время RFC 3339; пустая строка – нулевое время.
+// Для конца диапазона дата означает конец этого дня.
+func parseSearchTime(s string, end bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// TranscriptHandler – выгрузка переписки чата целиком для операторов, включая удалённые
+// сообщения. Требует RequirePermission(auth.PermChatAdmin).
+// URL: GET /transcripts/{chat_id}?format=json|txt|html&tz=Europe/Moscow
+// По умолчанию format=json, tz=UTC; файл отдаётся для скачивания.
+func TranscriptHandler(archive chat.Archive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		chatID := strings.Trim(r.URL.Path[len("/transcripts/"):], "/")
+		if chatID == "" {
+			http.NotFound(w, r)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = chat.TranscriptJSON
+		}
+		contentType, ok := chat.TranscriptContentTypes[format]
+		if !ok {
+			http.Error(w, "Некорректный параметр format: ожидается json, txt или html", http.StatusBadRequest)
+			return
+		}
+		loc := time.UTC
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			var err error
+			if loc, err = time.LoadLocation(tz); err != nil {
+				http.Error(w, "Некорректный часовой пояс tz", http.StatusBadRequest)
+				return
+			}
+		}
+
+		messages, err := archive.Transcript(chatID)
+		if err != nil {
+			log.Printf("Ошибка выгрузки чата %s: %v", chatID, err)
+			http.Error(w, "Ошибка выгрузки чата", http.StatusInternalServerError)
+			return
+		}
+		if len(messages) == 0 {
+			http.Error(w, "Чат не найден или пуст", http.StatusNotFound)
+			return
+		}
+		// Собираем в буфер, чтобы ошибка не оборвала уже начатый ответ
+		var buf bytes.Buffer
+		if err := chat.WriteTranscript(&buf, format, chatID, messages, loc); err != nil {
+			log.Printf("Ошибка формирования выгрузки чата %s: %v", chatID, err)
+			http.Error(w, "Ошибка выгрузки чата", http.StatusInternalServerError)
+			return
+		}
+		fileName := "chat-" + strings.NewReplacer(":", "-", "/", "-", `"`, "").Replace(chatID) + "." + format
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/chat"
+	"go-robot/internal/media"
+	"go-robot/internal/storage"
+)
+
+// ChatUploadHandler – загрузка изображения для последующей отправки в чат.
+// Требует RequireAuth, доступ как при подключении к чату (chat.CanJoin).
+// URL: POST /chats/{id}/attachments, multipart/form-data с полем "file".
+// Ответ – chat.Attachment; его id передаётся в attachment_ids сообщения.
+func ChatUploadHandler(files *chat.Attachments) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		chatID, _, _ := strings.Cut(r.URL.Path[len("/chats/"):], "/")
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if chatID == "" || !chat.CanJoin(identity, chatID) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+
+		// Запас на заголовки multipart сверх размера самого файла
+		r.Body = http.MaxBytesReader(w, r.Body, files.MaxSize()+64<<10)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "Файл больше "+strconv.FormatInt(files.MaxSize()>>20, 10)+" МБ", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Ожидается файл в поле file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		a, err := files.Upload(chatID, identity, header.Filename, file)
+		switch {
+		case errors.Is(err, chat.ErrAttachmentTooLarge):
+			http.Error(w, "Файл больше "+strconv.FormatInt(files.MaxSize()>>20, 10)+" МБ", http.StatusRequestEntityTooLarge)
+			return
+		case errors.Is(err, chat.ErrTooManyPending):
+			http.Error(w, "Слишком много неотправленных вложений: сначала отправьте загруженные", http.StatusTooManyRequests)
+			return
+		case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrTooManyPixels):
+			http.Error(w, "Допустимы изображения JPEG, PNG и GIF: "+err.Error(), http.StatusUnsupportedMediaType)
+			return
+		case err != nil:
+			log.Printf("Ошибка загрузки вложения в чат %s: %v", chatID, err)
+			http.Error(w, "Ошибка сохранения файла", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(a)
+	}
+}
+
+// AttachmentsHandler – скачивание вложения или его миниатюры. Требует RequireAuth;
+// доступно тем, кто может подключиться к чату вложения и не заблокирован в нём.
+// Вложения удалённых сообщений не отдаются.
+// URL: GET /attachments/{id} и /attachments/{id}/thumbnail
+func AttachmentsHandler(hub *chat.ChatHub, files *chat.Attachments) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		id, sub, _ := strings.Cut(strings.Trim(r.URL.Path[len("/attachments/"):], "/"), "/")
+		if !chat.ValidMessageID(id) || sub != "" && sub != "thumbnail" {
+			http.NotFound(w, r)
+			return
+		}
+		a, err := files.Get(id)
+		if errors.Is(err, chat.ErrAttachmentNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка получения вложения %s: %v", id, err)
+			http.Error(w, "Ошибка получения вложения", http.StatusInternalServerError)
+			return
+		}
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !chat.CanJoin(identity, a.ChatID) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+		if err := hub.CheckBan(a.ChatID, identity.GuestID); errors.Is(err, chat.ErrBanned) {
+			http.Error(w, "Вы заблокированы в этом чате", http.StatusForbidden)
+			return
+		} else if err != nil {
+			http.Error(w, "Ошибка проверки ограничений", http.StatusInternalServerError)
+			return
+		}
+
+		thumbnail := sub == "thumbnail"
+		body, err := files.Open(a, thumbnail)
+		if errors.Is(err, storage.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка чтения вложения %s: %v", id, err)
+			http.Error(w, "Ошибка получения вложения", http.StatusInternalServerError)
+			return
+		}
+		defer body.Close()
+
+		contentType := a.ContentType
+		if thumbnail {
+			contentType = "image/jpeg"
+		} else {
+			w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// Содержимое вложения по id никогда не меняется
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		if _, err := io.Copy(w, body); err != nil {
+			log.Printf("Ошибка отправки вложения %s: %v", id, err)
+		}
+	}
+}
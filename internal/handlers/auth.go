@@ -3,13 +3,33 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
+	"go-robot/internal/auth"
+	"go-robot/internal/bot"
+	"go-robot/internal/chat"
 	"go-robot/internal/models"
 )
 
+// reservedUsernames – имена, под которыми в чате пишут сервер и бот; гостям они недоступны.
+var reservedUsernames = []string{chat.SystemSender, bot.Name}
+
+// reservedUsername сообщает, совпадает ли name с зарезервированным именем без учёта регистра и пробелов.
+func reservedUsername(name string) bool {
+	name = strings.TrimSpace(name)
+	for _, reserved := range reservedUsernames {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
 // RegisterHandler – эндпоинт для регистрации гостей
-func RegisterHandler(db *sql.DB) http.HandlerFunc {
+func RegisterHandler(db *sql.DB, passwords *auth.Passwords) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
@@ -24,18 +44,29 @@ func RegisterHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Заполните все обязательные поля", http.StatusBadRequest)
 			return
 		}
-		query := `INSERT INTO guests (username, email, password, phone) VALUES ($1, $2, $3, $4) RETURNING id`
-		if err := db.QueryRow(query, guest.Username, guest.Email, guest.Password, guest.Phone).Scan(&guest.ID); err != nil {
+		if reservedUsername(guest.Username) {
+			http.Error(w, "Имя пользователя зарезервировано", http.StatusBadRequest)
+			return
+		}
+		hash, err := passwords.Hash(guest.Password)
+		if err != nil {
+			http.Error(w, "Ошибка обработки пароля", http.StatusInternalServerError)
+			return
+		}
+		query := `INSERT INTO guests (username, email, password, phone) VALUES ($1, $2, $3, $4) RETURNING id, role`
+		if err := db.QueryRow(query, guest.Username, guest.Email, hash, guest.Phone).Scan(&guest.ID, &guest.Role); err != nil {
 			http.Error(w, "Ошибка сохранения в базе данных", http.StatusInternalServerError)
 			return
 		}
+		guest.Password = ""
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		json.NewEncoder(w).Encode(guest)
 	}
 }
 
-// LoginHandler – эндпоинт для входа
-func LoginHandler(db *sql.DB) http.HandlerFunc {
+// LoginHandler – эндпоинт для входа. Возвращает access- и refresh-токены.
+// Если хеш пароля устарел (другой алгоритм или параметры), он прозрачно пересчитывается.
+func LoginHandler(db *sql.DB, passwords *auth.Passwords, sessions *auth.Sessions) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
@@ -54,13 +85,100 @@ func LoginHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 		var guest models.Guest
-		query := `SELECT id, username, email, password, phone FROM guests WHERE username = $1 AND password = $2`
-		if err := db.QueryRow(query, creds.Username, creds.Password).
-			Scan(&guest.ID, &guest.Username, &guest.Email, &guest.Password, &guest.Phone); err != nil {
+		query := `SELECT id, username, email, password, phone, role FROM guests WHERE username = $1`
+		err := db.QueryRow(query, creds.Username).
+			Scan(&guest.ID, &guest.Username, &guest.Email, &guest.Password, &guest.Phone, &guest.Role)
+		if err == sql.ErrNoRows {
+			passwords.DummyVerify(creds.Password)
 			http.Error(w, "Неверные учетные данные", http.StatusUnauthorized)
 			return
 		}
+		if err != nil {
+			http.Error(w, "Ошибка получения данных", http.StatusInternalServerError)
+			return
+		}
+		ok, rehash, err := passwords.Verify(guest.Password, creds.Password)
+		if err != nil {
+			log.Printf("Ошибка проверки пароля гостя %d: %v", guest.ID, err)
+		}
+		if !ok {
+			http.Error(w, "Неверные учетные данные", http.StatusUnauthorized)
+			return
+		}
+		if rehash {
+			if hash, err := passwords.Hash(creds.Password); err != nil {
+				log.Printf("Ошибка пересчёта хеша пароля гостя %d: %v", guest.ID, err)
+			} else if _, err := db.Exec(`UPDATE guests SET password = $1 WHERE id = $2`, hash, guest.ID); err != nil {
+				log.Printf("Ошибка сохранения нового хеша пароля гостя %d: %v", guest.ID, err)
+			}
+		}
+		guest.Password = ""
+		pair, err := sessions.Start(auth.Identity{GuestID: guest.ID, Username: guest.Username, Role: auth.Role(guest.Role)})
+		if err != nil {
+			log.Printf("Ошибка создания сессии гостя %d: %v", guest.ID, err)
+			http.Error(w, "Ошибка создания сессии", http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		json.NewEncoder(w).Encode(guest)
+		json.NewEncoder(w).Encode(struct {
+			auth.TokenPair
+			Guest models.Guest `json:"guest"`
+		}{pair, guest})
+	}
+}
+
+// refreshRequest – тело запросов /token/refresh и /logout.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshHandler – эндпоинт обмена refresh-токена на новую пару токенов.
+// Каждый refresh-токен одноразовый: повторное использование отзывает всю сессию.
+func RefreshHandler(sessions *auth.Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		pair, err := sessions.Refresh(req.RefreshToken)
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrRefreshReused) {
+			http.Error(w, "Недействительный refresh-токен", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка обновления токенов: %v", err)
+			http.Error(w, "Ошибка обновления токенов", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(pair)
+	}
+}
+
+// LogoutHandler – эндпоинт выхода: отзывает сессию, к которой принадлежит refresh-токен.
+func LogoutHandler(sessions *auth.Sessions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		var req refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+			return
+		}
+		err := sessions.Revoke(req.RefreshToken)
+		if err != nil && !errors.Is(err, auth.ErrInvalidToken) {
+			log.Printf("Ошибка завершения сессии: %v", err)
+			http.Error(w, "Ошибка завершения сессии", http.StatusInternalServerError)
+			return
+		}
+		// Неизвестный или уже отозванный токен – тоже успешный выход.
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/catalog"
+	"go-robot/internal/models"
+)
+
+// CategoriesHandler – список категорий меню (GET, публичный) и создание категории (POST).
+// Для POST требуется RequirePermissionForWrites(auth.PermManageProducts).
+// URL: GET /categories?locale=en – видимые категории по порядку; name – название
+// на языке locale (по умолчанию models.DefaultLocale).
+func CategoriesHandler(svc *catalog.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeCategories(w, r, svc, false)
+		case http.MethodPost:
+			c := models.Category{Visible: true} // без visible категория сразу видна
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+				return
+			}
+			created, err := svc.Create(c)
+			if err != nil {
+				writeCategoryError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(localize(created, r))
+		default:
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// CategoryAdminHandler – управление категориями. Требует RequirePermission(auth.PermManageProducts).
+//
+//	GET    /categories/      – все категории, включая скрытые
+//	GET    /categories/{id}  – категория
+//	PUT    /categories/{id}  – замена всех полей
+//	DELETE /categories/{id}  – удаление; категорию с продуктами удалить нельзя
+func CategoryAdminHandler(svc *catalog.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := strings.Trim(r.URL.Path[len("/categories/"):], "/")
+		if idStr == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			writeCategories(w, r, svc, true)
+			return
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		var c models.Category
+		switch r.Method {
+		case http.MethodGet:
+			c, err = svc.Get(id)
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+				return
+			}
+			c.ID = id
+			c, err = svc.Update(c)
+		case http.MethodDelete:
+			if err := svc.Delete(id); err != nil {
+				writeCategoryError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		default:
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			writeCategoryError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(localize(c, r))
+	}
+}
+
+func writeCategories(w http.ResponseWriter, r *http.Request, svc *catalog.Service, all bool) {
+	list, err := svc.List(all)
+	if err != nil {
+		log.Printf("Ошибка получения категорий: %v", err)
+		http.Error(w, "Ошибка получения категорий", http.StatusInternalServerError)
+		return
+	}
+	for i := range list {
+		list[i] = localize(list[i], r)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(list)
+}
+
+// localize заполняет name на языке из параметра locale.
+func localize(c models.Category, r *http.Request) models.Category {
+	c.Name = c.LocalName(r.URL.Query().Get("locale"))
+	return c
+}
+
+// writeCategoryError отвечает кодом, соответствующим ошибке catalog.
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, catalog.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, catalog.ErrSlugTaken), errors.Is(err, catalog.ErrInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("Ошибка сохранения категории: %v", err)
+		http.Error(w, "Ошибка сохранения категории", http.StatusInternalServerError)
+	}
+}
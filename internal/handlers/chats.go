@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/chat"
+)
+
+// maxMessagesPage – максимальный размер страницы истории чата.
+const maxMessagesPage = 200
+
+// ChatsHandler – маршруты /chats/{id}/...: история сообщений (ChatMessagesHandler),
+// удаление сообщений и история правок (ChatMessageHandler), загрузка вложений
+// (ChatUploadHandler), ограничения пользователей (ChatRestrictionsHandler)
+// и журнал реакций на флуд (ChatEscalationsHandler).
+// Забаненным в чате пользователям сообщения и вложения недоступны.
+// Требует RequireAuth.
+func ChatsHandler(hub *chat.ChatHub, store chat.Store, files *chat.Attachments) http.HandlerFunc {
+	messages := ChatMessagesHandler(store)
+	message := ChatMessageHandler(hub, store)
+	upload := ChatUploadHandler(files)
+	restrictions := ChatRestrictionsHandler(hub)
+	escalations := ChatEscalationsHandler(store)
+	return func(w http.ResponseWriter, r *http.Request) {
+		chatID, sub, _ := strings.Cut(strings.Trim(r.URL.Path[len("/chats/"):], "/"), "/")
+		section, rest, _ := strings.Cut(sub, "/")
+		if section == "messages" || section == "attachments" {
+			identity, _ := auth.IdentityFromContext(r.Context())
+			if err := hub.CheckBan(chatID, identity.GuestID); errors.Is(err, chat.ErrBanned) {
+				http.Error(w, "Вы заблокированы в этом чате", http.StatusForbidden)
+				return
+			} else if err != nil {
+				http.Error(w, "Ошибка проверки ограничений", http.StatusInternalServerError)
+				return
+			}
+		}
+		switch {
+		case section == "messages" && rest == "":
+			messages(w, r)
+		case section == "messages":
+			message(w, r)
+		case section == "attachments" && rest == "":
+			upload(w, r)
+		case section == "restrictions":
+			restrictions(w, r)
+		case section == "escalations" && rest == "":
+			escalations(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// ChatMessagesHandler – постраничная история чата. Требует RequireAuth.
+// Доступ проверяется так же, как при подключении к чату (chat.CanJoin).
+// URL: /chats/{id}/messages?before={message_id}&limit={n}
+// Сообщения возвращаются в хронологическом порядке; для следующей (более старой)
+// страницы передайте before = id первого сообщения текущей.
+func ChatMessagesHandler(store chat.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		// Извлекаем id чата из URL
+		chatID, sub, _ := strings.Cut(strings.Trim(r.URL.Path[len("/chats/"):], "/"), "/")
+		if chatID == "" || sub != "messages" {
+			http.NotFound(w, r)
+			return
+		}
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !chat.CanJoin(identity, chatID) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+
+		before := r.URL.Query().Get("before")
+		if before != "" && !chat.ValidMessageID(before) {
+			http.Error(w, "Некорректный параметр before", http.StatusBadRequest)
+			return
+		}
+		limit := chat.HistoryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Некорректный параметр limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxMessagesPage)
+		}
+
+		messages, err := store.History(chatID, before, limit)
+		if err != nil {
+			log.Printf("Ошибка получения истории чата %s: %v", chatID, err)
+			http.Error(w, "Ошибка получения сообщений", http.StatusInternalServerError)
+			return
+		}
+		resp := struct {
+			Messages []chat.Message `json:"messages"`
+			HasMore  bool           `json:"has_more"`
+		}{Messages: messages, HasMore: len(messages) == limit}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// ChatUnreadHandler – количество непрочитанных сообщений текущего пользователя
+// по чатам, в которых он участвует. Требует RequireAuth.
+// URL: /chats/unread; ответ: {"unread": {"support:1": 3}}
+func ChatUnreadHandler(store chat.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		identity, _ := auth.IdentityFromContext(r.Context())
+		unread, err := store.Unread(identity.GuestID)
+		if err != nil {
+			log.Printf("Ошибка подсчёта непрочитанных сообщений %s: %v", identity.Username, err)
+			http.Error(w, "Ошибка получения непрочитанных сообщений", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]map[string]int{"unread": unread})
+	}
+}
@@ -4,21 +4,44 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"go-robot/internal/auth"
 	"go-robot/internal/models"
 )
 
 // GuestHandler – эндпоинт для получения и обновления профиля гостя.
-// URL: /guest/{id}
-func GuestHandler(db *sql.DB) http.HandlerFunc {
+// URL: /guest/{id} или /guest/me. Гость имеет доступ только к своему профилю,
+// пользователь с правом guests:manage – к любому.
+// URL /guest/{id}/role (PUT) меняет роль и доступен только с правом guests:manage.
+// Требует RequireAuth.
+func GuestHandler(db *sql.DB, passwords *auth.Passwords) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
 		// Извлекаем id из URL
 		idStr := r.URL.Path[len("/guest/"):]
+		idStr, sub, _ := strings.Cut(idStr, "/")
+		if idStr == "me" {
+			idStr = strconv.Itoa(identity.GuestID)
+		}
+		if idStr != strconv.Itoa(identity.GuestID) && !identity.Can(auth.PermManageGuests) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+		if sub == "role" {
+			guestRoleHandler(db, identity, idStr, w, r)
+			return
+		}
+		if sub != "" {
+			http.NotFound(w, r)
+			return
+		}
 		switch r.Method {
 		case http.MethodGet:
 			var guest models.Guest
-			query := `SELECT id, username, email, password, phone FROM guests WHERE id = $1`
-			if err := db.QueryRow(query, idStr).Scan(&guest.ID, &guest.Username, &guest.Email, &guest.Password, &guest.Phone); err != nil {
+			query := `SELECT id, username, email, phone, role FROM guests WHERE id = $1`
+			if err := db.QueryRow(query, idStr).Scan(&guest.ID, &guest.Username, &guest.Email, &guest.Phone, &guest.Role); err != nil {
 				http.Error(w, "Пользователь не найден", http.StatusNotFound)
 				return
 			}
@@ -30,8 +53,21 @@ func GuestHandler(db *sql.DB) http.HandlerFunc {
 				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 				return
 			}
-			query := `UPDATE guests SET username = $1, email = $2, password = $3, phone = $4 WHERE id = $5`
-			if _, err := db.Exec(query, guest.Username, guest.Email, guest.Password, guest.Phone, idStr); err != nil {
+			if reservedUsername(guest.Username) {
+				http.Error(w, "Имя пользователя зарезервировано", http.StatusBadRequest)
+				return
+			}
+			// Пустой пароль означает, что пароль не меняется.
+			var hash string
+			if guest.Password != "" {
+				var err error
+				if hash, err = passwords.Hash(guest.Password); err != nil {
+					http.Error(w, "Ошибка обработки пароля", http.StatusInternalServerError)
+					return
+				}
+			}
+			query := `UPDATE guests SET username = $1, email = $2, password = COALESCE(NULLIF($3, ''), password), phone = $4 WHERE id = $5`
+			if _, err := db.Exec(query, guest.Username, guest.Email, hash, guest.Phone, idStr); err != nil {
 				http.Error(w, "Ошибка обновления профиля", http.StatusInternalServerError)
 				return
 			}
@@ -42,3 +78,32 @@ func GuestHandler(db *sql.DB) http.HandlerFunc {
 	}
 }
 
+// guestRoleHandler меняет роль пользователя: PUT /guest/{id}/role с телом {"role": "operator"}.
+func guestRoleHandler(db *sql.DB, identity auth.Identity, idStr string, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+		return
+	}
+	if !identity.Can(auth.PermManageGuests) {
+		http.Error(w, "Доступ запрещён", http.StatusForbidden)
+		return
+	}
+	var req struct {
+		Role auth.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Role.Valid() {
+		http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`UPDATE guests SET role = $1 WHERE id = $2`, req.Role, idStr)
+	if err != nil {
+		http.Error(w, "Ошибка обновления роли", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
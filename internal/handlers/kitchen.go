@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/kitchen"
+)
+
+// KitchenHandler – API кухонного экрана. Требует RequirePermission(auth.PermKitchen).
+//
+//	GET  /kitchen/orders             – активные заказы с позициями по категориям
+//	POST /kitchen/items/{id}/start   – позиция взята в работу
+//	POST /kitchen/items/{id}/done    – позиция готова
+func KitchenHandler(svc *kitchen.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		path := strings.Trim(r.URL.Path[len("/kitchen/"):], "/")
+
+		if path == "orders" {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			queue, err := svc.Queue()
+			if err != nil {
+				log.Printf("Ошибка получения очереди кухни: %v", err)
+				http.Error(w, "Ошибка получения очереди", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"server_time": time.Now(),
+				"orders":      queue,
+			})
+			return
+		}
+
+		// items/{id}/{action}
+		parts := strings.Split(path, "/")
+		if len(parts) != 3 || parts[0] != "items" {
+			http.NotFound(w, r)
+			return
+		}
+		itemID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		switch parts[2] {
+		case "start":
+			err = svc.Start(itemID, identity.GuestID)
+		case "done":
+			err = svc.Done(itemID, identity.GuestID)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if errors.Is(err, kitchen.ErrItemNotFound) {
+			http.Error(w, "Позиция не найдена", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Ошибка отметки позиции %d: %v", itemID, err)
+			http.Error(w, "Ошибка отметки позиции", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"go-robot/internal/auth"
+)
+
+// RequireAuth – middleware, пропускающий только запросы с действительным access-токеном.
+// Аутентифицированный гость кладётся в контекст запроса (см. auth.IdentityFromContext).
+// Браузер не может передать заголовок при открытии WebSocket, поэтому для
+// запросов Upgrade: websocket токен принимается и из параметра access_token.
+func RequireAuth(tokens *auth.Tokens, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := auth.BearerToken(r.Header.Get("Authorization"))
+		if !ok && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			token = r.URL.Query().Get("access_token")
+			ok = token != ""
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			http.Error(w, "Требуется авторизация", http.StatusUnauthorized)
+			return
+		}
+		identity, err := tokens.Parse(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+	}
+}
+
+// RequirePermission – middleware, пропускающий только пользователей, чья роль имеет право perm.
+func RequirePermission(tokens *auth.Tokens, perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(tokens, func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !identity.Can(perm) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+// RequirePermissionForWrites – как RequirePermission, но чтение (GET, HEAD) остаётся публичным.
+func RequirePermissionForWrites(tokens *auth.Tokens, perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	guarded := RequirePermission(tokens, perm, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		guarded(w, r)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/chat"
+)
+
+// ChatMessageHandler – удаление сообщения и история его правок. Требует RequireAuth.
+//
+//	DELETE /chats/{id}/messages/{message_id}           – удалить своё сообщение (оператор – любое)
+//	GET    /chats/{id}/messages/{message_id}/revisions – прежние тексты сообщения (только операторы)
+func ChatMessageHandler(hub *chat.ChatHub, store chat.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// {id}/messages/{message_id}[/revisions]
+		parts := strings.Split(strings.Trim(r.URL.Path[len("/chats/"):], "/"), "/")
+		if len(parts) < 3 || len(parts) > 4 || len(parts) == 4 && parts[3] != "revisions" {
+			http.NotFound(w, r)
+			return
+		}
+		chatID, messageID := parts[0], parts[2]
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !chat.CanJoin(identity, chatID) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+
+		if len(parts) == 4 {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			if !identity.Can(auth.PermChatAdmin) {
+				http.Error(w, "Доступ запрещён", http.StatusForbidden)
+				return
+			}
+			msg, err := store.Message(chatID, messageID)
+			if err != nil {
+				writeModerationError(w, err)
+				return
+			}
+			revisions, err := store.Revisions(chatID, messageID)
+			if err != nil {
+				writeModerationError(w, err)
+				return
+			}
+			resp := struct {
+				Message   chat.Message    `json:"message"`
+				Revisions []chat.Revision `json:"revisions"`
+			}{Message: msg, Revisions: revisions}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		if _, err := hub.DeleteMessage(identity, chatID, messageID); err != nil {
+			writeModerationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ChatRestrictionsHandler – ограничения пользователей в чате. Только для операторов.
+//
+//	GET    /chats/{id}/restrictions                   – действующие ограничения
+//	POST   /chats/{id}/restrictions                   – {"guest_id": 42, "kind": "mute", "duration": "24h", "reason": "..."}
+//	DELETE /chats/{id}/restrictions/{guest_id}/{kind} – снять ограничение досрочно
+//
+// Ограничение привязано к id гостя (sender_id сообщения), а не к имени, которое можно сменить.
+// kind – mute (только чтение) или ban (отключение от чата); duration вида 30m, 24h, 7d,
+// пустая – бессрочно.
+func ChatRestrictionsHandler(hub *chat.ChatHub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !identity.Can(auth.PermChatAdmin) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+		// {id}/restrictions[/{guest_id}/{kind}]
+		parts := strings.Split(strings.Trim(r.URL.Path[len("/chats/"):], "/"), "/")
+		chatID := parts[0]
+
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodGet:
+			list, err := hub.Restrictions(chatID)
+			if err != nil {
+				writeModerationError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(list)
+
+		case len(parts) == 2 && r.Method == http.MethodPost:
+			var req struct {
+				GuestID  int    `json:"guest_id"`
+				Kind     string `json:"kind"`
+				Duration string `json:"duration"`
+				Reason   string `json:"reason"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+				return
+			}
+			duration, err := chat.ParseRestrictionDuration(req.Duration)
+			if err != nil {
+				http.Error(w, "Некорректная длительность: ожидается 30m, 24h, 7d или пустая строка", http.StatusBadRequest)
+				return
+			}
+			restriction := chat.Restriction{
+				ChatID:    chatID,
+				GuestID:   req.GuestID,
+				Kind:      req.Kind,
+				Reason:    req.Reason,
+				CreatedBy: identity.Username,
+			}
+			if duration > 0 {
+				restriction.ExpiresAt = time.Now().Add(duration).UnixMilli()
+			}
+			restriction, err = hub.Restrict(restriction)
+			if err != nil {
+				writeModerationError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(restriction)
+
+		case len(parts) == 4 && r.Method == http.MethodDelete:
+			guestID, err := strconv.Atoi(parts[2])
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			if err := hub.Unrestrict(chatID, guestID, parts[3]); err != nil {
+				writeModerationError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		case len(parts) == 2 || len(parts) == 4:
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+// ChatEscalationsHandler – журнал реакций на флуд в чате (предупреждения, mute
+// и отключения за превышение лимитов), новые первыми. Только для операторов.
+// URL: GET /chats/{id}/escalations?limit={n}
+func ChatEscalationsHandler(store chat.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+			return
+		}
+		identity, _ := auth.IdentityFromContext(r.Context())
+		if !identity.Can(auth.PermChatAdmin) {
+			http.Error(w, "Доступ запрещён", http.StatusForbidden)
+			return
+		}
+		chatID, _, _ := strings.Cut(strings.Trim(r.URL.Path[len("/chats/"):], "/"), "/")
+		limit := chat.HistoryLimit
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "Некорректный параметр limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxMessagesPage)
+		}
+		list, err := store.Escalations(chatID, limit)
+		if err != nil {
+			log.Printf("Ошибка получения журнала флуда чата %s: %v", chatID, err)
+			http.Error(w, "Ошибка получения журнала", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// writeModerationError переводит ошибки модерации чата в HTTP-ответ.
+func writeModerationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, chat.ErrMessageNotFound):
+		http.Error(w, "Сообщение не найдено", http.StatusNotFound)
+	case errors.Is(err, chat.ErrRestrictionNotFound):
+		http.Error(w, "Ограничение не найдено", http.StatusNotFound)
+	case errors.Is(err, chat.ErrNotSender):
+		http.Error(w, "Можно удалять только свои сообщения", http.StatusForbidden)
+	case errors.Is(err, chat.ErrMessageDeleted):
+		http.Error(w, "Сообщение уже удалено", http.StatusConflict)
+	case errors.Is(err, chat.ErrInvalidRestriction):
+		http.Error(w, "Некорректное ограничение: kind – mute или ban, guest_id – id существующего гостя", http.StatusBadRequest)
+	default:
+		log.Printf("Ошибка модерации чата: %v", err)
+		http.Error(w, "Ошибка модерации чата", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/orders"
+)
+
+// OrderStatusHandler – эндпоинт статуса заказа. Требует RequireAuth.
+// URL: /orders/{id}/status
+//
+//	GET   – текущий статус и история; доступен владельцу заказа и персоналу.
+//	PATCH – смена статуса {"status": "accepted", "note": "..."}; только с правом orders:manage.
+func OrderStatusHandler(db *sql.DB, svc *orders.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		// Извлекаем id из URL
+		idStr, sub, _ := strings.Cut(r.URL.Path[len("/orders/"):], "/")
+		orderID, err := strconv.Atoi(idStr)
+		if err != nil || sub != "status" {
+			http.NotFound(w, r)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			var guestID int
+			var status orders.Status
+			err := db.QueryRow(`SELECT guest_id, status FROM orders WHERE id = $1`, orderID).Scan(&guestID, &status)
+			if err == sql.ErrNoRows {
+				http.Error(w, "Заказ не найден", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Ошибка получения заказа", http.StatusInternalServerError)
+				return
+			}
+			if guestID != identity.GuestID && !identity.Can(auth.PermManageOrders) {
+				http.Error(w, "Доступ запрещён", http.StatusForbidden)
+				return
+			}
+			history, err := svc.History(orderID)
+			if err != nil {
+				http.Error(w, "Ошибка получения истории заказа", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"order_id": orderID,
+				"status":   status,
+				"history":  history,
+			})
+		case http.MethodPatch:
+			if !identity.Can(auth.PermManageOrders) {
+				http.Error(w, "Доступ запрещён", http.StatusForbidden)
+				return
+			}
+			var req struct {
+				Status orders.Status `json:"status"`
+				Note   string        `json:"note"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Status.Valid() {
+				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
+				return
+			}
+			actorID := identity.GuestID
+			ev, err := svc.ChangeStatus(orderID, req.Status, &actorID, req.Note)
+			switch {
+			case errors.Is(err, orders.ErrNotFound):
+				http.Error(w, "Заказ не найден", http.StatusNotFound)
+				return
+			case errors.Is(err, orders.ErrIllegalTransition):
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			case err != nil:
+				log.Printf("Ошибка смены статуса заказа %d: %v", orderID, err)
+				http.Error(w, "Ошибка смены статуса заказа", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(ev)
+		default:
+			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+		}
+	}
+}
@@ -3,97 +3,205 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"go-robot/internal/auth"
 	"go-robot/internal/models"
+	"go-robot/internal/money"
+	"go-robot/internal/orders"
+
+	"github.com/lib/pq"
 )
 
-// OrdersHandler – эндпоинт для оформления заказа (POST) и получения истории заказов (GET)
-func OrdersHandler(db *sql.DB) http.HandlerFunc {
+// maxItemQuantity – наибольшее количество одного продукта в заказе (после
+// объединения одинаковых позиций).
+const maxItemQuantity = 99
+
+// orderItemRequest – позиция в запросе на оформление заказа.
+type orderItemRequest struct {
+	ProductID int `json:"product_id"`
+	Quantity  int `json:"quantity"`
+}
+
+// OrdersHandler – эндпоинт для оформления заказа (POST) и получения истории заказов (GET).
+// Заказы всегда относятся к аутентифицированному гостю. Требует RequireAuth.
+//
+// Тело POST: {"items": [{"product_id": 1, "quantity": 2}]}.
+// Старый формат {"product_ids": [1, 1]} по-прежнему принимается.
+// Все продукты заказа должны быть в одной валюте.
+func OrdersHandler(db *sql.DB, svc *orders.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
 		switch r.Method {
 		case http.MethodPost:
 			var req struct {
-				GuestID    int   `json:"guest_id"`
-				ProductIDs []int `json:"product_ids"`
+				Items      []orderItemRequest `json:"items"`
+				ProductIDs []int              `json:"product_ids"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 				return
 			}
-
-			var totalCalories int
-			var totalPrice float64 // Итоговая сумма в числовом формате.
-			// Реальная логика расчёта: пробегаем по каждому product_id и суммируем цены и калории.
 			for _, pid := range req.ProductIDs {
-				var priceStr string
-				var calories int
-				if err := db.QueryRow(`SELECT price, calories FROM products WHERE id = $1`, pid).Scan(&priceStr, &calories); err != nil {
-					http.Error(w, "Ошибка получения данных о продукте", http.StatusInternalServerError)
+				req.Items = append(req.Items, orderItemRequest{ProductID: pid, Quantity: 1})
+			}
+			if len(req.Items) == 0 {
+				http.Error(w, "Заказ не содержит позиций", http.StatusBadRequest)
+				return
+			}
+
+			tx, err := db.Begin()
+			if err != nil {
+				http.Error(w, "Ошибка сохранения заказа", http.StatusInternalServerError)
+				return
+			}
+			defer tx.Rollback()
+
+			order := models.Order{GuestID: identity.GuestID}
+			// Фиксируем цену, название и калорийность каждого продукта на момент заказа.
+			// Одинаковые продукты объединяются в одну позицию.
+			positions := make(map[int]int)
+			quantityError := fmt.Sprintf("Количество продукта должно быть от 1 до %d", maxItemQuantity)
+			for _, it := range req.Items {
+				if it.Quantity <= 0 || it.Quantity > maxItemQuantity {
+					http.Error(w, quantityError, http.StatusBadRequest)
+					return
+				}
+				if i, ok := positions[it.ProductID]; ok {
+					order.Items[i].Quantity += it.Quantity
+					if order.Items[i].Quantity > maxItemQuantity {
+						http.Error(w, quantityError, http.StatusBadRequest)
+						return
+					}
+					continue
+				}
+				item := models.OrderItem{Quantity: it.Quantity}
+				var available bool
+				// Для кухни категория фиксируется названием на языке по умолчанию
+				err := tx.QueryRow(`
+					SELECT p.id, p.title, p.price_minor, p.currency, p.calories, COALESCE(c.names ->> $2, c.slug), p.available
+					FROM products p JOIN categories c ON c.id = p.category_id
+					WHERE p.id = $1`, it.ProductID, models.DefaultLocale).
+					Scan(&item.ProductID, &item.Title, &item.UnitPrice.Minor, &item.UnitPrice.Currency, &item.Calories, &item.Category, &available)
+				if err == sql.ErrNoRows {
+					http.Error(w, fmt.Sprintf("Продукт %d не найден", it.ProductID), http.StatusBadRequest)
 					return
 				}
-				var price float64
-				// Предположим, что цена хранится в виде "$10". Убираем долларовый знак.
-				_, err := fmt.Sscanf(priceStr, "$%f", &price)
 				if err != nil {
-					http.Error(w, "Ошибка обработки цены продукта", http.StatusInternalServerError)
+					http.Error(w, "Ошибка получения данных о продукте", http.StatusInternalServerError)
+					return
+				}
+				if !available {
+					http.Error(w, fmt.Sprintf("Продукт %d сейчас недоступен", it.ProductID), http.StatusBadRequest)
 					return
 				}
-				totalCalories += calories
-				totalPrice += price
+				positions[it.ProductID] = len(order.Items)
+				order.Items = append(order.Items, item)
 			}
-
-			// Преобразуем список product_ids в JSON.
-			productIDsJSON, err := json.Marshal(req.ProductIDs)
-			if err != nil {
-				http.Error(w, "Ошибка обработки данных", http.StatusInternalServerError)
-				return
+			order.TotalPrice = money.Zero(order.Items[0].UnitPrice.Currency)
+			for _, item := range order.Items {
+				total, err := order.TotalPrice.Add(item.UnitPrice.Mul(item.Quantity))
+				if errors.Is(err, money.ErrCurrencyMismatch) {
+					http.Error(w, "Нельзя оформить заказ из продуктов в разных валютах", http.StatusBadRequest)
+					return
+				}
+				order.TotalPrice = total
+				order.TotalCalories += item.Calories * item.Quantity
 			}
 
-			var order models.Order
 			insertOrderQuery := `
-			INSERT INTO orders (guest_id, product_ids, total_price, total_calories)
+			INSERT INTO orders (guest_id, total_price_minor, currency, total_calories)
 			VALUES ($1, $2, $3, $4)
-			RETURNING id, guest_id, product_ids, total_price, total_calories, created_at`
-			if err := db.QueryRow(insertOrderQuery, req.GuestID, productIDsJSON, totalPrice, totalCalories).
-				Scan(&order.ID, &order.GuestID, &order.ProductIDs, &order.TotalPrice, &order.TotalCalories, &order.CreatedAt); err != nil {
+			RETURNING id, status, created_at`
+			if err := tx.QueryRow(insertOrderQuery, order.GuestID, order.TotalPrice.Minor, order.TotalPrice.Currency, order.TotalCalories).
+				Scan(&order.ID, &order.Status, &order.CreatedAt); err != nil {
+				http.Error(w, "Ошибка сохранения заказа", http.StatusInternalServerError)
+				return
+			}
+			placed, err := svc.RecordPlaced(tx, order.ID, identity.GuestID)
+			if err != nil {
+				http.Error(w, "Ошибка сохранения заказа", http.StatusInternalServerError)
+				return
+			}
+			for i, item := range order.Items {
+				if err := tx.QueryRow(`
+					INSERT INTO order_items (order_id, product_id, quantity, unit_price_minor, title, calories, category)
+					VALUES ($1, $2, $3, $4, $5, $6, $7)
+					RETURNING id`,
+					order.ID, item.ProductID, item.Quantity, item.UnitPrice.Minor, item.Title, item.Calories, item.Category).
+					Scan(&order.Items[i].ID); err != nil {
+					http.Error(w, "Ошибка сохранения позиций заказа", http.StatusInternalServerError)
+					return
+				}
+			}
+			if err := tx.Commit(); err != nil {
 				http.Error(w, "Ошибка сохранения заказа", http.StatusInternalServerError)
 				return
 			}
+			svc.Publish(placed)
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
 			json.NewEncoder(w).Encode(order)
 		case http.MethodGet:
-			guestIDStr := r.URL.Query().Get("guest_id")
-			if guestIDStr == "" {
-				http.Error(w, "guest_id не указан", http.StatusBadRequest)
-				return
-			}
-			rows, err := db.Query(`SELECT id, guest_id, product_ids, total_price, total_calories, created_at FROM orders WHERE guest_id = $1`, guestIDStr)
+			rows, err := db.Query(`SELECT id, guest_id, total_price_minor, currency, total_calories, status, created_at FROM orders WHERE guest_id = $1 ORDER BY id`, identity.GuestID)
 			if err != nil {
 				http.Error(w, "Ошибка получения заказов", http.StatusInternalServerError)
 				return
 			}
 			defer rows.Close()
-			orders := []models.Order{}
+			list := []models.Order{}
 			for rows.Next() {
 				var o models.Order
-				var productIDsJSON []byte
-				if err := rows.Scan(&o.ID, &o.GuestID, &productIDsJSON, &o.TotalPrice, &o.TotalCalories, &o.CreatedAt); err != nil {
+				if err := rows.Scan(&o.ID, &o.GuestID, &o.TotalPrice.Minor, &o.TotalPrice.Currency, &o.TotalCalories, &o.Status, &o.CreatedAt); err != nil {
 					http.Error(w, "Ошибка сканирования заказа", http.StatusInternalServerError)
 					return
 				}
-				if err := json.Unmarshal(productIDsJSON, &o.ProductIDs); err != nil {
-					http.Error(w, "Ошибка обработки данных заказа", http.StatusInternalServerError)
-					return
-				}
-				orders = append(orders, o)
+				list = append(list, o)
+			}
+			if err := loadOrderItems(db, list); err != nil {
+				http.Error(w, "Ошибка получения позиций заказа", http.StatusInternalServerError)
+				return
 			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			json.NewEncoder(w).Encode(orders)
+			json.NewEncoder(w).Encode(list)
 		default:
 			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
+// loadOrderItems заполняет Items у переданных заказов одним запросом.
+func loadOrderItems(db *sql.DB, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(orders))
+	byID := make(map[int]*models.Order, len(orders))
+	for i := range orders {
+		ids[i] = int64(orders[i].ID)
+		orders[i].Items = []models.OrderItem{}
+		byID[orders[i].ID] = &orders[i]
+	}
+	rows, err := db.Query(`
+		SELECT id, order_id, product_id, quantity, unit_price_minor, title, calories, category, started_at, done_at
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var orderID int
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &orderID, &item.ProductID, &item.Quantity, &item.UnitPrice.Minor, &item.Title, &item.Calories,
+			&item.Category, &item.StartedAt, &item.DoneAt); err != nil {
+			return err
+		}
+		// Цены позиций хранятся в валюте заказа.
+		item.UnitPrice.Currency = byID[orderID].TotalPrice.Currency
+		byID[orderID].Items = append(byID[orderID].Items, item)
+	}
+	return rows.Err()
+}
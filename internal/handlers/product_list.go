@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/models"
+	"go-robot/internal/money"
+	"go-robot/internal/orders"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// productPageSize – размер страницы списка продуктов по умолчанию.
+	productPageSize = 50
+	// maxProductPage – максимальный размер страницы.
+	maxProductPage = 200
+)
+
+// popularityExpr – сколько порций продукта заказано (без отменённых и отклонённых заказов).
+const popularityExpr = "COALESCE(pop.ordered, 0)"
+
+// productSortKeys – выражения сортировки по значению параметра sort. Порядок
+// всегда дополняется p.id, поэтому он стабилен и годится для курсора.
+// Цена сравнивается в пределах валюты.
+var productSortKeys = map[string][]string{
+	"":           {"c.position", "c.id"}, // как в меню: по категориям
+	"price":      {"p.currency", "p.price_minor"},
+	"calories":   {"p.calories"},
+	"popularity": {popularityExpr},
+	"title":      {"p.title"},
+}
+
+// productCursor – позиция последнего продукта страницы.
+type productCursor struct {
+	Sort string        `json:"s"`
+	Keys []interface{} `json:"k"` // значения productSortKeys[sort] без учёта направления
+	ID   int           `json:"id"`
+}
+
+func (c productCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(s string) (productCursor, bool) {
+	var c productCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // цены в минимальных единицах не теряют точность
+	if err := dec.Decode(&c); err != nil {
+		return c, false
+	}
+	return c, true
+}
+
+// cursorCondition – условие keyset-пагинации: строки строго после курсора
+// в порядке сортировки. values – плейсхолдеры значений keys и p.id.
+func cursorCondition(keys []string, desc bool, values []string) string {
+	cmp := ">"
+	if desc {
+		cmp = "<"
+	}
+	return "(" + strings.Join(keys, ", ") + ", p.id) " + cmp + " (" + strings.Join(values, ", ") + ")"
+}
+
+// productOrder – ORDER BY по keys и p.id в одном направлении.
+func productOrder(keys []string, desc bool) string {
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+	order := make([]string, 0, len(keys)+1)
+	for _, k := range append(keys[:len(keys):len(keys)], "p.id") {
+		order = append(order, k+" "+dir)
+	}
+	return strings.Join(order, ", ")
+}
+
+// listProducts – GET /products: продукты видимых категорий.
+// Параметры (все необязательные):
+//
+//	category=sushi,3             – категории по slug или ID
+//	price_min=5&price_max=$20    – цена в формате money.Parse, в одной валюте
+//	calories_min=100&calories_max=500
+//	tags=vegan,gluten-free       – все перечисленные метки из models.DietaryTags
+//	available=true               – только доступные (false – только недоступные)
+//	sort=price|calories|popularity|title, "-" в начале – по убыванию; по умолчанию – порядок меню
+//	limit=50 (не больше 200), cursor – next_cursor предыдущей страницы
+//
+// Ответ: {"products": [...], "next_cursor": "..."}; на последней странице next_cursor нет.
+func listProducts(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	query := r.URL.Query()
+	var (
+		args       []interface{}
+		conditions = []string{"c.visible"}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if v := query.Get("category"); v != "" {
+		var ids []int64
+		var slugs []string
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+				ids = append(ids, id)
+			} else if s != "" {
+				slugs = append(slugs, strings.ToLower(s))
+			}
+		}
+		conditions = append(conditions, "(p.category_id = ANY("+arg(pq.Array(ids))+") OR c.slug = ANY("+arg(pq.Array(slugs))+"))")
+	}
+
+	var currency string
+	for _, bound := range []struct{ param, op string }{{"price_min", ">="}, {"price_max", "<="}} {
+		v := query.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		price, err := money.Parse(v)
+		if err != nil || price.Minor < 0 {
+			http.Error(w, "Некорректный параметр "+bound.param, http.StatusBadRequest)
+			return
+		}
+		if currency != "" && currency != price.Currency {
+			http.Error(w, "price_min и price_max должны быть в одной валюте", http.StatusBadRequest)
+			return
+		}
+		if currency == "" {
+			currency = price.Currency
+			conditions = append(conditions, "p.currency = "+arg(currency))
+		}
+		conditions = append(conditions, "p.price_minor "+bound.op+" "+arg(price.Minor))
+	}
+
+	for _, bound := range []struct{ param, op string }{{"calories_min", ">="}, {"calories_max", "<="}} {
+		v := query.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Некорректный параметр "+bound.param, http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "p.calories "+bound.op+" "+arg(n))
+	}
+
+	if v := query.Get("tags"); v != "" {
+		tags, ok := normalizeTags(strings.Split(v, ","))
+		if !ok {
+			http.Error(w, "Неизвестная метка, допустимые: "+strings.Join(models.DietaryTags, ", "), http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "p.tags @> "+arg(pq.Array(tags))+"::text[]")
+	}
+
+	if v := query.Get("available"); v != "" {
+		available, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Некорректный параметр available", http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "p.available = "+arg(available))
+	}
+
+	sort := query.Get("sort")
+	desc := strings.HasPrefix(sort, "-")
+	sort = strings.TrimPrefix(sort, "-")
+	keys, ok := productSortKeys[sort]
+	if !ok {
+		http.Error(w, "Некорректный параметр sort: ожидается price, calories, popularity или title", http.StatusBadRequest)
+		return
+	}
+
+	limit := productPageSize
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Некорректный параметр limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxProductPage)
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, ok := decodeProductCursor(v)
+		if !ok || cursor.Sort != query.Get("sort") || len(cursor.Keys) != len(keys) {
+			http.Error(w, "Некорректный параметр cursor", http.StatusBadRequest)
+			return
+		}
+		placeholders := make([]string, 0, len(keys)+1)
+		for _, k := range cursor.Keys {
+			placeholders = append(placeholders, arg(k))
+		}
+		placeholders = append(placeholders, arg(cursor.ID))
+		conditions = append(conditions, cursorCondition(keys, desc, placeholders))
+	}
+
+	rows, err := db.Query(`
+		SELECT `+productColumns+`, `+popularityExpr+`, `+strings.Join(keys, ", ")+`
+		FROM products p
+		JOIN categories c ON c.id = p.category_id
+		LEFT JOIN (
+			SELECT i.product_id, SUM(i.quantity) AS ordered
+			FROM order_items i JOIN orders o ON o.id = i.order_id
+			WHERE o.status <> ALL(`+arg(pq.Array([]string{string(orders.StatusCancelled), string(orders.StatusRejected)}))+`)
+			GROUP BY i.product_id
+		) pop ON pop.product_id = p.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY `+productOrder(keys, desc)+`
+		LIMIT `+arg(limit+1), args...)
+	if err != nil {
+		log.Printf("Ошибка получения продуктов: %v", err)
+		http.Error(w, "Ошибка получения продуктов", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	resp := struct {
+		Products   []models.Product `json:"products"`
+		NextCursor string           `json:"next_cursor,omitempty"`
+	}{Products: []models.Product{}}
+	var last productCursor
+	for rows.Next() {
+		if len(resp.Products) == limit {
+			// Есть ещё хотя бы одна строка – отдаём курсор на последнюю выданную
+			resp.NextCursor = last.encode()
+			break
+		}
+		values := make([]interface{}, len(keys))
+		extra := []interface{}{new(int)}
+		for i := range values {
+			extra = append(extra, &values[i])
+		}
+		p, err := scanProduct(rows, extra...)
+		if err != nil {
+			http.Error(w, "Ошибка сканирования продукта", http.StatusInternalServerError)
+			return
+		}
+		p.Popularity = *extra[0].(*int)
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		last = productCursor{Sort: query.Get("sort"), Keys: values, ID: p.ID}
+		resp.Products = append(resp.Products, p)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Ошибка получения продуктов", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProductCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		cursor productCursor
+		keys   []interface{}
+	}{
+		{
+			cursor: productCursor{Sort: "", Keys: []interface{}{int64(2), int64(5)}, ID: 10},
+			keys:   []interface{}{json.Number("2"), json.Number("5")},
+		},
+		{
+			cursor: productCursor{Sort: "-price", Keys: []interface{}{"USD", int64(1050)}, ID: 7},
+			keys:   []interface{}{"USD", json.Number("1050")},
+		},
+		{
+			// Большие целые не проходят через float64 и не теряют точность.
+			cursor: productCursor{Sort: "price", Keys: []interface{}{"RUB", int64(9007199254740993)}, ID: 1},
+			keys:   []interface{}{"RUB", json.Number("9007199254740993")},
+		},
+		{
+			cursor: productCursor{Sort: "calories", Keys: []interface{}{nil}, ID: 3},
+			keys:   []interface{}{nil},
+		},
+		{
+			cursor: productCursor{Sort: "title", Keys: []interface{}{"Ролл «Дракон»"}, ID: 42},
+			keys:   []interface{}{"Ролл «Дракон»"},
+		},
+	}
+	for _, tt := range tests {
+		s := tt.cursor.encode()
+		got, ok := decodeProductCursor(s)
+		if !ok {
+			t.Errorf("decodeProductCursor(%q) failed for %+v", s, tt.cursor)
+			continue
+		}
+		want := productCursor{Sort: tt.cursor.Sort, Keys: tt.keys, ID: tt.cursor.ID}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decodeProductCursor(encode(%+v)) = %+v, want %+v", tt.cursor, got, want)
+		}
+	}
+}
+
+func TestDecodeProductCursorInvalid(t *testing.T) {
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, s := range []string{
+		"",
+		"not base64!",
+		base64.StdEncoding.EncodeToString([]byte(`{"s":"","k":[1],"id":1}`)), // с padding
+		b64("not json"),
+		b64(`{"s":"","k":[1],"id":"1"}`),
+		b64(`{"s":"","k":{},"id":1}`),
+		b64(`[1, 2]`),
+	} {
+		if c, ok := decodeProductCursor(s); ok {
+			t.Errorf("decodeProductCursor(%q) = %+v, want failure", s, c)
+		}
+	}
+}
+
+func TestCursorCondition(t *testing.T) {
+	tests := []struct {
+		keys   []string
+		desc   bool
+		values []string
+		want   string
+	}{
+		{
+			keys:   productSortKeys[""],
+			values: []string{"$1", "$2", "$3"},
+			want:   "(c.position, c.id, p.id) > ($1, $2, $3)",
+		},
+		{
+			keys:   productSortKeys["price"],
+			desc:   true,
+			values: []string{"$4", "$5", "$6"},
+			want:   "(p.currency, p.price_minor, p.id) < ($4, $5, $6)",
+		},
+		{
+			keys:   productSortKeys["popularity"],
+			values: []string{"$1", "$2"},
+			want:   "(COALESCE(pop.ordered, 0), p.id) > ($1, $2)",
+		},
+	}
+	for _, tt := range tests {
+		if got := cursorCondition(tt.keys, tt.desc, tt.values); got != tt.want {
+			t.Errorf("cursorCondition(%q, %v, %q) = %q, want %q", tt.keys, tt.desc, tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestProductOrder(t *testing.T) {
+	tests := []struct {
+		keys []string
+		desc bool
+		want string
+	}{
+		{keys: productSortKeys[""], want: "c.position ASC, c.id ASC, p.id ASC"},
+		{keys: productSortKeys["calories"], desc: true, want: "p.calories DESC, p.id DESC"},
+		{keys: productSortKeys["price"], want: "p.currency ASC, p.price_minor ASC, p.id ASC"},
+	}
+	for _, tt := range tests {
+		if got := productOrder(tt.keys, tt.desc); got != tt.want {
+			t.Errorf("productOrder(%q, %v) = %q, want %q", tt.keys, tt.desc, got, tt.want)
+		}
+	}
+
+	// Добавление p.id не портит общий срез productSortKeys.
+	keys := make([]string, 1, 4)
+	keys[0] = "p.title"
+	productOrder(keys, false)
+	if full := keys[:cap(keys)]; full[1] != "" {
+		t.Errorf("productOrder wrote %q past the end of keys", full[1])
+	}
+}
+
+func TestListProductsBadRequest(t *testing.T) {
+	priceCursor := productCursor{Sort: "price", Keys: []interface{}{"USD", 100}, ID: 1}.encode()
+	tests := []string{
+		"sort=weight",
+		"sort=--price",
+		"limit=0",
+		"limit=abc",
+		"price_min=abc",
+		"price_min=$1&price_max=2%20EUR",
+		"calories_max=-1",
+		"tags=unknown",
+		"available=maybe",
+		"cursor=%21%21",
+		"sort=-price&cursor=" + priceCursor,
+		"sort=title&cursor=" + priceCursor,
+		"sort=price&cursor=" + productCursor{Sort: "price", Keys: []interface{}{100}, ID: 1}.encode(),
+	}
+	for _, q := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/products?"+q, nil)
+		rec := httptest.NewRecorder()
+		// Запрос отклоняется до обращения к базе.
+		listProducts(rec, req, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /products?%s: status %d, want %d", q, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -4,54 +4,98 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"slices"
+	"strings"
 
 	"go-robot/internal/models"
+
+	"github.com/lib/pq"
 )
 
+// productColumns – поля продукта со slug категории; в запросе products p JOIN categories c.
+const productColumns = "p.id, p.title, p.description, p.price_minor, p.currency, p.calories, p.category_id, c.slug, p.image_url, p.tags, p.available"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProduct читает productColumns и дополнительные поля extra.
+func scanProduct(row rowScanner, extra ...interface{}) (models.Product, error) {
+	var p models.Product
+	dest := []interface{}{&p.ID, &p.Title, &p.Description, &p.Price.Minor, &p.Price.Currency, &p.Calories,
+		&p.CategoryID, &p.Category, &p.ImageURL, pq.Array(&p.Tags), &p.Available}
+	err := row.Scan(append(dest, extra...)...)
+	return p, err
+}
+
+// normalizeTags приводит метки к нижнему регистру и проверяет, что все они из models.DietaryTags.
+func normalizeTags(tags []string) ([]string, bool) {
+	normalized := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !slices.Contains(models.DietaryTags, t) {
+			return nil, false
+		}
+		if !slices.Contains(normalized, t) {
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized, true
+}
+
+// validateProduct проверяет обязательные поля и метки перед записью.
+func validateProduct(w http.ResponseWriter, prod *models.Product) bool {
+	if prod.Title == "" || prod.Description == "" || prod.Price.Currency == "" || prod.Calories == 0 ||
+		prod.CategoryID == 0 || prod.ImageURL == "" {
+		http.Error(w, "Заполните все обязательные поля", http.StatusBadRequest)
+		return false
+	}
+	tags, ok := normalizeTags(prod.Tags)
+	if !ok {
+		http.Error(w, "Неизвестная метка, допустимые: "+strings.Join(models.DietaryTags, ", "), http.StatusBadRequest)
+		return false
+	}
+	prod.Tags = tags
+	return true
+}
+
 // ProductsHandler – эндпоинт для создания (POST) и получения (GET) списка продуктов.
+// GET возвращает продукты видимых категорий с фильтрами и постраничной выдачей (см. listProducts).
+// Без available продукт создаётся доступным.
 func ProductsHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			var prod models.Product
+			prod := models.Product{Available: true}
 			if err := json.NewDecoder(r.Body).Decode(&prod); err != nil {
 				http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 				return
 			}
-			if prod.Title == "" || prod.Description == "" || prod.Price == "" || prod.Calories == 0 ||
-				prod.Category == "" || prod.ImageURL == "" {
-				http.Error(w, "Заполните все обязательные поля", http.StatusBadRequest)
+			if !validateProduct(w, &prod) {
 				return
 			}
+			// Вставка из categories: для несуществующей категории строк не будет
 			insertQuery := `
-			INSERT INTO products (title, description, price, calories, category, image_url)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			RETURNING id, title, description, price, calories, category, image_url`
-			if err := db.QueryRow(insertQuery, prod.Title, prod.Description, prod.Price, prod.Calories, prod.Category, prod.ImageURL).
-				Scan(&prod.ID, &prod.Title, &prod.Description, &prod.Price, &prod.Calories, &prod.Category, &prod.ImageURL); err != nil {
-				http.Error(w, "Ошибка сохранения продукта", http.StatusInternalServerError)
+			WITH p AS (
+				INSERT INTO products (title, description, price_minor, currency, calories, category_id, image_url, tags, available)
+				SELECT $1, $2, $3, $4, $5, id, $7, $8, $9 FROM categories WHERE id = $6
+				RETURNING *
+			)
+			SELECT ` + productColumns + ` FROM p JOIN categories c ON c.id = p.category_id`
+			prod, err := scanProduct(db.QueryRow(insertQuery, prod.Title, prod.Description, prod.Price.Minor, prod.Price.Currency, prod.Calories, prod.CategoryID, prod.ImageURL,
+				pq.Array(prod.Tags), prod.Available))
+			if err == sql.ErrNoRows {
+				http.Error(w, "Категория не найдена", http.StatusBadRequest)
 				return
 			}
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			json.NewEncoder(w).Encode(prod)
-		case http.MethodGet:
-			rows, err := db.Query(`SELECT id, title, description, price, calories, category, image_url FROM products`)
 			if err != nil {
-				http.Error(w, "Ошибка получения продуктов", http.StatusInternalServerError)
+				http.Error(w, "Ошибка сохранения продукта", http.StatusInternalServerError)
 				return
 			}
-			defer rows.Close()
-			products := []models.Product{}
-			for rows.Next() {
-				var p models.Product
-				if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Price, &p.Calories, &p.Category, &p.ImageURL); err != nil {
-					http.Error(w, "Ошибка сканирования продукта", http.StatusInternalServerError)
-					return
-				}
-				products = append(products, p)
-			}
 			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			json.NewEncoder(w).Encode(products)
+			json.NewEncoder(w).Encode(prod)
+		case http.MethodGet:
+			listProducts(w, r, db)
 		default:
 			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
 		}
@@ -59,7 +103,7 @@ func ProductsHandler(db *sql.DB) http.HandlerFunc {
 }
 
 // ProductUpdateHandler – эндпоинт для редактирования продукта по ID (PUT)
-// URL должен иметь вид: /products/{id}
+// URL должен иметь вид: /products/{id}. Без available продукт считается доступным.
 func ProductUpdateHandler(db *sql.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Извлекаем id из URL
@@ -68,23 +112,28 @@ func ProductUpdateHandler(db *sql.DB) http.HandlerFunc {
 			http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
 			return
 		}
-		var prod models.Product
+		prod := models.Product{Available: true}
 		if err := json.NewDecoder(r.Body).Decode(&prod); err != nil {
 			http.Error(w, "Неверный формат запроса", http.StatusBadRequest)
 			return
 		}
-		if prod.Title == "" || prod.Description == "" || prod.Price == "" || prod.Calories == 0 ||
-			prod.Category == "" || prod.ImageURL == "" {
-			http.Error(w, "Заполните все обязательные поля", http.StatusBadRequest)
+		if !validateProduct(w, &prod) {
 			return
 		}
 		updateQuery := `
-		UPDATE products
-		SET title = $1, description = $2, price = $3, calories = $4, category = $5, image_url = $6
-		WHERE id = $7
-		RETURNING id, title, description, price, calories, category, image_url`
-		if err := db.QueryRow(updateQuery, prod.Title, prod.Description, prod.Price, prod.Calories, prod.Category, prod.ImageURL, idStr).
-			Scan(&prod.ID, &prod.Title, &prod.Description, &prod.Price, &prod.Calories, &prod.Category, &prod.ImageURL); err != nil {
+		UPDATE products p
+		SET title = $1, description = $2, price_minor = $3, currency = $4, calories = $5, category_id = c.id, image_url = $7,
+		    tags = $9, available = $10
+		FROM categories c
+		WHERE p.id = $8 AND c.id = $6
+		RETURNING ` + productColumns
+		prod, err := scanProduct(db.QueryRow(updateQuery, prod.Title, prod.Description, prod.Price.Minor, prod.Price.Currency, prod.Calories, prod.CategoryID, prod.ImageURL, idStr,
+			pq.Array(prod.Tags), prod.Available))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Продукт или категория не найдены", http.StatusNotFound)
+			return
+		}
+		if err != nil {
 			http.Error(w, "Ошибка обновления продукта", http.StatusInternalServerError)
 			return
 		}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-robot/internal/auth"
+	"go-robot/internal/support"
+)
+
+// SupportHandler – очередь поддержки для операторов. Требует RequirePermission(auth.PermChatAdmin).
+//
+//	GET  /support/tickets?status=waiting&mine=1 – обращения (по умолчанию все открытые)
+//	GET  /support/tickets/{id}                  – одно обращение
+//	POST /support/tickets/{id}/claim            – взять обращение себе
+//	POST /support/tickets/{id}/transfer         – передать {"operator_id": 7}
+//	POST /support/tickets/{id}/close            – закрыть
+//	PUT  /support/availability                  – {"available": true, "max_tickets": 5}
+func SupportHandler(svc *support.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.IdentityFromContext(r.Context())
+		path := strings.Trim(r.URL.Path[len("/support/"):], "/")
+
+		switch path {
+		case "availability":
+			if r.Method != http.MethodPut {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			var req struct {
+				Available  bool `json:"available"`
+				MaxTickets int  `json:"max_tickets"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Некорректный JSON", http.StatusBadRequest)
+				return
+			}
+			if err := svc.SetAvailability(identity.GuestID, req.Available, req.MaxTickets); err != nil {
+				log.Printf("Ошибка изменения доступности оператора %d: %v", identity.GuestID, err)
+				http.Error(w, "Ошибка изменения доступности", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+
+		case "tickets":
+			if r.Method != http.MethodGet {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			status := support.Status(r.URL.Query().Get("status"))
+			if status != "" && !status.Valid() {
+				http.Error(w, "Некорректный параметр status", http.StatusBadRequest)
+				return
+			}
+			operatorID := 0
+			if r.URL.Query().Get("mine") == "1" {
+				operatorID = identity.GuestID
+			}
+			list, err := svc.List(status, operatorID)
+			if err != nil {
+				log.Printf("Ошибка получения очереди поддержки: %v", err)
+				http.Error(w, "Ошибка получения обращений", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(list)
+			return
+		}
+
+		// tickets/{id}[/{action}]
+		parts := strings.Split(path, "/")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] != "tickets" {
+			http.NotFound(w, r)
+			return
+		}
+		ticketID, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		var ticket support.Ticket
+		if len(parts) == 2 {
+			if r.Method != http.MethodGet {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			ticket, err = svc.Get(ticketID)
+		} else {
+			if r.Method != http.MethodPost {
+				http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+				return
+			}
+			switch parts[2] {
+			case "claim":
+				ticket, err = svc.Claim(ticketID, identity.GuestID)
+			case "transfer":
+				var req struct {
+					OperatorID int `json:"operator_id"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.OperatorID <= 0 {
+					http.Error(w, "Укажите operator_id", http.StatusBadRequest)
+					return
+				}
+				ticket, err = svc.Transfer(ticketID, identity, req.OperatorID)
+			case "close":
+				ticket, err = svc.Close(ticketID, identity)
+			default:
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		switch {
+		case errors.Is(err, support.ErrNotFound):
+			http.Error(w, "Обращение не найдено", http.StatusNotFound)
+		case errors.Is(err, support.ErrNotOperator):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, support.ErrNotAssignee):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, support.ErrClosed), errors.Is(err, support.ErrAlreadyAssigned):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			log.Printf("Ошибка обработки обращения %d: %v", ticketID, err)
+			http.Error(w, "Ошибка обработки обращения", http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(ticket)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package kitchen
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go-robot/internal/orders"
+
+	"github.com/lib/pq"
+)
+
+// ErrItemNotFound возвращается, если позиции нет или её заказ уже не в работе.
+var ErrItemNotFound = errors.New("позиция не найдена среди активных заказов")
+
+// activeStatuses – статусы заказов, которые видит кухня.
+var activeStatuses = []string{string(orders.StatusPlaced), string(orders.StatusAccepted), string(orders.StatusCooking)}
+
+// Item – позиция заказа на кухонном экране.
+type Item struct {
+	ID             int64      `json:"id"`
+	Title          string     `json:"title"`
+	Quantity       int        `json:"quantity"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	DoneAt         *time.Time `json:"done_at,omitempty"`
+	ElapsedSeconds int64      `json:"elapsed_seconds"` // с начала приготовления (0, если не начата)
+}
+
+// Group – позиции одной категории (суши, роллы, сашими…).
+type Group struct {
+	Category string `json:"category"`
+	Items    []Item `json:"items"`
+}
+
+// Order – активный заказ на кухонном экране.
+type Order struct {
+	ID             int       `json:"id"`
+	Status         string    `json:"status"`
+	PlacedAt       time.Time `json:"placed_at"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"` // с момента оформления
+	Groups         []Group   `json:"groups"`
+}
+
+// Service – очередь кухни и отметки готовности позиций.
+type Service struct {
+	db     *sql.DB
+	orders *orders.Service
+
+	mu        sync.RWMutex
+	listeners []func(orderID int)
+}
+
+// NewService создаёт Service.
+func NewService(db *sql.DB, svc *orders.Service) *Service {
+	return &Service{db: db, orders: svc}
+}
+
+// OnChange регистрирует обработчик, вызываемый после отметки позиции.
+func (s *Service) OnChange(fn func(orderID int)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+func (s *Service) notify(orderID int) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(orderID)
+	}
+}
+
+// Queue возвращает активные заказы, начиная с самых старых.
+func (s *Service) Queue() ([]Order, error) {
+	return s.load(`o.status = ANY($1)`, pq.Array(activeStatuses))
+}
+
+// Order возвращает один заказ, если он ещё активен; ok = false, если заказ покинул очередь.
+func (s *Service) Order(orderID int) (Order, bool, error) {
+	list, err := s.load(`o.status = ANY($1) AND o.id = $2`, pq.Array(activeStatuses), orderID)
+	if err != nil || len(list) == 0 {
+		return Order{}, false, err
+	}
+	return list[0], true, nil
+}
+
+func (s *Service) load(where string, args ...interface{}) ([]Order, error) {
+	rows, err := s.db.Query(`
+		SELECT o.id, o.status, o.created_at, i.id, i.title, i.quantity, i.category, i.started_at, i.done_at
+		FROM orders o
+		JOIN order_items i ON i.order_id = o.id
+		WHERE `+where+`
+		ORDER BY o.created_at, o.id, i.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	list := []Order{}
+	var current *Order
+	groups := make(map[string]int)
+	for rows.Next() {
+		var (
+			o        Order
+			it       Item
+			category string
+		)
+		if err := rows.Scan(&o.ID, &o.Status, &o.PlacedAt, &it.ID, &it.Title, &it.Quantity, &category, &it.StartedAt, &it.DoneAt); err != nil {
+			return nil, err
+		}
+		if current == nil || current.ID != o.ID {
+			o.ElapsedSeconds = int64(now.Sub(o.PlacedAt).Seconds())
+			o.Groups = []Group{}
+			list = append(list, o)
+			current = &list[len(list)-1]
+			groups = make(map[string]int)
+		}
+		if it.StartedAt != nil {
+			end := now
+			if it.DoneAt != nil {
+				end = *it.DoneAt
+			}
+			it.ElapsedSeconds = int64(end.Sub(*it.StartedAt).Seconds())
+		}
+		if category == "" {
+			category = "прочее"
+		}
+		gi, ok := groups[category]
+		if !ok {
+			gi = len(current.Groups)
+			groups[category] = gi
+			current.Groups = append(current.Groups, Group{Category: category})
+		}
+		current.Groups[gi].Items = append(current.Groups[gi].Items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		sort.SliceStable(list[i].Groups, func(a, b int) bool { return list[i].Groups[a].Category < list[i].Groups[b].Category })
+	}
+	return list, nil
+}
+
+// Start отмечает, что кухня начала готовить позицию.
+// Заказ при этом автоматически переходит в cooking (через accepted, если он ещё не принят).
+func (s *Service) Start(itemID int64, actorID int) error {
+	orderID, err := s.bump(itemID, `UPDATE order_items SET started_at = COALESCE(started_at, NOW()) WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	s.promote(orderID, actorID)
+	s.notify(orderID)
+	return nil
+}
+
+// Done отмечает позицию готовой. Когда готовы все позиции, заказ автоматически переходит в ready.
+func (s *Service) Done(itemID int64, actorID int) error {
+	orderID, err := s.bump(itemID, `
+		UPDATE order_items
+		SET started_at = COALESCE(started_at, NOW()), done_at = COALESCE(done_at, NOW())
+		WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	s.promote(orderID, actorID)
+	s.notify(orderID)
+	return nil
+}
+
+// bump применяет query к позиции активного заказа и возвращает id заказа.
+// Заказ блокируется до конца транзакции, чтобы его не сняли с кухни между проверкой и отметкой.
+func (s *Service) bump(itemID int64, query string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var orderID int
+	err = tx.QueryRow(`
+		SELECT o.id FROM order_items i
+		JOIN orders o ON o.id = i.order_id
+		WHERE i.id = $1 AND o.status = ANY($2)
+		FOR UPDATE OF o`, itemID, pq.Array(activeStatuses)).Scan(&orderID)
+	if err == sql.ErrNoRows {
+		return 0, ErrItemNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(query, itemID); err != nil {
+		return 0, err
+	}
+	return orderID, tx.Commit()
+}
+
+// promote продвигает заказ по статусам в соответствии с отметками позиций:
+// начатая позиция переводит заказ в cooking, все готовые позиции – в ready.
+func (s *Service) promote(orderID, actorID int) {
+	var status orders.Status
+	var started, pending int
+	err := s.db.QueryRow(`
+		SELECT o.status,
+		       COUNT(*) FILTER (WHERE i.started_at IS NOT NULL),
+		       COUNT(*) FILTER (WHERE i.done_at IS NULL)
+		FROM orders o
+		JOIN order_items i ON i.order_id = o.id
+		WHERE o.id = $1
+		GROUP BY o.status`, orderID).Scan(&status, &started, &pending)
+	if err != nil {
+		log.Printf("Ошибка проверки готовности заказа %d: %v", orderID, err)
+		return
+	}
+
+	steps := []orders.Status{}
+	if status == orders.StatusPlaced && started > 0 {
+		steps = append(steps, orders.StatusAccepted)
+		status = orders.StatusAccepted
+	}
+	if status == orders.StatusAccepted && started > 0 {
+		steps = append(steps, orders.StatusCooking)
+		status = orders.StatusCooking
+	}
+	if status == orders.StatusCooking && pending == 0 {
+		steps = append(steps, orders.StatusReady)
+	}
+	for _, to := range steps {
+		note := "приготовление начато"
+		switch to {
+		case orders.StatusAccepted:
+			note = "принят кухней"
+		case orders.StatusReady:
+			note = "все позиции готовы"
+		}
+		if _, err := s.orders.ChangeStatus(orderID, to, &actorID, note); err != nil {
+			log.Printf("Ошибка автоматической смены статуса заказа %d на %s: %v", orderID, to, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // регистрация декодеров
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+var (
+	// ErrUnsupportedType возвращается для файлов, не являющихся изображением допустимого типа.
+	ErrUnsupportedType = errors.New("неподдерживаемый тип файла")
+	// ErrTooManyPixels возвращается для изображений слишком большого разрешения.
+	ErrTooManyPixels = errors.New("слишком большое разрешение изображения")
+)
+
+// MaxPixels ограничивает разрешение, чтобы маленький файл не распаковался в гигабайты памяти:
+// распакованное изображение занимает до 4 байт на пиксель.
+const MaxPixels = 16_000_000
+
+// maxDecodes – сколько изображений распаковывается одновременно; остальные ждут.
+const maxDecodes = 2
+
+// decodeSlots ограничивает память, занятую распакованными изображениями.
+var decodeSlots = make(chan struct{}, maxDecodes)
+
+// maxSamples – сколько пикселей исходника по каждой оси усредняется в один пиксель миниатюры.
+const maxSamples = 4
+
+// allowedTypes – допустимые типы изображений и их расширения.
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image – проверенное изображение.
+type Image struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// Inspect определяет тип по содержимому (заголовку клиента не доверяем)
+// и проверяет, что изображение читается и не превышает MaxPixels.
+func Inspect(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedTypes[contentType]
+	if !ok {
+		return Image{}, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, ErrTooManyPixels
+	}
+	return Image{ContentType: contentType, Extension: ext, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// Thumbnail уменьшает изображение так, чтобы большая сторона не превышала maxSide,
+// и кодирует его в JPEG. Прозрачные области заливаются белым.
+func Thumbnail(data []byte, maxSide int) ([]byte, error) {
+	decodeSlots <- struct{}{}
+	defer func() { <-decodeSlots }()
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			w, h = maxSide, max(1, h*maxSide/w)
+		} else {
+			w, h = max(1, w*maxSide/h), maxSide
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	scale(dst, src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scale усредняет пиксели исходника, попадающие в каждый пиксель dst (box-фильтр),
+// и накладывает результат на белый фон. Из большого прямоугольника берётся не больше
+// maxSamples×maxSamples равномерно расположенных пикселей, поэтому время не зависит от размера исходника.
+func scale(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	dw, dh := dst.Bounds().Dx(), dst.Bounds().Dy()
+	for y := 0; y < dh; y++ {
+		y0 := sb.Min.Y + y*sb.Dy()/dh
+		y1 := max(y0+1, sb.Min.Y+(y+1)*sb.Dy()/dh)
+		for x := 0; x < dw; x++ {
+			x0 := sb.Min.X + x*sb.Dx()/dw
+			x1 := max(x0+1, sb.Min.X+(x+1)*sb.Dx()/dw)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy += max(1, (y1-y0)/maxSamples) {
+				for sx := x0; sx < x1; sx += max(1, (x1-x0)/maxSamples) {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Цвета RGBA() уже умножены на альфу: поверх белого добавляется 0xffff-a.
+			white := 0xffff - a/n
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r/n + white), G: uint16(g/n + white), B: uint16(bl/n + white), A: 0xffff,
+			})
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Файлы миграций лежат в каталоге sql и называются
+// <версия>_<имя>.up.sql / <версия>_<имя>.down.sql, например 0001_init.up.sql.
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey – ключ advisory-блокировки, защищающей от одновременного запуска миграций.
+const lockKey int64 = 727_000_001
+
+// Migration – одна версия схемы с SQL для применения и отката.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // sha256 от Up-скрипта
+}
+
+// Status – состояние миграции относительно базы данных.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"` // файл изменён после применения
+}
+
+// Load читает встроенные файлы миграций и возвращает их, отсортированными по версии.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("некорректное имя файла миграции: %s", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная версия в файле миграции %s: %w", name, err)
+		}
+		body, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("версия %d используется миграциями %s и %s", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("у миграции %04d_%s нет up-скрипта", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator применяет и откатывает миграции в базе данных.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New создаёт Migrator со встроенным набором миграций.
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// appliedMigration – строка таблицы schema_migrations.
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// withLock выполняет fn на отдельном соединении, удерживая advisory-блокировку.
+// Advisory-блокировка привязана к сессии, поэтому всё выполняется на одном *sql.Conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Printf("Ошибка снятия блокировки миграций: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// applied возвращает применённые миграции по версиям.
+func applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		result[version] = a
+	}
+	return result, rows.Err()
+}
+
+// Up применяет все ещё не применённые миграции и возвращает их количество.
+// Если уже применённая миграция изменилась, ничего не применяется.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if a, ok := done[mig.Version]; ok && a.checksum != mig.Checksum {
+				return fmt.Errorf("миграция %04d_%s изменена после применения (checksum не совпадает)", mig.Version, mig.Name)
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := runInTx(ctx, conn, mig.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx,
+					`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+					mig.Version, mig.Name, mig.Checksum)
+				return err
+			}); err != nil {
+				return fmt.Errorf("миграция %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Миграция %04d_%s применена", mig.Version, mig.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает последние steps применённых миграций и возвращает их количество.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("у миграции %04d_%s нет down-скрипта", mig.Version, mig.Name)
+			}
+			if err := runInTx(ctx, conn, mig.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("откат миграции %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Миграция %04d_%s откачена", mig.Version, mig.Name)
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status возвращает состояние всех известных миграций.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var result []Status
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			s := Status{Version: mig.Version, Name: mig.Name}
+			if a, ok := done[mig.Version]; ok {
+				appliedAt := a.appliedAt
+				s.Applied = true
+				s.AppliedAt = &appliedAt
+				s.Modified = a.checksum != mig.Checksum
+			}
+			result = append(result, s)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// runInTx выполняет скрипт и запись в schema_migrations в одной транзакции.
+func runInTx(ctx context.Context, conn *sql.Conn, script string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,30 @@
+-- Базовая схема: гости, продукты и заказы.
+-- IF NOT EXISTS позволяет принять миграцию на базе, созданной вручную до её появления.
+CREATE TABLE IF NOT EXISTS guests (
+    id       SERIAL PRIMARY KEY,
+    username TEXT NOT NULL UNIQUE,
+    email    TEXT NOT NULL,
+    password TEXT NOT NULL,
+    phone    TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS products (
+    id          SERIAL PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL,
+    price       TEXT NOT NULL,
+    calories    INTEGER NOT NULL,
+    category    TEXT NOT NULL,
+    image_url   TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id             SERIAL PRIMARY KEY,
+    guest_id       INTEGER NOT NULL REFERENCES guests (id),
+    product_ids    JSONB NOT NULL DEFAULT '[]',
+    total_price    NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    total_calories INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS orders_guest_id_idx ON orders (guest_id);
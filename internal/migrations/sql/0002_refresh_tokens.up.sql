@@ -0,0 +1,16 @@
+-- Refresh-токены хранятся только в виде sha256-хеша.
+-- Все токены одной сессии делят family_id: при повторном использовании
+-- уже заменённого токена отзывается вся семья.
+CREATE TABLE refresh_tokens (
+    id          BIGSERIAL PRIMARY KEY,
+    guest_id    INTEGER NOT NULL REFERENCES guests (id) ON DELETE CASCADE,
+    family_id   TEXT NOT NULL,
+    token_hash  TEXT NOT NULL UNIQUE,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    replaced_at TIMESTAMPTZ,
+    revoked_at  TIMESTAMPTZ
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_guest_id_idx ON refresh_tokens (guest_id);
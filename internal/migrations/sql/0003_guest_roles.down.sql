@@ -0,0 +1 @@
+ALTER TABLE guests DROP COLUMN IF EXISTS role;
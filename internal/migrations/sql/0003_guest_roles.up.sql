@@ -0,0 +1,3 @@
+ALTER TABLE guests
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'guest'
+        CHECK (role IN ('guest', 'operator', 'kitchen', 'admin'));
@@ -0,0 +1,14 @@
+ALTER TABLE orders ADD COLUMN product_ids JSONB NOT NULL DEFAULT '[]';
+
+UPDATE orders o
+SET product_ids = items.ids
+FROM (
+    SELECT i.order_id, jsonb_agg(i.product_id ORDER BY i.id) AS ids
+    FROM order_items i
+    CROSS JOIN LATERAL generate_series(1, i.quantity)
+    WHERE i.product_id IS NOT NULL
+    GROUP BY i.order_id
+) AS items
+WHERE items.order_id = o.id;
+
+DROP TABLE IF EXISTS order_items;
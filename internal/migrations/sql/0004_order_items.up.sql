@@ -0,0 +1,32 @@
+-- Позиции заказа с количеством и снимком цены, названия и калорийности на момент заказа.
+CREATE TABLE order_items (
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    product_id INTEGER REFERENCES products (id) ON DELETE SET NULL,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(12, 2) NOT NULL,
+    title      TEXT NOT NULL,
+    calories   INTEGER NOT NULL
+);
+
+CREATE INDEX order_items_order_id_idx ON order_items (order_id);
+
+-- Перенос product_ids: повторяющиеся ID превращаются в одну позицию с quantity.
+-- Цена исторических заказов не сохранялась, поэтому берётся текущая цена продукта ("$10" -> 10).
+-- Десятичный разделитель – точка или запятая перед последними одной-двумя цифрами,
+-- остальные точки и запятые разделяют тысячи ("$1,234.50" и "1.234,50 €" -> 1234.50).
+INSERT INTO order_items (order_id, product_id, quantity, unit_price, title, calories)
+SELECT o.id,
+       p.id,
+       COUNT(*),
+       COALESCE(NULLIF(replace(regexp_replace(regexp_replace(
+           regexp_replace(p.price, '[^0-9.,]', '', 'g'),
+           '[.,]([0-9]{1,2})$', 'D\1'), '[.,]', '', 'g'), 'D', '.'), '')::NUMERIC, 0),
+       COALESCE(p.title, 'Удалённый продукт #' || e.pid),
+       COALESCE(p.calories, 0)
+FROM orders o
+CROSS JOIN LATERAL jsonb_array_elements_text(o.product_ids::JSONB) AS e (pid)
+LEFT JOIN products p ON p.id = e.pid::INTEGER
+GROUP BY o.id, e.pid, p.id, p.price, p.title, p.calories;
+
+ALTER TABLE orders DROP COLUMN product_ids;
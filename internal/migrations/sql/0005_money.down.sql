@@ -0,0 +1,11 @@
+ALTER TABLE order_items ADD COLUMN unit_price NUMERIC(12, 2) NOT NULL DEFAULT 0;
+UPDATE order_items SET unit_price = unit_price_minor / 100.0;
+ALTER TABLE order_items DROP COLUMN unit_price_minor;
+
+ALTER TABLE orders ADD COLUMN total_price NUMERIC(12, 2) NOT NULL DEFAULT 0;
+UPDATE orders SET total_price = total_price_minor / 100.0;
+ALTER TABLE orders DROP COLUMN total_price_minor, DROP COLUMN currency;
+
+ALTER TABLE products ADD COLUMN price TEXT NOT NULL DEFAULT '';
+UPDATE products SET price = '$' || trim_scale(price_minor / 100.0)::TEXT;
+ALTER TABLE products DROP COLUMN price_minor, DROP COLUMN currency;
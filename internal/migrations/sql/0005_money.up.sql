@@ -0,0 +1,46 @@
+-- Цены хранятся целым числом минимальных единиц (центов) с кодом валюты ISO 4217.
+
+ALTER TABLE products
+    ADD COLUMN price_minor BIGINT,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+UPDATE products
+SET currency = CASE
+        WHEN price LIKE '%€%' OR upper(price) LIKE '%EUR%' THEN 'EUR'
+        WHEN price LIKE '%₽%' OR upper(price) LIKE '%RUB%' THEN 'RUB'
+        WHEN price LIKE '%zł%' OR upper(price) LIKE '%PLN%' THEN 'PLN'
+        ELSE 'USD'
+    END,
+    -- Десятичный разделитель – точка или запятая перед последними одной-двумя цифрами,
+    -- остальные точки и запятые разделяют тысячи: "$1,234.50", "1.234,50 €" и "1 234,5 ₽".
+    price_minor = ROUND(COALESCE(NULLIF(replace(regexp_replace(regexp_replace(
+        regexp_replace(price, '[^0-9.,]', '', 'g'),
+        '[.,]([0-9]{1,2})$', 'D\1'), '[.,]', '', 'g'), 'D', '.'), '')::NUMERIC, 0) * 100);
+
+ALTER TABLE products
+    ALTER COLUMN price_minor SET NOT NULL,
+    ADD CONSTRAINT products_price_minor_check CHECK (price_minor >= 0),
+    DROP COLUMN price;
+
+ALTER TABLE orders
+    ADD COLUMN total_price_minor BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Валюта заказа – валюта его продуктов; заказы без известных продуктов остаются в USD.
+UPDATE orders o
+SET total_price_minor = ROUND(o.total_price * 100),
+    currency = COALESCE((
+        SELECT p.currency
+        FROM order_items oi
+        JOIN products p ON p.id = oi.product_id
+        WHERE oi.order_id = o.id
+        ORDER BY oi.id
+        LIMIT 1), 'USD');
+
+ALTER TABLE orders DROP COLUMN total_price;
+
+ALTER TABLE order_items ADD COLUMN unit_price_minor BIGINT NOT NULL DEFAULT 0;
+
+UPDATE order_items SET unit_price_minor = ROUND(unit_price * 100);
+
+ALTER TABLE order_items DROP COLUMN unit_price;
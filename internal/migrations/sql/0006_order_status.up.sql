@@ -0,0 +1,20 @@
+ALTER TABLE orders
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'placed'
+        CHECK (status IN ('placed', 'accepted', 'cooking', 'ready', 'out_for_delivery',
+                          'delivered', 'cancelled', 'rejected'));
+
+CREATE TABLE order_status_events (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status   TEXT NOT NULL,
+    actor_id    INTEGER REFERENCES guests (id) ON DELETE SET NULL,
+    note        TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX order_status_events_order_id_idx ON order_status_events (order_id);
+
+-- Существующие заказы получают начальное событие "placed" от имени гостя.
+INSERT INTO order_status_events (order_id, from_status, to_status, actor_id, created_at)
+SELECT id, NULL, 'placed', guest_id, created_at FROM orders;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS orders_active_status_idx;
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS category,
+    DROP COLUMN IF EXISTS started_at,
+    DROP COLUMN IF EXISTS done_at;
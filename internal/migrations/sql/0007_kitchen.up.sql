@@ -0,0 +1,14 @@
+-- Кухонный экран: категория позиции фиксируется на момент заказа,
+-- started_at / done_at отмечают начало и окончание приготовления.
+ALTER TABLE order_items
+    ADD COLUMN category   TEXT NOT NULL DEFAULT '',
+    ADD COLUMN started_at TIMESTAMPTZ,
+    ADD COLUMN done_at    TIMESTAMPTZ;
+
+UPDATE order_items i
+SET category = p.category
+FROM products p
+WHERE p.id = i.product_id;
+
+CREATE INDEX orders_active_status_idx ON orders (status)
+    WHERE status IN ('placed', 'accepted', 'cooking');
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS chat_messages;
+DROP TABLE IF EXISTS chat_participants;
+DROP TABLE IF EXISTS chats;
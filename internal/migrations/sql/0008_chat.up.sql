@@ -0,0 +1,24 @@
+-- История чатов: чаты, участники и сообщения.
+CREATE TABLE chats (
+    id         TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE chat_participants (
+    chat_id      TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    username     TEXT NOT NULL,
+    joined_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_id, username)
+);
+
+CREATE TABLE chat_messages (
+    id      BIGSERIAL PRIMARY KEY,
+    chat_id TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    sender  TEXT NOT NULL,
+    text    TEXT NOT NULL,
+    sent_at BIGINT NOT NULL, -- unix-время в миллисекундах (Message.Timestamp)
+    read    BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX chat_messages_chat_id_id_idx ON chat_messages (chat_id, id DESC);
@@ -0,0 +1,24 @@
+ALTER TABLE chat_messages ADD COLUMN read BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE chat_messages m
+SET read = EXISTS (SELECT 1 FROM chat_receipts r WHERE r.message_id = m.id AND r.read_at IS NOT NULL);
+
+DROP TABLE IF EXISTS chat_receipts;
+
+DROP INDEX chat_messages_chat_id_id_idx;
+ALTER TABLE chat_messages DROP CONSTRAINT chat_messages_pkey;
+ALTER TABLE chat_messages RENAME COLUMN id TO uid;
+
+-- Числовые id восстанавливаются в порядке отправки.
+ALTER TABLE chat_messages ADD COLUMN id BIGINT;
+UPDATE chat_messages m
+SET id = n.rn
+FROM (SELECT uid, row_number() OVER (ORDER BY uid) AS rn FROM chat_messages) n
+WHERE n.uid = m.uid;
+CREATE SEQUENCE chat_messages_id_seq OWNED BY chat_messages.id;
+SELECT setval('chat_messages_id_seq', COALESCE(MAX(id), 0) + 1, false) FROM chat_messages;
+ALTER TABLE chat_messages
+    ALTER COLUMN id SET DEFAULT nextval('chat_messages_id_seq'),
+    ALTER COLUMN id SET NOT NULL,
+    ADD PRIMARY KEY (id);
+ALTER TABLE chat_messages DROP COLUMN uid;
+CREATE INDEX chat_messages_chat_id_id_idx ON chat_messages (chat_id, id DESC);
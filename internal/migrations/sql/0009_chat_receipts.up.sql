@@ -0,0 +1,30 @@
+-- Стабильные идентификаторы сообщений (ULID-подобные: 12 hex-символов времени в мс
+-- и 20 hex-символов случайной части) и отметки доставки/прочтения по каждому получателю.
+ALTER TABLE chat_messages ADD COLUMN uid TEXT;
+UPDATE chat_messages SET uid = lpad(to_hex(sent_at), 12, '0') || lpad(to_hex(id), 20, '0');
+
+CREATE TABLE chat_receipts (
+    message_id   TEXT NOT NULL,
+    username     TEXT NOT NULL,
+    delivered_at TIMESTAMPTZ,
+    read_at      TIMESTAMPTZ,
+    PRIMARY KEY (message_id, username)
+);
+
+-- Прочитанные сообщения считаем прочитанными всеми остальными участниками чата.
+INSERT INTO chat_receipts (message_id, username, delivered_at, read_at)
+SELECT m.uid, p.username, to_timestamp(m.sent_at / 1000.0), to_timestamp(m.sent_at / 1000.0)
+FROM chat_messages m
+JOIN chat_participants p ON p.chat_id = m.chat_id AND p.username <> m.sender
+WHERE m.read;
+
+DROP INDEX chat_messages_chat_id_id_idx;
+ALTER TABLE chat_messages DROP COLUMN id;
+ALTER TABLE chat_messages DROP COLUMN read;
+ALTER TABLE chat_messages RENAME COLUMN uid TO id;
+ALTER TABLE chat_messages ALTER COLUMN id SET NOT NULL;
+ALTER TABLE chat_messages ADD PRIMARY KEY (id);
+CREATE INDEX chat_messages_chat_id_id_idx ON chat_messages (chat_id, id DESC);
+
+ALTER TABLE chat_receipts
+    ADD FOREIGN KEY (message_id) REFERENCES chat_messages (id) ON DELETE CASCADE;
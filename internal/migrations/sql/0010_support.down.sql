@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS support_operators;
+DROP TABLE IF EXISTS support_tickets;
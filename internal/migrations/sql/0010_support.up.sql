@@ -0,0 +1,23 @@
+-- Очередь поддержки: обращения гостей и доступность операторов.
+CREATE TABLE support_tickets (
+    id          BIGSERIAL PRIMARY KEY,
+    chat_id     TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    guest_id    INTEGER NOT NULL REFERENCES guests (id) ON DELETE CASCADE,
+    status      TEXT NOT NULL DEFAULT 'waiting'
+                CHECK (status IN ('waiting', 'assigned', 'closed')),
+    operator_id INTEGER REFERENCES guests (id) ON DELETE SET NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    assigned_at TIMESTAMPTZ,
+    closed_at   TIMESTAMPTZ
+);
+
+-- В одном чате не больше одного открытого обращения.
+CREATE UNIQUE INDEX support_tickets_open_chat_idx ON support_tickets (chat_id) WHERE status <> 'closed';
+CREATE INDEX support_tickets_operator_idx ON support_tickets (operator_id) WHERE status = 'assigned';
+
+CREATE TABLE support_operators (
+    operator_id INTEGER PRIMARY KEY REFERENCES guests (id) ON DELETE CASCADE,
+    available   BOOLEAN NOT NULL DEFAULT FALSE,
+    max_tickets INTEGER NOT NULL DEFAULT 5 CHECK (max_tickets > 0),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
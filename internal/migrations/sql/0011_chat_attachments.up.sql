@@ -0,0 +1,18 @@
+-- Вложения чата: метаданные файлов, сами файлы лежат в хранилище (storage.Storage).
+-- message_id пуст, пока загруженный файл не отправлен в сообщении.
+CREATE TABLE chat_attachments (
+    id            TEXT PRIMARY KEY,
+    chat_id       TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    uploader      TEXT NOT NULL,
+    message_id    TEXT REFERENCES chat_messages (id) ON DELETE SET NULL,
+    file_name     TEXT NOT NULL,
+    content_type  TEXT NOT NULL,
+    size          BIGINT NOT NULL,
+    width         INTEGER NOT NULL,
+    height        INTEGER NOT NULL,
+    storage_key   TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX chat_attachments_message_id_idx ON chat_attachments (message_id);
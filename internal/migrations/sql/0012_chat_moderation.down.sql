@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS chat_restrictions;
+DROP TABLE IF EXISTS chat_message_revisions;
+
+ALTER TABLE chat_messages
+    DROP COLUMN IF EXISTS deleted_by,
+    DROP COLUMN IF EXISTS deleted_at,
+    DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,27 @@
+-- Редактирование и мягкое удаление сообщений (время в мс, как sent_at).
+ALTER TABLE chat_messages
+    ADD COLUMN edited_at  BIGINT,
+    ADD COLUMN deleted_at BIGINT,
+    ADD COLUMN deleted_by TEXT;
+
+-- Прежние тексты отредактированных сообщений; created_at – когда текст стал актуальным.
+CREATE TABLE chat_message_revisions (
+    id         BIGSERIAL PRIMARY KEY,
+    message_id TEXT NOT NULL REFERENCES chat_messages (id) ON DELETE CASCADE,
+    text       TEXT NOT NULL,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX chat_message_revisions_message_id_idx ON chat_message_revisions (message_id, id);
+
+-- Ограничения пользователей в чате: mute (только чтение) и ban (без подключения).
+CREATE TABLE chat_restrictions (
+    chat_id    TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    username   TEXT NOT NULL,
+    kind       TEXT NOT NULL CHECK (kind IN ('mute', 'ban')),
+    reason     TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ, -- NULL – бессрочно
+    PRIMARY KEY (chat_id, username, kind)
+);
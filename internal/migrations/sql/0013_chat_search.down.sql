@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS chat_messages_sent_at_idx;
+DROP INDEX IF EXISTS chat_messages_search_idx;
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS search;
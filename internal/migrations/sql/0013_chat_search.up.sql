@@ -0,0 +1,7 @@
+-- Полнотекстовый поиск по сообщениям чата для операторов: текст индексируется
+-- сразу в русской и английской конфигурациях, поэтому находятся формы слов обоих языков.
+ALTER TABLE chat_messages ADD COLUMN search tsvector
+    GENERATED ALWAYS AS (to_tsvector('russian', text) || to_tsvector('english', text)) STORED;
+
+CREATE INDEX chat_messages_search_idx ON chat_messages USING GIN (search);
+CREATE INDEX chat_messages_sent_at_idx ON chat_messages (sent_at);
@@ -0,0 +1,8 @@
+-- Отправленные уведомления о непрочитанных сообщениях: по каждому сообщению
+-- и получателю уведомление отправляется не больше одного раза.
+CREATE TABLE chat_notifications (
+    message_id  TEXT NOT NULL REFERENCES chat_messages (id) ON DELETE CASCADE,
+    username    TEXT NOT NULL,
+    notified_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, username)
+);
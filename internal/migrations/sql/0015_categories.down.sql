@@ -0,0 +1,12 @@
+ALTER TABLE products ADD COLUMN category TEXT NOT NULL DEFAULT '';
+
+UPDATE products p
+SET category = lower(COALESCE(c.names ->> 'ru', c.slug))
+FROM categories c
+WHERE c.id = p.category_id;
+
+ALTER TABLE products
+    ALTER COLUMN category DROP DEFAULT,
+    DROP COLUMN category_id;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,50 @@
+-- Категории меню: slug, порядок вывода, иконка, видимость и названия по локалям.
+CREATE TABLE categories (
+    id       SERIAL PRIMARY KEY,
+    slug     TEXT NOT NULL UNIQUE CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$'),
+    position INTEGER NOT NULL DEFAULT 0,
+    icon     TEXT NOT NULL DEFAULT '',
+    visible  BOOLEAN NOT NULL DEFAULT TRUE,
+    names    JSONB NOT NULL DEFAULT '{}' CHECK (jsonb_typeof(names) = 'object') -- {"ru": "Суши", "en": "Sushi"}
+);
+
+-- Перенос строковых категорий продуктов. Известным категориям из начальных данных
+-- задаются slug и английское название, остальные получают slug category-N.
+WITH known (name, slug, en, position) AS (
+    VALUES ('суши', 'sushi', 'Sushi', 10),
+           ('роллы', 'rolls', 'Rolls', 20),
+           ('сашими', 'sashimi', 'Sashimi', 30),
+           ('салаты', 'salads', 'Salads', 40),
+           ('закуски', 'appetizers', 'Appetizers', 50)
+),
+existing AS (
+    SELECT DISTINCT COALESCE(NULLIF(lower(trim(category)), ''), 'прочее') AS name
+    FROM products
+),
+numbered AS (
+    SELECT e.name, k.slug, k.en, k.position,
+           ROW_NUMBER() OVER (ORDER BY e.name) AS n
+    FROM existing e
+    LEFT JOIN known k ON k.name = e.name
+)
+INSERT INTO categories (slug, position, names)
+SELECT COALESCE(slug, 'category-' || n),
+       COALESCE(position, 100 + n::INTEGER),
+       CASE WHEN en IS NULL
+            THEN jsonb_build_object('ru', upper(left(name, 1)) || substr(name, 2))
+            ELSE jsonb_build_object('ru', upper(left(name, 1)) || substr(name, 2), 'en', en)
+       END
+FROM numbered;
+
+ALTER TABLE products ADD COLUMN category_id INTEGER REFERENCES categories (id) ON DELETE RESTRICT;
+
+UPDATE products p
+SET category_id = c.id
+FROM categories c
+WHERE lower(c.names ->> 'ru') = COALESCE(NULLIF(lower(trim(p.category)), ''), 'прочее');
+
+ALTER TABLE products
+    ALTER COLUMN category_id SET NOT NULL,
+    DROP COLUMN category;
+
+CREATE INDEX products_category_id_idx ON products (category_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS order_items_product_id_idx;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS available,
+    DROP COLUMN IF EXISTS tags;
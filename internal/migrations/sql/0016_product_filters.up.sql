@@ -0,0 +1,9 @@
+-- Фильтры каталога: диетические метки и доступность продукта.
+ALTER TABLE products
+    ADD COLUMN tags      TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN available BOOLEAN NOT NULL DEFAULT TRUE;
+
+CREATE INDEX products_tags_idx ON products USING GIN (tags);
+
+-- Популярность считается по позициям заказов.
+CREATE INDEX IF NOT EXISTS order_items_product_id_idx ON order_items (product_id);
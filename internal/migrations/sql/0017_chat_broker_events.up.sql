@@ -0,0 +1,9 @@
+-- События чата, не помещающиеся в полезную нагрузку NOTIFY: в уведомлении
+-- передаётся только id строки. Строки удаляются через несколько минут.
+CREATE TABLE chat_broker_events (
+    id         BIGSERIAL PRIMARY KEY,
+    payload    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX chat_broker_events_created_at_idx ON chat_broker_events (created_at);
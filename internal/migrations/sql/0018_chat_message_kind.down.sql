@@ -0,0 +1 @@
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS kind;
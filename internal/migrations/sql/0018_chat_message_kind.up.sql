@@ -0,0 +1,10 @@
+-- Вид сообщения: user – от участника чата, system – уведомление сервера,
+-- bot – ответ бота. Раньше сообщения сервера и бота отличались только
+-- именем отправителя, которое мог занять и гость.
+ALTER TABLE chat_messages ADD COLUMN kind TEXT NOT NULL DEFAULT 'user'
+    CHECK (kind IN ('user', 'system', 'bot'));
+
+UPDATE chat_messages m
+SET kind = CASE m.sender WHEN 'system' THEN 'system' ELSE 'bot' END
+WHERE m.sender IN ('system', 'robot')
+  AND NOT EXISTS (SELECT 1 FROM guests g WHERE g.username = m.sender);
@@ -0,0 +1,3 @@
+ALTER TABLE support_tickets DROP CONSTRAINT IF EXISTS support_tickets_assigned_operator;
+DROP TRIGGER IF EXISTS support_tickets_unassign ON support_tickets;
+DROP FUNCTION IF EXISTS support_tickets_unassign();
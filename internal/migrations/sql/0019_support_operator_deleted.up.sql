@@ -0,0 +1,21 @@
+-- Когда удаляют оператора, operator_id его обращений обнуляется (ON DELETE SET NULL);
+-- такие обращения возвращаются в очередь, а не остаются назначенными никому.
+UPDATE support_tickets SET status = 'waiting', assigned_at = NULL
+WHERE status = 'assigned' AND operator_id IS NULL;
+
+CREATE FUNCTION support_tickets_unassign() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status = 'assigned' AND NEW.operator_id IS NULL THEN
+        NEW.status := 'waiting';
+        NEW.assigned_at := NULL;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER support_tickets_unassign
+    BEFORE UPDATE OF operator_id ON support_tickets
+    FOR EACH ROW EXECUTE FUNCTION support_tickets_unassign();
+
+ALTER TABLE support_tickets ADD CONSTRAINT support_tickets_assigned_operator
+    CHECK (status <> 'assigned' OR operator_id IS NOT NULL);
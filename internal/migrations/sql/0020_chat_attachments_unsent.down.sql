@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS chat_attachments_unsent_created_at_idx;
+DROP INDEX IF EXISTS chat_attachments_unsent_uploader_idx;
@@ -0,0 +1,3 @@
+-- Неотправленные вложения: лимит на пользователя и удаление устаревших.
+CREATE INDEX chat_attachments_unsent_uploader_idx ON chat_attachments (uploader) WHERE message_id IS NULL;
+CREATE INDEX chat_attachments_unsent_created_at_idx ON chat_attachments (created_at) WHERE message_id IS NULL;
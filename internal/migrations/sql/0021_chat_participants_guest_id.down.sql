@@ -0,0 +1,9 @@
+ALTER TABLE chat_messages DROP COLUMN IF EXISTS sender_id;
+
+ALTER TABLE chat_participants ADD COLUMN username TEXT;
+UPDATE chat_participants p SET username = g.username FROM guests g WHERE g.id = p.guest_id;
+ALTER TABLE chat_participants DROP CONSTRAINT chat_participants_pkey;
+ALTER TABLE chat_participants DROP COLUMN guest_id;
+ALTER TABLE chat_participants
+    ALTER COLUMN username SET NOT NULL,
+    ADD PRIMARY KEY (chat_id, username);
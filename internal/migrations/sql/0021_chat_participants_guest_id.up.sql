@@ -0,0 +1,16 @@
+-- Участники чатов и авторы сообщений определяются по id гостя, а не по имени:
+-- имя можно сменить, и освободившееся имя зарегистрирует другой гость, который
+-- не должен получить чужие чаты. Участники с именами, которых уже нет, удаляются.
+ALTER TABLE chat_participants ADD COLUMN guest_id INTEGER REFERENCES guests (id) ON DELETE CASCADE;
+UPDATE chat_participants p SET guest_id = g.id FROM guests g WHERE g.username = p.username;
+DELETE FROM chat_participants WHERE guest_id IS NULL;
+ALTER TABLE chat_participants DROP CONSTRAINT chat_participants_pkey;
+ALTER TABLE chat_participants DROP COLUMN username;
+ALTER TABLE chat_participants
+    ALTER COLUMN guest_id SET NOT NULL,
+    ADD PRIMARY KEY (chat_id, guest_id);
+CREATE INDEX chat_participants_guest_id_idx ON chat_participants (guest_id);
+
+-- sender остаётся именем на момент отправки; у сообщений сервера и бота sender_id пуст.
+ALTER TABLE chat_messages ADD COLUMN sender_id INTEGER REFERENCES guests (id) ON DELETE SET NULL;
+UPDATE chat_messages m SET sender_id = g.id FROM guests g WHERE m.kind = 'user' AND g.username = m.sender;
@@ -0,0 +1,7 @@
+ALTER TABLE chat_receipts ADD COLUMN username TEXT;
+UPDATE chat_receipts r SET username = g.username FROM guests g WHERE g.id = r.guest_id;
+ALTER TABLE chat_receipts DROP CONSTRAINT chat_receipts_pkey;
+ALTER TABLE chat_receipts DROP COLUMN guest_id;
+ALTER TABLE chat_receipts
+    ALTER COLUMN username SET NOT NULL,
+    ADD PRIMARY KEY (message_id, username);
@@ -0,0 +1,14 @@
+-- Отметки доставки и прочтения хранятся по id получателя, а не по имени, чтобы
+-- гость, занявший освободившееся имя, не получил чужие отметки и очередь недоставленных.
+-- Отметки переносятся только на участников чата сообщения (см. 0021).
+ALTER TABLE chat_receipts ADD COLUMN guest_id INTEGER REFERENCES guests (id) ON DELETE CASCADE;
+UPDATE chat_receipts r SET guest_id = g.id
+FROM guests g, chat_messages m, chat_participants p
+WHERE g.username = r.username AND m.id = r.message_id
+  AND p.chat_id = m.chat_id AND p.guest_id = g.id AND m.sender_id IS DISTINCT FROM g.id;
+DELETE FROM chat_receipts WHERE guest_id IS NULL;
+ALTER TABLE chat_receipts DROP CONSTRAINT chat_receipts_pkey;
+ALTER TABLE chat_receipts DROP COLUMN username;
+ALTER TABLE chat_receipts
+    ALTER COLUMN guest_id SET NOT NULL,
+    ADD PRIMARY KEY (message_id, guest_id);
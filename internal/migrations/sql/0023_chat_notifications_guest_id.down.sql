@@ -0,0 +1,7 @@
+ALTER TABLE chat_notifications ADD COLUMN username TEXT;
+UPDATE chat_notifications n SET username = g.username FROM guests g WHERE g.id = n.guest_id;
+ALTER TABLE chat_notifications DROP CONSTRAINT chat_notifications_pkey;
+ALTER TABLE chat_notifications DROP COLUMN guest_id;
+ALTER TABLE chat_notifications
+    ALTER COLUMN username SET NOT NULL,
+    ADD PRIMARY KEY (message_id, username);
@@ -0,0 +1,13 @@
+-- Уведомления учитываются по id получателя, а не по имени: гость, занявший
+-- освободившееся имя, не должен получать уведомления о чужих сообщениях.
+ALTER TABLE chat_notifications ADD COLUMN guest_id INTEGER REFERENCES guests (id) ON DELETE CASCADE;
+UPDATE chat_notifications n SET guest_id = g.id
+FROM guests g, chat_messages m, chat_participants p
+WHERE g.username = n.username AND m.id = n.message_id
+  AND p.chat_id = m.chat_id AND p.guest_id = g.id;
+DELETE FROM chat_notifications WHERE guest_id IS NULL;
+ALTER TABLE chat_notifications DROP CONSTRAINT chat_notifications_pkey;
+ALTER TABLE chat_notifications DROP COLUMN username;
+ALTER TABLE chat_notifications
+    ALTER COLUMN guest_id SET NOT NULL,
+    ADD PRIMARY KEY (message_id, guest_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS chat_attachments_unsent_uploader_id_idx;
+CREATE INDEX IF NOT EXISTS chat_attachments_unsent_uploader_idx ON chat_attachments (uploader) WHERE message_id IS NULL;
+ALTER TABLE chat_attachments DROP COLUMN IF EXISTS uploader_id;
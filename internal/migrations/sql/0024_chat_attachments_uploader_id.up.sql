@@ -0,0 +1,14 @@
+-- Владелец вложения определяется по id гостя, а не по имени: иначе гость, занявший
+-- освободившееся имя, смог бы отправить чужие неотправленные вложения.
+-- uploader остаётся именем на момент загрузки. Неотправленные вложения, владельца
+-- которых нельзя определить, остаются без uploader_id и удаляются по сроку.
+ALTER TABLE chat_attachments ADD COLUMN uploader_id INTEGER REFERENCES guests (id) ON DELETE SET NULL;
+UPDATE chat_attachments a SET uploader_id = m.sender_id
+FROM chat_messages m
+WHERE m.id = a.message_id;
+UPDATE chat_attachments a SET uploader_id = g.id
+FROM guests g, chat_participants p
+WHERE a.message_id IS NULL AND g.username = a.uploader AND p.chat_id = a.chat_id AND p.guest_id = g.id;
+
+DROP INDEX chat_attachments_unsent_uploader_idx;
+CREATE INDEX chat_attachments_unsent_uploader_id_idx ON chat_attachments (uploader_id) WHERE message_id IS NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE chat_restrictions ADD COLUMN username TEXT;
+UPDATE chat_restrictions r SET username = g.username FROM guests g WHERE g.id = r.guest_id;
+ALTER TABLE chat_restrictions DROP CONSTRAINT chat_restrictions_pkey;
+ALTER TABLE chat_restrictions DROP COLUMN guest_id;
+ALTER TABLE chat_restrictions
+    ALTER COLUMN username SET NOT NULL,
+    ADD PRIMARY KEY (chat_id, username, kind);
@@ -0,0 +1,13 @@
+-- Ограничения в чатах привязаны к id гостя, а не к имени: переименование их не снимает,
+-- а гость, занявший освободившееся имя, их не получает. Пока ограничения были привязаны
+-- к имени, переименование с действующим ограничением запрещалось, поэтому действующие
+-- ограничения переносятся по имени; истёкшие удаляются.
+DELETE FROM chat_restrictions WHERE expires_at <= NOW();
+ALTER TABLE chat_restrictions ADD COLUMN guest_id INTEGER REFERENCES guests (id) ON DELETE CASCADE;
+UPDATE chat_restrictions r SET guest_id = g.id FROM guests g WHERE g.username = r.username;
+DELETE FROM chat_restrictions WHERE guest_id IS NULL;
+ALTER TABLE chat_restrictions DROP CONSTRAINT chat_restrictions_pkey;
+ALTER TABLE chat_restrictions DROP COLUMN username;
+ALTER TABLE chat_restrictions
+    ALTER COLUMN guest_id SET NOT NULL,
+    ADD PRIMARY KEY (chat_id, guest_id, kind);
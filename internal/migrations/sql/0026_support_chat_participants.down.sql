@@ -0,0 +1,2 @@
+-- Схема не менялась; удалённые записи об участии сотрудников не восстанавливаются.
+SELECT 1;
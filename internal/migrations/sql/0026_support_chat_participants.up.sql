@@ -0,0 +1,15 @@
+-- Участники чата поддержки – гость и оператор, которому назначено открытое обращение:
+-- только они получают счётчики непрочитанного и уведомления. Раньше участником
+-- становился любой сотрудник, открывший чат.
+DELETE FROM chat_participants p
+WHERE p.chat_id LIKE 'support:%'
+  AND p.chat_id <> 'support:' || p.guest_id
+  AND NOT EXISTS (
+      SELECT 1 FROM support_tickets t
+      WHERE t.chat_id = p.chat_id AND t.status = 'assigned' AND t.operator_id = p.guest_id);
+
+INSERT INTO chat_participants (chat_id, guest_id, joined_at, last_seen_at)
+SELECT t.chat_id, t.operator_id, t.assigned_at, t.assigned_at
+FROM support_tickets t
+WHERE t.status = 'assigned' AND t.operator_id IS NOT NULL
+ON CONFLICT (chat_id, guest_id) DO NOTHING;
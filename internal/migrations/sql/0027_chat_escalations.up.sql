@@ -0,0 +1,11 @@
+-- Журнал реакций на флуд в чате (предупреждение, mute, отключение) для операторов.
+CREATE TABLE chat_escalations (
+    id         BIGSERIAL PRIMARY KEY,
+    chat_id    TEXT NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    guest_id   INTEGER NOT NULL REFERENCES guests (id) ON DELETE CASCADE,
+    action     TEXT NOT NULL CHECK (action IN ('warning', 'mute', 'disconnect')),
+    exceeded   TEXT NOT NULL, -- превышенный лимит: frame, message, read или typing
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX chat_escalations_chat_id_idx ON chat_escalations (chat_id, id DESC);
@@ -1,33 +1,85 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"go-robot/internal/money"
+)
 
 // Guest – структура пользователя
 type Guest struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	Password string `json:"password,omitempty"` // только во входящих запросах, наружу не отдаётся
 	Phone    string `json:"phone"`
+	Role     string `json:"role,omitempty"` // guest, operator, kitchen, admin; клиентом не задаётся
 }
 
 // Product – структура товара (карточки)
 type Product struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Price       string `json:"price"`
-	Calories    int    `json:"calories"`
-	Category    string `json:"category"`
-	ImageURL    string `json:"image_url"`
+	ID          int         `json:"id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Calories    int         `json:"calories"`
+	CategoryID  int         `json:"category_id"`
+	Category    string      `json:"category"` // slug категории; при записи не учитывается
+	ImageURL    string      `json:"image_url"`
+	Tags        []string    `json:"tags"`                 // диетические метки из DietaryTags
+	Available   bool        `json:"available"`            // можно заказать сейчас
+	Popularity  int         `json:"popularity,omitempty"` // заказано порций; только в списке продуктов
+}
+
+// DietaryTags – допустимые диетические метки продуктов.
+var DietaryTags = []string{"vegetarian", "vegan", "gluten-free", "lactose-free", "spicy", "halal"}
+
+// DefaultLocale – язык названий категорий по умолчанию.
+const DefaultLocale = "ru"
+
+// Category – категория меню. Names – названия по локалям: {"ru": "Суши", "en": "Sushi"}.
+type Category struct {
+	ID       int               `json:"id"`
+	Slug     string            `json:"slug"`
+	Position int               `json:"position"` // порядок вывода, по возрастанию
+	Icon     string            `json:"icon"`
+	Visible  bool              `json:"visible"`
+	Names    map[string]string `json:"names"`
+	Name     string            `json:"name,omitempty"` // название на запрошенном языке; при записи не учитывается
+}
+
+// LocalName возвращает название на языке locale, иначе на DefaultLocale, иначе slug.
+func (c Category) LocalName(locale string) string {
+	if name := c.Names[locale]; name != "" {
+		return name
+	}
+	if name := c.Names[DefaultLocale]; name != "" {
+		return name
+	}
+	return c.Slug
 }
 
 // Order – структура заказа
 type Order struct {
-	ID            int       `json:"id"`
-	GuestID       int       `json:"guest_id"`
-	ProductIDs    []int     `json:"product_ids"` // список идентификаторов товаров
-	TotalPrice    string    `json:"total_price"`
-	TotalCalories int       `json:"total_calories"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            int         `json:"id"`
+	GuestID       int         `json:"guest_id"`
+	Items         []OrderItem `json:"items"`
+	TotalPrice    money.Money `json:"total_price"`
+	TotalCalories int         `json:"total_calories"`
+	Status        string      `json:"status"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// OrderItem – позиция заказа. Цена, название и калорийность фиксируются
+// на момент заказа и не меняются при последующем редактировании продукта.
+type OrderItem struct {
+	ID        int64       `json:"id"`
+	ProductID *int        `json:"product_id"` // nil, если продукт удалён
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+	Title     string      `json:"title"`
+	Calories  int         `json:"calories"`
+	Category  string      `json:"category"`
+	StartedAt *time.Time  `json:"started_at,omitempty"` // кухня начала готовить
+	DoneAt    *time.Time  `json:"done_at,omitempty"`    // кухня закончила
 }
\ No newline at end of file
@@ -0,0 +1,253 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch возвращается при сложении сумм в разных валютах.
+	ErrCurrencyMismatch = errors.New("суммы в разных валютах")
+	// ErrUnknownCurrency возвращается для кода валюты, которого нет в справочнике.
+	ErrUnknownCurrency = errors.New("неизвестная валюта")
+)
+
+// DefaultCurrency используется, если в строке цены нет ни символа, ни кода валюты.
+const DefaultCurrency = "USD"
+
+// Currency – описание валюты по ISO 4217.
+type Currency struct {
+	Code     string
+	Digits   int    // количество знаков дробной части (центы, копейки)
+	Symbol   string // символ для отображения
+	SymbolAt string // "prefix" ($10.00) или "suffix" (10.00 ₽)
+}
+
+// currencies – поддерживаемые валюты.
+var currencies = map[string]Currency{
+	"USD": {Code: "USD", Digits: 2, Symbol: "$", SymbolAt: "prefix"},
+	"EUR": {Code: "EUR", Digits: 2, Symbol: "€", SymbolAt: "prefix"},
+	"GBP": {Code: "GBP", Digits: 2, Symbol: "£", SymbolAt: "prefix"},
+	"RUB": {Code: "RUB", Digits: 2, Symbol: "₽", SymbolAt: "suffix"},
+	"PLN": {Code: "PLN", Digits: 2, Symbol: "zł", SymbolAt: "suffix"},
+	"UAH": {Code: "UAH", Digits: 2, Symbol: "₴", SymbolAt: "suffix"},
+	"JPY": {Code: "JPY", Digits: 0, Symbol: "¥", SymbolAt: "prefix"},
+}
+
+// currencyCodes – коды валют по алфавиту: Parse перебирает их в этом порядке,
+// чтобы результат не зависел от порядка обхода map.
+var currencyCodes = func() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}()
+
+// LookupCurrency возвращает описание валюты по коду.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// Money – точная денежная сумма в минимальных единицах валюты (центах, копейках).
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// New создаёт сумму из минимальных единиц, проверяя код валюты.
+func New(minor int64, currency string) (Money, error) {
+	c, ok := LookupCurrency(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+	return Money{Minor: minor, Currency: c.Code}, nil
+}
+
+// Zero возвращает нулевую сумму в валюте currency.
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse разбирает строку цены: "$10", "10.50 USD", "350 ₽", "12,90 zł".
+// Без символа и кода используется DefaultCurrency. Разделители разрядов не поддерживаются.
+func Parse(s string) (Money, error) {
+	rest, currency := detectCurrency(strings.TrimSpace(s))
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	c := currencies[currency]
+	minor, err := parseDecimal(strings.TrimSpace(rest), c.Digits)
+	if err != nil {
+		return Money{}, fmt.Errorf("некорректная цена %q: %w", s, err)
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// detectCurrency отделяет от s код (в любом регистре) или символ валюты в начале или в конце.
+// Коды проверяются раньше символов; если указано и то и другое, второе остаётся в rest,
+// и число не разберётся.
+func detectCurrency(s string) (rest, currency string) {
+	for _, code := range currencyCodes {
+		n := len(code)
+		switch {
+		case len(s) >= n && strings.EqualFold(s[:n], code):
+			return s[n:], code
+		case len(s) >= n && strings.EqualFold(s[len(s)-n:], code):
+			return s[:len(s)-n], code
+		}
+	}
+	for _, code := range currencyCodes {
+		symbol := currencies[code].Symbol
+		switch {
+		case strings.HasPrefix(s, symbol):
+			return s[len(symbol):], code
+		case strings.HasSuffix(s, symbol):
+			return s[:len(s)-len(symbol)], code
+		}
+	}
+	return s, ""
+}
+
+// MustParse – как Parse, но паникует при ошибке. Предназначена для констант (seed-данные).
+func MustParse(s string) Money {
+	m, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// parseDecimal переводит "10.5" (или "10,5") в минимальные единицы без потери точности.
+func parseDecimal(s string, digits int) (int64, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("ожидается число")
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, errors.New("ожидается неотрицательное число")
+	}
+	if len(frac) > digits {
+		return 0, fmt.Errorf("больше %d знаков после запятой", digits)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, errors.New("слишком большая сумма")
+	}
+	return n, nil
+}
+
+// isDigits сообщает, состоит ли s только из цифр 0–9 (пустая строка – тоже).
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Add складывает суммы; валюты должны совпадать.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s и %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	return Money{Minor: m.Minor + o.Minor, Currency: m.Currency}, nil
+}
+
+// Mul умножает сумму на количество.
+func (m Money) Mul(n int) Money {
+	return Money{Minor: m.Minor * int64(n), Currency: m.Currency}
+}
+
+// Amount возвращает сумму в основных единицах строкой с фиксированной точностью: "10.00".
+func (m Money) Amount() string {
+	c, ok := currencies[m.Currency]
+	if !ok || c.Digits == 0 {
+		return strconv.FormatInt(m.Minor, 10)
+	}
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign, minor = "-", -minor
+	}
+	pow := int64(1)
+	for i := 0; i < c.Digits; i++ {
+		pow *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/pow, c.Digits, minor%pow)
+}
+
+// String возвращает сумму для отображения: "$10.00", "350.00 ₽".
+func (m Money) String() string {
+	c, ok := currencies[m.Currency]
+	if !ok {
+		return m.Amount() + " " + m.Currency
+	}
+	if c.SymbolAt == "suffix" {
+		return m.Amount() + " " + c.Symbol
+	}
+	return c.Symbol + m.Amount()
+}
+
+// jsonMoney – единый JSON-формат денежных сумм во всех ответах API.
+type jsonMoney struct {
+	Minor     int64  `json:"amount_minor"`
+	Amount    string `json:"amount"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted"`
+}
+
+// MarshalJSON кодирует сумму как {"amount_minor":1000,"amount":"10.00","currency":"USD","formatted":"$10.00"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Minor: m.Minor, Amount: m.Amount(), Currency: m.Currency, Formatted: m.String()})
+}
+
+// UnmarshalJSON принимает объект {"amount_minor": 1000, "currency": "USD"}
+// или строку в формате Parse ("$10", "10.50 EUR").
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := Parse(s)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+	var v jsonMoney
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Minor == 0 && v.Amount != "" {
+		c, ok := LookupCurrency(v.Currency)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownCurrency, v.Currency)
+		}
+		minor, err := parseDecimal(v.Amount, c.Digits)
+		if err != nil {
+			return err
+		}
+		v.Minor = minor
+	}
+	parsed, err := New(v.Minor, v.Currency)
+	if err != nil {
+		return err
+	}
+	if parsed.Minor < 0 {
+		return errors.New("сумма не может быть отрицательной")
+	}
+	*m = parsed
+	return nil
+}
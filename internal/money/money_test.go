@@ -0,0 +1,156 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		// Символы и коды валют.
+		{in: "$10", want: Money{1000, "USD"}},
+		{in: "10.50 USD", want: Money{1050, "USD"}},
+		{in: "usd 3", want: Money{300, "USD"}},
+		{in: "EUR4.2", want: Money{420, "EUR"}},
+		{in: "€ 0.99", want: Money{99, "EUR"}},
+		{in: "£7", want: Money{700, "GBP"}},
+		{in: "350 ₽", want: Money{35000, "RUB"}},
+		{in: "350 rub", want: Money{35000, "RUB"}},
+		{in: "12,90 zł", want: Money{1290, "PLN"}},
+		{in: "40 ₴", want: Money{4000, "UAH"}},
+		{in: "¥500", want: Money{500, "JPY"}},
+		{in: "  7  ", want: Money{700, DefaultCurrency}},
+		{in: "10 XYZ", wantErr: true},
+		{in: "$10 EUR", wantErr: true},
+		{in: "10 USD EUR", wantErr: true},
+
+		// Разделители.
+		{in: "0.5", want: Money{50, "USD"}},
+		{in: ".5 €", want: Money{50, "EUR"}},
+		{in: "10,5 €", want: Money{1050, "EUR"}},
+		{in: "10.", want: Money{1000, "USD"}},
+		{in: "1,000.50", wantErr: true},
+		{in: "1 000", wantErr: true},
+		{in: "1.000.000", wantErr: true},
+		{in: "10.505", wantErr: true},
+		{in: "500.5 ¥", wantErr: true},
+
+		// Пустые и отрицательные значения.
+		{in: "", wantErr: true},
+		{in: "$", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "-5", wantErr: true},
+		{in: "$-5", wantErr: true},
+		{in: "-0", wantErr: true},
+		{in: "+5", wantErr: true},
+		{in: "5.-1", wantErr: true},
+		{in: "0x10", wantErr: true},
+
+		// Переполнение int64.
+		{in: "92233720368547758.07", want: Money{9223372036854775807, "USD"}},
+		{in: "92233720368547758.08", wantErr: true},
+		{in: "¥9223372036854775808", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDeterministic(t *testing.T) {
+	// Строки, в которых можно найти больше одной валюты, разбираются
+	// одинаково при любом порядке обхода справочника.
+	for _, in := range []string{"$10 EUR", "EUR 10 $", "usd10₽", "10 ¥"} {
+		first, firstErr := Parse(in)
+		for i := 0; i < 100; i++ {
+			got, err := Parse(in)
+			if got != first || (err == nil) != (firstErr == nil) {
+				t.Fatalf("Parse(%q) = %v, %v; first call gave %v, %v", in, got, err, first, firstErr)
+			}
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		in      string
+		digits  int
+		want    int64
+		wantErr bool
+	}{
+		{in: "10", digits: 2, want: 1000},
+		{in: "10.5", digits: 2, want: 1050},
+		{in: "10,05", digits: 2, want: 1005},
+		{in: "0,001", digits: 3, want: 1},
+		{in: "42", digits: 0, want: 42},
+		{in: "42.0", digits: 0, wantErr: true},
+		{in: "007.10", digits: 2, want: 710},
+		{in: "1,2,3", digits: 2, wantErr: true},
+		{in: "1.2,3", digits: 2, wantErr: true},
+		{in: " 1", digits: 2, wantErr: true},
+		{in: "", digits: 2, wantErr: true},
+		{in: "9223372036854775807", digits: 0, want: 9223372036854775807},
+		{in: "9223372036854775807", digits: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDecimal(tt.in, tt.digits)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseDecimal(%q, %d) = %d, want error", tt.in, tt.digits, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDecimal(%q, %d): %v", tt.in, tt.digits, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDecimal(%q, %d) = %d, want %d", tt.in, tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: `"$10"`, want: Money{1000, "USD"}},
+		{in: `{"amount_minor": 350, "currency": "rub"}`, want: Money{350, "RUB"}},
+		{in: `{"amount": "3.5", "currency": "EUR"}`, want: Money{350, "EUR"}},
+		{in: `{"amount_minor": -1, "currency": "USD"}`, wantErr: true},
+		{in: `{"amount": "-1", "currency": "USD"}`, wantErr: true},
+		{in: `{"amount_minor": 1, "currency": "XYZ"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got Money
+		err := got.UnmarshalJSON([]byte(tt.in))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("UnmarshalJSON(%s) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("UnmarshalJSON(%s): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("UnmarshalJSON(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package orders
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status – этап жизненного цикла заказа.
+type Status string
+
+const (
+	StatusPlaced         Status = "placed"
+	StatusAccepted       Status = "accepted"
+	StatusCooking        Status = "cooking"
+	StatusReady          Status = "ready"
+	StatusOutForDelivery Status = "out_for_delivery"
+	StatusDelivered      Status = "delivered"
+	StatusCancelled      Status = "cancelled"
+	StatusRejected       Status = "rejected"
+)
+
+var (
+	// ErrIllegalTransition возвращается при переходе, которого нет в таблице transitions.
+	ErrIllegalTransition = errors.New("недопустимый переход статуса заказа")
+	// ErrNotFound возвращается, если заказа не существует.
+	ErrNotFound = errors.New("заказ не найден")
+)
+
+// transitions – допустимые переходы: placed → accepted → cooking → ready →
+// out_for_delivery → delivered; отмена возможна до передачи курьеру,
+// отклонение – только для нового заказа.
+var transitions = map[Status][]Status{
+	StatusPlaced:         {StatusAccepted, StatusRejected, StatusCancelled},
+	StatusAccepted:       {StatusCooking, StatusCancelled},
+	StatusCooking:        {StatusReady, StatusCancelled},
+	StatusReady:          {StatusOutForDelivery, StatusCancelled},
+	StatusOutForDelivery: {StatusDelivered},
+}
+
+// Valid сообщает, является ли статус известным.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusPlaced, StatusAccepted, StatusCooking, StatusReady,
+		StatusOutForDelivery, StatusDelivered, StatusCancelled, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// Final сообщает, что из статуса больше нет переходов.
+func (s Status) Final() bool {
+	return len(transitions[s]) == 0
+}
+
+// CanTransition сообщает, разрешён ли переход from → to.
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Event – запись истории статусов (таблица order_status_events).
+type Event struct {
+	ID        int64     `json:"id"` // растёт в порядке изменений заказа: строка заказа блокируется до записи события
+	OrderID   int       `json:"order_id"`
+	GuestID   int       `json:"guest_id"` // владелец заказа
+	From      *Status   `json:"from"`     // nil для первого события (создание заказа)
+	To        Status    `json:"to"`
+	ActorID   *int      `json:"actor_id"` // nil для системных переходов
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Service меняет статусы заказов и оповещает подписчиков об изменениях
+// (WebSocket-трекинг, кухонный экран).
+type Service struct {
+	db *sql.DB
+
+	mu        sync.RWMutex
+	listeners []func(Event)
+}
+
+// NewService создаёт Service.
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+// OnStatusChange регистрирует обработчик, вызываемый после каждого сохранённого события.
+// Обработчик вызывается синхронно и не должен блокироваться.
+func (s *Service) OnStatusChange(fn func(Event)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+// Publish передаёт событие всем подписчикам. Вызывается после фиксации транзакции.
+func (s *Service) Publish(ev Event) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// RecordPlaced записывает первое событие истории для только что созданного заказа.
+// Событие нужно передать в Publish после фиксации tx.
+func (s *Service) RecordPlaced(tx *sql.Tx, orderID, guestID int) (Event, error) {
+	placed := StatusPlaced
+	ev := Event{OrderID: orderID, GuestID: guestID, To: placed, ActorID: &guestID}
+	err := tx.QueryRow(`
+		INSERT INTO order_status_events (order_id, from_status, to_status, actor_id)
+		VALUES ($1, NULL, $2, $3)
+		RETURNING id, created_at`, orderID, StatusPlaced, guestID).Scan(&ev.ID, &ev.CreatedAt)
+	return ev, err
+}
+
+// ChangeStatus переводит заказ в статус to, проверяя таблицу переходов,
+// записывает событие в историю и оповещает подписчиков.
+// actorID = nil означает системный переход.
+func (s *Service) ChangeStatus(orderID int, to Status, actorID *int, note string) (Event, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Event{}, err
+	}
+	defer tx.Rollback()
+
+	var from Status
+	var guestID int
+	err = tx.QueryRow(`SELECT status, guest_id FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&from, &guestID)
+	if err == sql.ErrNoRows {
+		return Event{}, ErrNotFound
+	}
+	if err != nil {
+		return Event{}, err
+	}
+	if !CanTransition(from, to) {
+		return Event{}, fmt.Errorf("%w: %s → %s", ErrIllegalTransition, from, to)
+	}
+	if _, err := tx.Exec(`UPDATE orders SET status = $1 WHERE id = $2`, to, orderID); err != nil {
+		return Event{}, err
+	}
+	ev := Event{OrderID: orderID, GuestID: guestID, From: &from, To: to, ActorID: actorID, Note: note}
+	if err := tx.QueryRow(`
+		INSERT INTO order_status_events (order_id, from_status, to_status, actor_id, note)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`, orderID, from, to, actorID, note).Scan(&ev.ID, &ev.CreatedAt); err != nil {
+		return Event{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Event{}, err
+	}
+	s.Publish(ev)
+	return ev, nil
+}
+
+// Current возвращает владельца и последнее событие заказа (для повтора текущего статуса подписчику).
+func (s *Service) Current(orderID int) (Event, error) {
+	var ev Event
+	err := s.db.QueryRow(`
+		SELECT e.id, o.id, o.guest_id, e.from_status, o.status, e.actor_id, e.note, e.created_at
+		FROM orders o
+		JOIN LATERAL (
+			SELECT id, from_status, actor_id, note, created_at
+			FROM order_status_events
+			WHERE order_id = o.id
+			ORDER BY id DESC
+			LIMIT 1
+		) e ON TRUE
+		WHERE o.id = $1`, orderID).
+		Scan(&ev.ID, &ev.OrderID, &ev.GuestID, &ev.From, &ev.To, &ev.ActorID, &ev.Note, &ev.CreatedAt)
+	if err == sql.ErrNoRows {
+		return Event{}, ErrNotFound
+	}
+	return ev, err
+}
+
+// History возвращает историю статусов заказа в хронологическом порядке.
+func (s *Service) History(orderID int) ([]Event, error) {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.order_id, o.guest_id, e.from_status, e.to_status, e.actor_id, e.note, e.created_at
+		FROM order_status_events e
+		JOIN orders o ON o.id = e.order_id
+		WHERE e.order_id = $1
+		ORDER BY e.id`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []Event{}
+	for rows.Next() {
+		var ev Event
+		if err := rows.Scan(&ev.ID, &ev.OrderID, &ev.GuestID, &ev.From, &ev.To, &ev.ActorID, &ev.Note, &ev.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, rows.Err()
+}
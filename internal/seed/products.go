@@ -2,11 +2,34 @@ package seed
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
 )
 
+// InsertSampleCategories добавляет недостающие категории из SampleCategories.
+// Существующие категории не меняются: их могли отредактировать в админке.
+func InsertSampleCategories(db *sql.DB) {
+	for _, c := range SampleCategories {
+		names, err := json.Marshal(c.Names)
+		if err != nil {
+			log.Printf("Ошибка подготовки категории %s: %v", c.Slug, err)
+			continue
+		}
+		res, err := db.Exec(`
+			INSERT INTO categories (slug, position, icon, visible, names)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (slug) DO NOTHING`, c.Slug, c.Position, c.Icon, c.Visible, names)
+		if err != nil {
+			log.Printf("Ошибка вставки категории %s: %v", c.Slug, err)
+		} else if n, _ := res.RowsAffected(); n > 0 {
+			log.Printf("Категория %s успешно добавлена", c.Slug)
+		}
+	}
+}
+
 // InsertSampleProducts добавляет или обновляет карточки продуктов в базе,
 // а затем удаляет дубликаты (оставляя только уникальные записи).
+// Категории продуктов задаются slug и должны уже существовать (см. InsertSampleCategories).
 func InsertSampleProducts(db *sql.DB) {
 	// Используем карточки из файла sample_products.go (SampleProducts)
 	for _, p := range SampleProducts {
@@ -17,9 +40,10 @@ func InsertSampleProducts(db *sql.DB) {
 			// Если продукт существует, обновляем его данные.
 			updateQuery := `
 				UPDATE products
-				SET description = $1, price = $2, calories = $3, category = $4
-				WHERE id = $5`
-			_, err = db.Exec(updateQuery, p.Description, p.Price, p.Calories, p.Category, existingID)
+				SET description = $1, price_minor = $2, currency = $3, calories = $4,
+				    category_id = (SELECT id FROM categories WHERE slug = $5)
+				WHERE id = $6`
+			_, err = db.Exec(updateQuery, p.Description, p.Price.Minor, p.Price.Currency, p.Calories, p.Category, existingID)
 			if err != nil {
 				log.Printf("Ошибка обновления продукта %s (id=%d): %v", p.Title, existingID, err)
 			} else {
@@ -34,9 +58,9 @@ func InsertSampleProducts(db *sql.DB) {
 
 		// Если продукта нет, выполняем вставку.
 		insertQuery := `
-			INSERT INTO products (title, description, price, calories, category, image_url)
-			VALUES ($1, $2, $3, $4, $5, $6)`
-		_, err = db.Exec(insertQuery, p.Title, p.Description, p.Price, p.Calories, p.Category, p.ImageURL)
+			INSERT INTO products (title, description, price_minor, currency, calories, category_id, image_url)
+			VALUES ($1, $2, $3, $4, $5, (SELECT id FROM categories WHERE slug = $6), $7)`
+		_, err = db.Exec(insertQuery, p.Title, p.Description, p.Price.Minor, p.Price.Currency, p.Calories, p.Category, p.ImageURL)
 		if err != nil {
 			log.Printf("Ошибка вставки продукта %s: %v", p.Title, err)
 		} else {
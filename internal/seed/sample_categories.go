@@ -0,0 +1,12 @@
+package seed
+
+import "go-robot/internal/models"
+
+// SampleCategories – начальные категории меню; SampleProducts ссылаются на них по slug.
+var SampleCategories = []models.Category{
+	{Slug: "sushi", Position: 10, Icon: "🍣", Visible: true, Names: map[string]string{"ru": "Суши", "en": "Sushi"}},
+	{Slug: "rolls", Position: 20, Icon: "🍙", Visible: true, Names: map[string]string{"ru": "Роллы", "en": "Rolls"}},
+	{Slug: "sashimi", Position: 30, Icon: "🐟", Visible: true, Names: map[string]string{"ru": "Сашими", "en": "Sashimi"}},
+	{Slug: "salads", Position: 40, Icon: "🥗", Visible: true, Names: map[string]string{"ru": "Салаты", "en": "Salads"}},
+	{Slug: "appetizers", Position: 50, Icon: "🥟", Visible: true, Names: map[string]string{"ru": "Закуски", "en": "Appetizers"}},
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"io"
+	"path"
+)
+
+// S3API – операции S3-совместимого клиента, которые нужны хранилищу.
+// Реализуется тонкой обёрткой над SDK (aws-sdk-go, minio-go) при подключении
+// облачного хранилища; сам SDK в зависимости сервиса не входит.
+// GetObject должен возвращать ErrNotFound для отсутствующего ключа.
+type S3API interface {
+	PutObject(bucket, key string, body io.Reader, contentType string) error
+	GetObject(bucket, key string) (io.ReadCloser, error)
+	DeleteObject(bucket, key string) error
+}
+
+// S3 хранит объекты в бакете S3-совместимого хранилища (AWS S3, MinIO, Yandex Object Storage).
+type S3 struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+// NewS3 создаёт хранилище в бакете bucket; prefix добавляется ко всем ключам.
+func NewS3(client S3API, bucket, prefix string) *S3 {
+	return &S3{client: client, bucket: bucket, prefix: prefix}
+}
+
+func (s *S3) key(key string) string {
+	return path.Join(s.prefix, key)
+}
+
+// Put загружает объект.
+func (s *S3) Put(key string, r io.Reader, contentType string) error {
+	return s.client.PutObject(s.bucket, s.key(key), r, contentType)
+}
+
+// Open скачивает объект.
+func (s *S3) Open(key string) (io.ReadCloser, error) {
+	return s.client.GetObject(s.bucket, s.key(key))
+}
+
+// Delete удаляет объект.
+func (s *S3) Delete(key string) error {
+	return s.client.DeleteObject(s.bucket, s.key(key))
+}
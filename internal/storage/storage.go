@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound возвращается, если объекта с таким ключом нет.
+	ErrNotFound = errors.New("объект не найден")
+	// ErrInvalidKey возвращается для ключа, выходящего за пределы хранилища.
+	ErrInvalidKey = errors.New("некорректный ключ объекта")
+)
+
+// Storage – хранилище файлов (вложений чата). Ключ – путь вида "attachments/abc.jpg".
+type Storage interface {
+	// Put сохраняет объект, заменяя существующий с тем же ключом.
+	Put(key string, r io.Reader, contentType string) error
+	// Open открывает объект для чтения; ErrNotFound, если его нет.
+	Open(key string) (io.ReadCloser, error)
+	// Delete удаляет объект; отсутствие объекта ошибкой не считается.
+	Delete(key string) error
+}
+
+// Local хранит объекты в каталоге локальной файловой системы.
+type Local struct {
+	root string
+}
+
+// NewLocal создаёт каталог root (если нужно) и возвращает хранилище в нём.
+func NewLocal(root string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+// path переводит ключ в путь внутри root, не позволяя выйти за его пределы.
+func (s *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// Put записывает объект во временный файл и переименовывает его,
+// чтобы читатели не увидели недописанный файл.
+func (s *Local) Put(key string, r io.Reader, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // после успешного Rename файла уже нет
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open открывает объект.
+func (s *Local) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete удаляет объект.
+func (s *Local) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
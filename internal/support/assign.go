@@ -0,0 +1,45 @@
+package support
+
+import "database/sql"
+
+// Assigner – правило автоматического назначения обращений.
+type Assigner interface {
+	// Pick выбирает оператора для обращения t внутри транзакции tx;
+	// ok = false, если назначать некого – обращение остаётся в очереди.
+	Pick(tx *sql.Tx, t Ticket) (operatorID int, ok bool, err error)
+}
+
+// Manual никого не назначает: операторы сами берут обращения из очереди.
+type Manual struct{}
+
+// Pick всегда оставляет обращение в очереди.
+func (Manual) Pick(*sql.Tx, Ticket) (int, bool, error) {
+	return 0, false, nil
+}
+
+// LeastBusy назначает доступного оператора с наименьшим числом открытых
+// обращений, не превышая его лимит; при равенстве – того, кто дольше
+// не получал новых обращений.
+type LeastBusy struct{}
+
+// Pick выбирает наименее загруженного оператора.
+func (LeastBusy) Pick(tx *sql.Tx, _ Ticket) (int, bool, error) {
+	var operatorID int
+	err := tx.QueryRow(`
+		SELECT o.operator_id
+		FROM support_operators o
+		JOIN guests g ON g.id = o.operator_id AND g.role IN ('operator', 'admin')
+		LEFT JOIN support_tickets t ON t.operator_id = o.operator_id AND t.status = 'assigned'
+		WHERE o.available
+		GROUP BY o.operator_id, o.max_tickets
+		HAVING COUNT(t.id) < o.max_tickets
+		ORDER BY COUNT(t.id), MAX(t.assigned_at) NULLS FIRST, o.operator_id
+		LIMIT 1`).Scan(&operatorID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return operatorID, true, nil
+}
@@ -0,0 +1,383 @@
+package support
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"go-robot/internal/auth"
+)
+
+// Status – этап обращения в поддержку.
+type Status string
+
+const (
+	StatusWaiting  Status = "waiting"  // в очереди, оператор не назначен
+	StatusAssigned Status = "assigned" // оператор ведёт диалог
+	StatusClosed   Status = "closed"
+)
+
+// Valid сообщает, является ли статус известным.
+func (s Status) Valid() bool {
+	switch s {
+	case StatusWaiting, StatusAssigned, StatusClosed:
+		return true
+	}
+	return false
+}
+
+var (
+	// ErrNotFound возвращается, если обращения не существует.
+	ErrNotFound = errors.New("обращение не найдено")
+	// ErrClosed возвращается при действиях с закрытым обращением.
+	ErrClosed = errors.New("обращение уже закрыто")
+	// ErrAlreadyAssigned возвращается, если обращение уже ведёт другой оператор.
+	ErrAlreadyAssigned = errors.New("обращение уже назначено другому оператору")
+	// ErrNotAssignee возвращается, если действие доступно только назначенному оператору.
+	ErrNotAssignee = errors.New("обращение назначено другому оператору")
+	// ErrNotOperator возвращается при передаче обращения пользователю без доступа к поддержке.
+	ErrNotOperator = errors.New("пользователь не является оператором")
+)
+
+// assignLockKey – ключ advisory-блокировки, сериализующей автоназначение,
+// чтобы два экземпляра не превысили лимит обращений оператора.
+const assignLockKey = 727000002
+
+// Ticket – обращение гостя; одно открытое обращение на чат поддержки.
+type Ticket struct {
+	ID           int64      `json:"id"`
+	ChatID       string     `json:"chat_id"`
+	GuestID      int        `json:"guest_id"`
+	GuestName    string     `json:"guest_name"`
+	Status       Status     `json:"status"`
+	OperatorID   *int       `json:"operator_id"`
+	OperatorName string     `json:"operator_name,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	AssignedAt   *time.Time `json:"assigned_at,omitempty"`
+	ClosedAt     *time.Time `json:"closed_at,omitempty"`
+}
+
+// assignedTo сообщает, назначено ли обращение оператору operatorID.
+// У назначенного обращения operator_id может быть пуст, если оператора удалили.
+func (t Ticket) assignedTo(operatorID int) bool {
+	return t.Status == StatusAssigned && t.OperatorID != nil && *t.OperatorID == operatorID
+}
+
+// Action – что произошло с обращением.
+type Action string
+
+const (
+	ActionOpened      Action = "opened"
+	ActionAssigned    Action = "assigned"
+	ActionTransferred Action = "transferred"
+	ActionClosed      Action = "closed"
+)
+
+// Change – событие очереди поддержки для подписчиков (уведомления в чате).
+type Change struct {
+	Action             Action `json:"action"`
+	Ticket             Ticket `json:"ticket"`
+	ActorID            *int   `json:"actor_id,omitempty"`             // nil для автоматического назначения
+	PreviousOperatorID *int   `json:"previous_operator_id,omitempty"` // оператор, передавший обращение
+}
+
+// Service ведёт очередь обращений: создание, назначение операторов, передачу и закрытие.
+type Service struct {
+	db       *sql.DB
+	assigner Assigner
+
+	mu        sync.RWMutex
+	listeners []func(Change)
+}
+
+// NewService создаёт Service. assigner выбирает оператора для новых обращений.
+func NewService(db *sql.DB, assigner Assigner) *Service {
+	return &Service{db: db, assigner: assigner}
+}
+
+// OnChange регистрирует обработчик, вызываемый после каждого сохранённого изменения.
+// Обработчик вызывается синхронно.
+func (s *Service) OnChange(fn func(Change)) {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, fn)
+	s.mu.Unlock()
+}
+
+func (s *Service) publish(ch Change) {
+	s.mu.RLock()
+	listeners := s.listeners
+	s.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ch)
+	}
+}
+
+const selectTicket = `
+	SELECT t.id, t.chat_id, t.guest_id, g.username, t.status, t.operator_id,
+	       COALESCE(op.username, ''), t.created_at, t.assigned_at, t.closed_at
+	FROM support_tickets t
+	JOIN guests g ON g.id = t.guest_id
+	LEFT JOIN guests op ON op.id = t.operator_id`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTicket(row scanner) (Ticket, error) {
+	var t Ticket
+	err := row.Scan(&t.ID, &t.ChatID, &t.GuestID, &t.GuestName, &t.Status, &t.OperatorID,
+		&t.OperatorName, &t.CreatedAt, &t.AssignedAt, &t.ClosedAt)
+	if err == sql.ErrNoRows {
+		return Ticket{}, ErrNotFound
+	}
+	return t, err
+}
+
+// lock загружает обращение в транзакции и блокирует его строку.
+func lock(tx *sql.Tx, id int64) (Ticket, error) {
+	return scanTicket(tx.QueryRow(selectTicket+` WHERE t.id = $1 FOR UPDATE OF t`, id))
+}
+
+// Get возвращает обращение по id.
+func (s *Service) Get(id int64) (Ticket, error) {
+	return scanTicket(s.db.QueryRow(selectTicket+` WHERE t.id = $1`, id))
+}
+
+// Current возвращает открытое обращение чата или ErrNotFound.
+func (s *Service) Current(chatID string) (Ticket, error) {
+	return scanTicket(s.db.QueryRow(selectTicket+` WHERE t.chat_id = $1 AND t.status <> 'closed'`, chatID))
+}
+
+// List возвращает обращения со статусом status (пустой – все открытые), начиная с самых старых.
+// operatorID > 0 оставляет только обращения этого оператора.
+func (s *Service) List(status Status, operatorID int) ([]Ticket, error) {
+	rows, err := s.db.Query(selectTicket+`
+		WHERE ($1 = '' AND t.status <> 'closed' OR t.status = $1)
+		  AND ($2 = 0 OR t.operator_id = $2)
+		ORDER BY t.created_at, t.id`, string(status), operatorID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Ticket{}
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// Open возвращает открытое обращение чата, создавая его при необходимости.
+// Новое обращение сразу предлагается assigner'у.
+func (s *Service) Open(guestID int, chatID string) (Ticket, error) {
+	var id int64
+	err := s.db.QueryRow(`
+		INSERT INTO support_tickets (chat_id, guest_id) VALUES ($1, $2)
+		ON CONFLICT (chat_id) WHERE status <> 'closed' DO NOTHING
+		RETURNING id`, chatID, guestID).Scan(&id)
+	if err == sql.ErrNoRows {
+		// Обращение уже открыто.
+		return s.Current(chatID)
+	}
+	if err != nil {
+		return Ticket{}, err
+	}
+	t, err := s.Get(id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	s.publish(Change{Action: ActionOpened, Ticket: t})
+	if assigned, ok, err := s.autoAssign(id); err != nil {
+		log.Printf("Ошибка автоназначения обращения %d: %v", id, err)
+	} else if ok {
+		t = assigned
+	}
+	return t, nil
+}
+
+// Claim назначает ожидающее обращение оператору operatorID.
+func (s *Service) Claim(id int64, operatorID int) (Ticket, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Ticket{}, err
+	}
+	defer tx.Rollback()
+	t, err := lock(tx, id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	switch {
+	case t.Status == StatusClosed:
+		return Ticket{}, ErrClosed
+	case t.assignedTo(operatorID):
+		return t, nil
+	case t.Status == StatusAssigned && t.OperatorID != nil:
+		return Ticket{}, ErrAlreadyAssigned
+	}
+	if t, err = assign(tx, id, operatorID); err != nil {
+		return Ticket{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Ticket{}, err
+	}
+	s.publish(Change{Action: ActionAssigned, Ticket: t, ActorID: &operatorID})
+	return t, nil
+}
+
+// Transfer передаёт обращение оператору toOperatorID. Назначенное обращение
+// может передать только его оператор или администратор.
+func (s *Service) Transfer(id int64, actor auth.Identity, toOperatorID int) (Ticket, error) {
+	var role auth.Role
+	err := s.db.QueryRow(`SELECT role FROM guests WHERE id = $1`, toOperatorID).Scan(&role)
+	if err == sql.ErrNoRows || err == nil && !role.Can(auth.PermChatAdmin) {
+		return Ticket{}, ErrNotOperator
+	}
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Ticket{}, err
+	}
+	defer tx.Rollback()
+	t, err := lock(tx, id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if t.Status == StatusClosed {
+		return Ticket{}, ErrClosed
+	}
+	if t.Status == StatusAssigned && t.OperatorID != nil && !t.assignedTo(actor.GuestID) && actor.Role != auth.RoleAdmin {
+		return Ticket{}, ErrNotAssignee
+	}
+	previous := t.OperatorID
+	if t, err = assign(tx, id, toOperatorID); err != nil {
+		return Ticket{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Ticket{}, err
+	}
+	s.publish(Change{Action: ActionTransferred, Ticket: t, ActorID: &actor.GuestID, PreviousOperatorID: previous})
+	return t, nil
+}
+
+// Close закрывает обращение. Закрыть его могут назначенный оператор и
+// администратор, а ожидающее обращение – любой оператор.
+// Освободившийся оператор получает следующее обращение из очереди.
+func (s *Service) Close(id int64, actor auth.Identity) (Ticket, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Ticket{}, err
+	}
+	defer tx.Rollback()
+	t, err := lock(tx, id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if t.Status == StatusClosed {
+		return Ticket{}, ErrClosed
+	}
+	allowed := actor.Role == auth.RoleAdmin ||
+		(t.Status == StatusWaiting || t.OperatorID == nil) && actor.Can(auth.PermChatAdmin) ||
+		t.assignedTo(actor.GuestID)
+	if !allowed {
+		return Ticket{}, ErrNotAssignee
+	}
+	if _, err := tx.Exec(`UPDATE support_tickets SET status = 'closed', closed_at = NOW() WHERE id = $1`, id); err != nil {
+		return Ticket{}, err
+	}
+	if t, err = lock(tx, id); err != nil {
+		return Ticket{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Ticket{}, err
+	}
+	s.publish(Change{Action: ActionClosed, Ticket: t, ActorID: &actor.GuestID})
+	if t.OperatorID != nil {
+		s.assignWaiting()
+	}
+	return t, nil
+}
+
+// SetAvailability включает или выключает приём обращений оператором.
+// maxTickets <= 0 оставляет текущий лимит. Вышедший на линию оператор
+// сразу получает ожидающие обращения.
+func (s *Service) SetAvailability(operatorID int, available bool, maxTickets int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO support_operators (operator_id, available, max_tickets)
+		VALUES ($1, $2, COALESCE(NULLIF($3, 0), 5))
+		ON CONFLICT (operator_id) DO UPDATE
+		SET available = EXCLUDED.available,
+		    max_tickets = COALESCE(NULLIF($3, 0), support_operators.max_tickets),
+		    updated_at = NOW()`, operatorID, available, max(maxTickets, 0))
+	if err != nil {
+		return err
+	}
+	if available {
+		s.assignWaiting()
+	}
+	return nil
+}
+
+// assign назначает обращение оператору в транзакции tx и возвращает его новое состояние.
+func assign(tx *sql.Tx, id int64, operatorID int) (Ticket, error) {
+	if _, err := tx.Exec(`
+		UPDATE support_tickets SET status = 'assigned', operator_id = $2, assigned_at = NOW()
+		WHERE id = $1`, id, operatorID); err != nil {
+		return Ticket{}, err
+	}
+	return lock(tx, id)
+}
+
+// autoAssign предлагает ожидающее обращение assigner'у; ok = false, если никто не назначен.
+func (s *Service) autoAssign(id int64) (Ticket, bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return Ticket{}, false, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, assignLockKey); err != nil {
+		return Ticket{}, false, err
+	}
+	t, err := lock(tx, id)
+	if err != nil || t.Status != StatusWaiting {
+		return Ticket{}, false, err
+	}
+	operatorID, ok, err := s.assigner.Pick(tx, t)
+	if err != nil || !ok {
+		return Ticket{}, false, err
+	}
+	if t, err = assign(tx, id, operatorID); err != nil {
+		return Ticket{}, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Ticket{}, false, err
+	}
+	s.publish(Change{Action: ActionAssigned, Ticket: t})
+	return t, true, nil
+}
+
+// assignWaiting раздаёт ожидающие обращения в порядке очереди, пока есть свободные операторы.
+func (s *Service) assignWaiting() {
+	waiting, err := s.List(StatusWaiting, 0)
+	if err != nil {
+		log.Printf("Ошибка получения очереди поддержки: %v", err)
+		return
+	}
+	for _, t := range waiting {
+		_, ok, err := s.autoAssign(t.ID)
+		if err != nil {
+			log.Printf("Ошибка автоназначения обращения %d: %v", t.ID, err)
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+}